@@ -0,0 +1,170 @@
+// Package ceworkq maps CloudEvents onto workq jobs, so that workq can be used
+// as a CloudEvents transport.
+//
+// Events are encoded in binary content mode: the event type becomes the job
+// name, the event id the job ID and the event data the job payload. All other
+// attributes and extensions are sent as "ce-" prefixed job headers, with the
+// data content type in the standard "content-type" header.
+//
+// As in the HTTP binary content mode, extensions are sent in their string
+// form and decoded as strings. Convert typed extensions back with the
+// conversion functions of the types package, such as types.ToInteger.
+//
+//	j, err := ceworkq.ToJob(e)
+//	j.TTR, j.TTL = 5000, 60000
+//	err = client.Add(j)
+//
+//	// Worker
+//	leased, err := client.Lease([]string{"com.example.order.created"}, 60000)
+//	e, err := ceworkq.FromJob(leased)
+package ceworkq
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"github.com/iamduo/go-workq"
+	"github.com/satori/go.uuid"
+)
+
+var (
+	// ErrNotEvent is returned when decoding a job without CloudEvents headers.
+	ErrNotEvent = errors.New("Job is not a CloudEvent")
+	// ErrInvalidID is returned when encoding an event whose id is not a UUID.
+	ErrInvalidID = errors.New("CloudEvent id is not a UUID")
+	// ErrInvalidType is returned when encoding an event whose type is not a
+	// valid job name.
+	ErrInvalidType = errors.New("CloudEvent type is not a valid job name")
+)
+
+// Job headers of CloudEvents attributes.
+const (
+	HeaderSpecVersion = "ce-specversion"
+	HeaderSource      = "ce-source"
+	HeaderSubject     = "ce-subject"
+	HeaderTime        = "ce-time"
+	HeaderDataSchema  = "ce-dataschema"
+
+	// Prefix of all attribute headers, extensions are sent as
+	// "ce-<extension>".
+	headerPrefix = "ce-"
+)
+
+// ToJob returns e encoded as a background job.
+// TTR, TTL and the other job options are left for the caller to set.
+// Returns an error if e is not a valid event.
+// Returns ErrInvalidID if the event id is not a UUID.
+// Returns ErrInvalidType if the event type is not a valid job name.
+// Returns workq.ErrInvalidHeader if an attribute or extension can not be sent
+// as a job header, such as a value containing '\r' or '\n'.
+func ToJob(e event.Event) (*workq.BgJob, error) {
+	err := e.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = uuid.FromString(e.ID())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidID, e.ID())
+	}
+
+	if !workq.ValidName(e.Type()) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidType, e.Type())
+	}
+
+	h := workq.Headers{
+		HeaderSpecVersion: e.SpecVersion(),
+		HeaderSource:      e.Source(),
+	}
+	if e.Subject() != "" {
+		h[HeaderSubject] = e.Subject()
+	}
+	if !e.Time().IsZero() {
+		h[HeaderTime] = types.FormatTime(e.Time())
+	}
+	if e.DataSchema() != "" {
+		h[HeaderDataSchema] = e.DataSchema()
+	}
+	if e.DataContentType() != "" {
+		h[workq.HeaderContentType] = e.DataContentType()
+	}
+	for name, value := range e.Extensions() {
+		s, err := types.Format(value)
+		if err != nil {
+			return nil, fmt.Errorf("extension %s: %w", name, err)
+		}
+		h[headerPrefix+name] = s
+	}
+
+	_, err = workq.EncodeEnvelope(h, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &workq.BgJob{
+		ID:      e.ID(),
+		Name:    e.Type(),
+		Payload: e.Data(),
+		Headers: h,
+	}, nil
+}
+
+// FromJob returns the event encoded in a leased job.
+// The payload of jobs leased with Client.LeaseStream is read in full.
+// Headers without the "ce-" prefix other than "content-type" are ignored.
+// Extensions are set as strings, whatever their type when encoded.
+// Returns ErrNotEvent if the job has no "ce-specversion" header.
+// Returns an error if the decoded event is not valid.
+func FromJob(j *workq.LeasedJob) (*event.Event, error) {
+	h := j.Headers()
+	spec := h.Get(HeaderSpecVersion)
+	if spec == "" {
+		return nil, ErrNotEvent
+	}
+
+	e := event.New(spec)
+	e.SetID(j.ID)
+	e.SetType(j.Name)
+	for key, value := range h {
+		switch key {
+		case HeaderSpecVersion:
+		case HeaderSource:
+			e.SetSource(value)
+		case HeaderSubject:
+			e.SetSubject(value)
+		case HeaderTime:
+			t, err := types.ParseTime(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", HeaderTime, err)
+			}
+			e.SetTime(t)
+		case HeaderDataSchema:
+			e.SetDataSchema(value)
+		case workq.HeaderContentType:
+			e.SetDataContentType(value)
+		default:
+			if strings.HasPrefix(key, headerPrefix) {
+				e.SetExtension(key[len(headerPrefix):], value)
+			}
+		}
+	}
+
+	data, err := ioutil.ReadAll(j.PayloadReader())
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		e.DataEncoded = data
+	}
+
+	err = e.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
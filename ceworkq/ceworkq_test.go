@@ -0,0 +1,156 @@
+package ceworkq
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/cloudevents/sdk-go/v2/types"
+	"github.com/iamduo/go-workq"
+	"github.com/iamduo/go-workq/workqtest"
+)
+
+const (
+	id1 = "6ba7b810-9dad-11d1-80b4-00c04fd430c4"
+	id2 = "6ba7b811-9dad-11d1-80b4-00c04fd430c4"
+)
+
+func newEvent() event.Event {
+	e := event.New()
+	e.SetID(id1)
+	e.SetType("com.example.order.created")
+	e.SetSource("/orders")
+	e.SetSubject("order-1")
+	e.SetTime(time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC))
+	e.SetDataSchema("https://example.com/order.json")
+	e.SetExtension("tenant", "t1")
+	e.SetExtension("attempt", 3)
+	e.SetDataContentType(event.ApplicationJSON)
+	e.DataEncoded = []byte(`{"id":1}`)
+	return e
+}
+
+func TestToJob(t *testing.T) {
+	j, err := ToJob(newEvent())
+	if err != nil {
+		t.Fatalf("ToJob failed, err=%s", err)
+	}
+
+	if j.ID != id1 || j.Name != "com.example.order.created" || string(j.Payload) != `{"id":1}` {
+		t.Fatalf("Job mismatch, job=%+v", j)
+	}
+
+	exp := workq.Headers{
+		HeaderSpecVersion:       "1.0",
+		HeaderSource:            "/orders",
+		HeaderSubject:           "order-1",
+		HeaderTime:              "2016-01-02T15:04:05Z",
+		HeaderDataSchema:        "https://example.com/order.json",
+		workq.HeaderContentType: "application/json",
+		"ce-tenant":             "t1",
+		"ce-attempt":            "3",
+	}
+	if len(j.Headers) != len(exp) {
+		t.Fatalf("Headers mismatch, act=%v", j.Headers)
+	}
+	for k, v := range exp {
+		if j.Headers[k] != v {
+			t.Fatalf("Header mismatch, key=%s, act=%q", k, j.Headers[k])
+		}
+	}
+}
+
+func TestToJobErrors(t *testing.T) {
+	tests := []struct {
+		event  func(e *event.Event)
+		expErr error
+	}{
+		{func(e *event.Event) { e.SetID("order-1") }, ErrInvalidID},
+		{func(e *event.Event) { e.SetType("com example") }, ErrInvalidType},
+		{func(e *event.Event) { e.SetSource("") }, nil},
+		{func(e *event.Event) { e.SetExtension("tenant", "t1\r\nce-tenant: t2") }, workq.ErrInvalidHeader},
+	}
+
+	for _, tt := range tests {
+		e := newEvent()
+		tt.event(&e)
+		_, err := ToJob(e)
+		if err == nil || (tt.expErr != nil && !errors.Is(err, tt.expErr)) {
+			t.Fatalf("Error mismatch, err=%v, expErr=%v", err, tt.expErr)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	srv := workqtest.NewUnstartedServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	leases := map[string]func([]string, int) (*workq.LeasedJob, error){
+		id1: client.Lease,
+		id2: client.LeaseStream,
+	}
+	for id, lease := range leases {
+		e := newEvent()
+		e.SetID(id)
+		j, err := ToJob(e)
+		if err != nil {
+			t.Fatalf("ToJob failed, err=%s", err)
+		}
+		j.TTR, j.TTL = 1000, 60000
+		err = client.Add(j)
+		if err != nil {
+			t.Fatalf("Add failed, err=%s", err)
+		}
+
+		leased, err := lease([]string{"com.example.order.created"}, 0)
+		if err != nil {
+			t.Fatalf("Lease failed, err=%s", err)
+		}
+
+		act, err := FromJob(leased)
+		if err != nil {
+			t.Fatalf("FromJob failed, err=%s", err)
+		}
+		if act.String() != e.String() {
+			t.Fatalf("Event mismatch, exp=%s, act=%s", e, act)
+		}
+
+		// Typed extensions are decoded as strings.
+		attempt, err := types.ToInteger(act.Extensions()["attempt"])
+		if act.Extensions()["attempt"] != "3" || err != nil || attempt != 3 {
+			t.Fatalf("Extension mismatch, act=%#v, err=%v", act.Extensions()["attempt"], err)
+		}
+
+		err = client.Complete(leased.ID, nil)
+		if err != nil {
+			t.Fatalf("Complete failed, err=%s", err)
+		}
+	}
+}
+
+func TestFromJobErrors(t *testing.T) {
+	tests := []struct {
+		headers workq.Headers
+		expErr  error
+	}{
+		{nil, ErrNotEvent},
+		{workq.Headers{workq.HeaderContentType: "text/plain"}, ErrNotEvent},
+		{workq.Headers{HeaderSpecVersion: "1.0"}, nil},
+		{workq.Headers{HeaderSpecVersion: "1.0", HeaderSource: "/a", HeaderTime: "now"}, nil},
+		{workq.Headers{HeaderSpecVersion: "1.0", HeaderSource: "/a", "ce-Bad_Name": "a"}, nil},
+	}
+
+	for _, tt := range tests {
+		j := &workq.LeasedJob{ID: id1, Name: "a"}
+		for k, v := range tt.headers {
+			j.Headers().Set(k, v)
+		}
+
+		_, err := FromJob(j)
+		if err == nil || (tt.expErr != nil && !errors.Is(err, tt.expErr)) {
+			t.Fatalf("Error mismatch, headers=%v, err=%v", tt.headers, err)
+		}
+	}
+}
@@ -4,9 +4,12 @@ package workq
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"log/slog"
 	"net"
 	"regexp"
 	"strconv"
@@ -18,14 +21,16 @@ import (
 
 var (
 	// ErrMalformed is returned when responses from workq can not be parsed
-	// due to unrecognized responses.
+	// due to unrecognized responses. Parse errors are returned as MalformedError
+	// describing where parsing failed, matching ErrMalformed through errors.Is.
 	ErrMalformed             = errors.New("Malformed response")
 	ErrPayloadMustFollowSize = errors.New("Payload must immediately follow payload size when inspecting jobs")
 )
 
 const (
-	// Max Data Block that can be read within a response, 1 MiB.
-	maxDataBlock = 1048576
+	// Default max data block that can be sent or read within a command, 1 MiB.
+	// Matches the default limit of the workq server.
+	DefaultMaxDataBlock = 1048576
 
 	// Line terminator in string form.
 	crnl       = "\r\n"
@@ -39,95 +44,178 @@ const (
 
 // Client represents a single connection to Workq.
 type Client struct {
-	conn   net.Conn
-	rdr    *bufio.Reader
-	parser *responseParser
+	conn          net.Conn
+	rdr           *bufio.Reader
+	wrt           *bufio.Writer
+	parser        *responseParser
+	maxDataBlock  int
+	strictInspect bool
+	trace         *wireTrace
+	logger        *slog.Logger
+	logPayloads   bool
+	log           *clientLog
+	rawPayloads   bool
 }
 
 // Connect to a Workq server returning a Client
-func Connect(addr string) (*Client, error) {
+func Connect(addr string, opts ...Option) (*Client, error) {
 	conn, err := net.Dial("tcp", addr)
 	if err != nil {
+		c := &Client{}
+		for _, opt := range opts {
+			opt(c)
+		}
+		c.newLog().connect(addr, err)
 		return nil, err
 	}
 
-	return NewClient(conn), nil
+	c := NewClient(conn, opts...)
+	c.log.connect(addr, nil)
+	return c, nil
 }
 
 // NewClient returns a Client from a net.Conn.
-func NewClient(conn net.Conn) *Client {
+func NewClient(conn net.Conn, opts ...Option) *Client {
 	rdr := bufio.NewReader(conn)
-	return &Client{
-		conn:   conn,
-		rdr:    rdr,
-		parser: &responseParser{rdr: rdr},
+	c := &Client{
+		conn:         conn,
+		rdr:          rdr,
+		wrt:          bufio.NewWriter(conn),
+		maxDataBlock: DefaultMaxDataBlock,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.log = c.newLog()
+	c.parser = &responseParser{
+		rdr:          rdr,
+		maxDataBlock: c.maxDataBlock,
+		strict:       c.strictInspect,
+		trace:        c.trace,
+		log:          c.log,
+		rawPayloads:  c.rawPayloads,
+	}
+	return c
+}
+
+// Return the configured log, nil without a logger.
+func (c *Client) newLog() *clientLog {
+	if c.logger == nil {
+		return nil
 	}
+
+	return &clientLog{l: c.logger, payloads: c.logPayloads}
 }
 
 // "add" command: https://github.com/iamduo/workq/blob/master/doc/protocol.md#add
 //
-// Add background job
+// Add background job, the payload is wrapped in an envelope when the job has
+// headers.
+// Returns ErrInvalidHeader if a header can not be encoded.
 // Returns ResponseError for Workq response errors.
 // Returns NetError on any network errors.
 // Returns ErrMalformed if response can't be parsed.
 func (c *Client) Add(j *BgJob) error {
-	var flagsPad string
-	var flags []string
-	if j.Priority != 0 {
-		flags = append(flags, fmt.Sprintf("-priority=%d", j.Priority))
+	payload, err := jobPayload(j.Headers, j.Payload)
+	if err != nil {
+		return err
 	}
-	if j.MaxAttempts != 0 {
-		flags = append(flags, fmt.Sprintf("-max-attempts=%d", j.MaxAttempts))
+
+	err = c.writeCommand(addCommand(j, len(payload)), dataBlock(payload))
+	if err != nil {
+		return err
 	}
-	if j.MaxFails != 0 {
-		flags = append(flags, fmt.Sprintf("-max-fails=%d", j.MaxFails))
+
+	return c.parser.parseOk()
+}
+
+// "add" command streaming the payload from r.
+//
+// Add background job with a payload of exactly size bytes read from r.
+// j.Payload is ignored. The payload is copied directly to the connection,
+// following the envelope header when the job has headers.
+// Returns ErrInvalidHeader if a header can not be encoded.
+// Returns ResponseError for Workq response errors.
+// Returns NetError on any network errors or if r returns less than size bytes,
+// the connection must not be reused after a NetError.
+// Returns ErrMalformed if response can't be parsed.
+func (c *Client) AddFrom(j *BgJob, r io.Reader, size int) error {
+	r, size, err := jobPayloadReader(j.Headers, r, size)
+	if err != nil {
+		return err
 	}
-	if len(flags) > 0 {
-		flagsPad = " "
+
+	err = c.writeCommandFrom(addCommand(j, size), r, size)
+	if err != nil {
+		return err
 	}
-	r := []byte(fmt.Sprintf(
-		"add %s %s %d %d %d%s"+crnl+"%s"+crnl,
+
+	return c.parser.parseOk()
+}
+
+// Build "add" command line for a payload of size bytes.
+func addCommand(j *BgJob, size int) string {
+	return fmt.Sprintf(
+		"add %s %s %d %d %d%s",
 		j.ID,
 		j.Name,
 		j.TTR,
 		j.TTL,
-		len(j.Payload),
-		flagsPad+strings.Join(flags, " "),
-		j.Payload,
-	))
-	_, err := c.conn.Write(r)
-	if err != nil {
-		return NewNetError(err.Error())
+		size,
+		jobFlags(j.Priority, j.MaxAttempts, j.MaxFails),
+	)
+}
+
+// Build optional job flags, padded with a leading space when present.
+func jobFlags(priority int, maxAttempts int, maxFails int) string {
+	var flags []string
+	if priority != 0 {
+		flags = append(flags, fmt.Sprintf("-priority=%d", priority))
+	}
+	if maxAttempts != 0 {
+		flags = append(flags, fmt.Sprintf("-max-attempts=%d", maxAttempts))
+	}
+	if maxFails != 0 {
+		flags = append(flags, fmt.Sprintf("-max-fails=%d", maxFails))
+	}
+	if len(flags) == 0 {
+		return ""
 	}
 
-	return c.parser.parseOk()
+	return " " + strings.Join(flags, " ")
 }
 
 // "run" command: https://github.com/iamduo/workq/blob/master/doc/protocol.md#run
 //
-// Submit foreground job and wait for result.
+// Submit foreground job and wait for result, the payload is wrapped in an
+// envelope when the job has headers.
+// Returns ErrInvalidHeader if a header can not be encoded.
 // Returns ResponseError for Workq response errors
 // Returns NetError on any network errors.
 // Returns ErrMalformed if response can't be parsed.
 func (c *Client) Run(j *FgJob) (*JobResult, error) {
+	payload, err := jobPayload(j.Headers, j.Payload)
+	if err != nil {
+		return nil, err
+	}
+
 	var flags string
 	if j.Priority != 0 {
 		flags = fmt.Sprintf(" -priority=%d", j.Priority)
 	}
-	r := []byte(fmt.Sprintf(
-		"run %s %s %d %d %d%s"+crnl+"%s"+crnl,
+	line := fmt.Sprintf(
+		"run %s %s %d %d %d%s",
 		j.ID,
 		j.Name,
 		j.TTR,
 		j.Timeout,
-		len(j.Payload),
+		len(payload),
 		flags,
-		j.Payload,
-	))
-
-	_, err := c.conn.Write(r)
+	)
+	err = c.writeCommand(line, dataBlock(payload))
 	if err != nil {
-		return nil, NewNetError(err.Error())
+		return nil, err
 	}
 
 	count, err := c.parser.parseOkWithReply()
@@ -136,7 +224,7 @@ func (c *Client) Run(j *FgJob) (*JobResult, error) {
 	}
 
 	if count != 1 {
-		return nil, ErrMalformed
+		return nil, c.parser.malformed("reply", `"+OK 1"`)
 	}
 
 	return c.parser.readResult()
@@ -144,39 +232,31 @@ func (c *Client) Run(j *FgJob) (*JobResult, error) {
 
 // "schedule" command: https://github.com/iamduo/workq/blob/master/doc/protocol.md#schedule
 //
-// Schedule job at future UTC time.
+// Schedule job at future UTC time, the payload is wrapped in an envelope when
+// the job has headers.
+// Returns ErrInvalidHeader if a header can not be encoded.
 // Returns ResponseError for Workq response errors.
 // Returns NetError on any network errors.
 // Returns ErrMalformed if response can't be parsed.
 func (c *Client) Schedule(j *ScheduledJob) error {
-	var flagsPad string
-	var flags []string
-	if j.Priority != 0 {
-		flags = append(flags, fmt.Sprintf("-priority=%d", j.Priority))
-	}
-	if j.MaxAttempts != 0 {
-		flags = append(flags, fmt.Sprintf("-max-attempts=%d", j.MaxAttempts))
-	}
-	if j.MaxFails != 0 {
-		flags = append(flags, fmt.Sprintf("-max-fails=%d", j.MaxFails))
-	}
-	if len(flags) > 0 {
-		flagsPad = " "
+	payload, err := jobPayload(j.Headers, j.Payload)
+	if err != nil {
+		return err
 	}
-	r := []byte(fmt.Sprintf(
-		"schedule %s %s %d %d %s %d%s"+crnl+"%s"+crnl,
+
+	line := fmt.Sprintf(
+		"schedule %s %s %d %d %s %d%s",
 		j.ID,
 		j.Name,
 		j.TTR,
 		j.TTL,
 		j.Time,
-		len(j.Payload),
-		flagsPad+strings.Join(flags, " "),
-		j.Payload,
-	))
-	_, err := c.conn.Write(r)
+		len(payload),
+		jobFlags(j.Priority, j.MaxAttempts, j.MaxFails),
+	)
+	err = c.writeCommand(line, dataBlock(payload))
 	if err != nil {
-		return NewNetError(err.Error())
+		return err
 	}
 
 	return c.parser.parseOk()
@@ -189,14 +269,9 @@ func (c *Client) Schedule(j *ScheduledJob) error {
 // Returns NetError on any network errors.
 // Returns ErrMalformed if response can't be parsed.
 func (c *Client) Result(id string, timeout int) (*JobResult, error) {
-	r := []byte(fmt.Sprintf(
-		"result %s %d"+crnl,
-		id,
-		timeout,
-	))
-	_, err := c.conn.Write(r)
+	err := c.writeCommand(fmt.Sprintf("result %s %d", id, timeout), nil)
 	if err != nil {
-		return nil, NewNetError(err.Error())
+		return nil, err
 	}
 
 	count, err := c.parser.parseOkWithReply()
@@ -204,12 +279,59 @@ func (c *Client) Result(id string, timeout int) (*JobResult, error) {
 		return nil, err
 	}
 	if count != 1 {
-		return nil, ErrMalformed
+		return nil, c.parser.malformed("reply", `"+OK 1"`)
 	}
 
 	return c.parser.readResult()
 }
 
+// Max "result" commands pipelined in a single write, sized so that a batch
+// fits in the default 4 KiB read buffer of the peer.
+const maxPipelinedResults = 64
+
+// Pipeline "result <id> 0" commands for up to maxPipelinedResults ids, reading
+// their replies in order. Either results[i] or errs[i] is set for every id.
+// Returns a NetError or ErrMalformed if the connection can't be reused.
+func (c *Client) pollResults(ids []string) ([]*JobResult, []error, error) {
+	lines := make([]string, len(ids))
+	for i, id := range ids {
+		lines[i] = "result " + id + " 0"
+		c.wrt.WriteString(lines[i])
+		c.wrt.WriteString(crnl)
+		c.trace.sent(lines[i])
+	}
+
+	c.parser.cmd = "result"
+	err := c.flush()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results := make([]*JobResult, len(ids))
+	errs := make([]error, len(ids))
+	for i := range ids {
+		c.log.command("result", lines[i], nil)
+		count, err := c.parser.parseOkWithReply()
+		if _, ok := err.(*ResponseError); ok {
+			errs[i] = err
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if count != 1 {
+			return nil, nil, c.parser.malformed("reply", `"+OK 1"`)
+		}
+
+		results[i], err = c.parser.readResult()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return results, errs, nil
+}
+
 // "lease" command: https://github.com/iamduo/workq/blob/master/doc/protocol.md#lease
 //
 // Lease a job, waiting for available jobs until timeout, @see PROTOCOL_DOC
@@ -217,26 +339,53 @@ func (c *Client) Result(id string, timeout int) (*JobResult, error) {
 // Returns NetError on any network errors.
 // Returns ErrMalformed if response can't be parsed.
 func (c *Client) Lease(names []string, timeout int) (*LeasedJob, error) {
-	r := []byte(fmt.Sprintf(
-		"lease %s %d"+crnl,
+	err := c.lease(names, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.parser.readLeasedJob()
+}
+
+// "lease" command streaming the payload from the connection.
+//
+// Lease a job like Lease, but leave the payload unread on the connection.
+// LeasedJob.Payload is nil and the payload must be read through
+// LeasedJob.PayloadReader. Any unread remainder is discarded before the next
+// command on this Client, after which the reader is no longer valid.
+// Returns ResponseError for Workq response errors.
+// Returns NetError on any network errors.
+// Returns ErrMalformed if response can't be parsed.
+func (c *Client) LeaseStream(names []string, timeout int) (*LeasedJob, error) {
+	err := c.lease(names, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.parser.readLeasedJobStream()
+}
+
+// Write "lease" command and parse the reply count.
+func (c *Client) lease(names []string, timeout int) error {
+	line := fmt.Sprintf(
+		"lease %s %d",
 		strings.Join(names, " "),
 		timeout,
-	))
-
-	_, err := c.conn.Write(r)
+	)
+	err := c.writeCommand(line, nil)
 	if err != nil {
-		return nil, NewNetError(err.Error())
+		return err
 	}
 
 	count, err := c.parser.parseOkWithReply()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	if count != 1 {
-		return nil, ErrMalformed
+		return c.parser.malformed("reply", `"+OK 1"`)
 	}
 
-	return c.parser.readLeasedJob()
+	return nil
 }
 
 // "complete" command: https://github.com/iamduo/workq/blob/master/doc/protocol.md#complete
@@ -246,15 +395,9 @@ func (c *Client) Lease(names []string, timeout int) (*LeasedJob, error) {
 // Returns NetError on any network errors.
 // Returns ErrMalformed if response can't be parsed.
 func (c *Client) Complete(id string, result []byte) error {
-	r := []byte(fmt.Sprintf(
-		"complete %s %d"+crnl+"%s"+crnl,
-		id,
-		len(result),
-		result,
-	))
-	_, err := c.conn.Write(r)
+	err := c.writeCommand(fmt.Sprintf("complete %s %d", id, len(result)), dataBlock(result))
 	if err != nil {
-		return NewNetError(err.Error())
+		return err
 	}
 
 	return c.parser.parseOk()
@@ -267,15 +410,41 @@ func (c *Client) Complete(id string, result []byte) error {
 // Returns NetError on any network errors.
 // Returns ErrMalformed if response can't be parsed.
 func (c *Client) Fail(id string, result []byte) error {
-	r := []byte(fmt.Sprintf(
-		"fail %s %d"+crnl+"%s"+crnl,
-		id,
-		len(result),
-		result,
-	))
-	_, err := c.conn.Write(r)
+	err := c.writeCommand(fmt.Sprintf("fail %s %d", id, len(result)), dataBlock(result))
 	if err != nil {
-		return NewNetError(err.Error())
+		return err
+	}
+
+	return c.parser.parseOk()
+}
+
+// "complete" command streaming the result from r.
+//
+// Mark job successfully complete with a result of exactly size bytes read from r.
+// Returns ResponseError for Workq response errors.
+// Returns NetError on any network errors or if r returns less than size bytes,
+// the connection must not be reused after a NetError.
+// Returns ErrMalformed if response can't be parsed.
+func (c *Client) CompleteFrom(id string, r io.Reader, size int) error {
+	err := c.writeCommandFrom(fmt.Sprintf("complete %s %d", id, size), r, size)
+	if err != nil {
+		return err
+	}
+
+	return c.parser.parseOk()
+}
+
+// "fail" command streaming the result from r.
+//
+// Mark job as failure with a result of exactly size bytes read from r.
+// Returns ResponseError for Workq response errors.
+// Returns NetError on any network errors or if r returns less than size bytes,
+// the connection must not be reused after a NetError.
+// Returns ErrMalformed if response can't be parsed.
+func (c *Client) FailFrom(id string, r io.Reader, size int) error {
+	err := c.writeCommandFrom(fmt.Sprintf("fail %s %d", id, size), r, size)
+	if err != nil {
+		return err
 	}
 
 	return c.parser.parseOk()
@@ -288,13 +457,9 @@ func (c *Client) Fail(id string, result []byte) error {
 // Returns NetError on any network errors.
 // Returns ErrMalformed if response can't be parsed.
 func (c *Client) Delete(id string) error {
-	r := []byte(fmt.Sprintf(
-		"delete %s"+crnl,
-		id,
-	))
-	_, err := c.conn.Write(r)
+	err := c.writeCommand(fmt.Sprintf("delete %s", id), nil)
 	if err != nil {
-		return NewNetError(err.Error())
+		return err
 	}
 
 	return c.parser.parseOk()
@@ -308,15 +473,15 @@ func (c *Client) Delete(id string) error {
 // Returns ErrMalformed if response can't be parsed.
 // Returns ErrPayloadMustFollowSize if payload is not directly preceded by payload size in key value list.
 func (c *Client) InspectJobs(name string, cursorOffset int, limit int) ([]*InspectedJob, error) {
-	r := []byte(fmt.Sprintf(
-		"inspect jobs %s %d %d"+crnl,
+	line := fmt.Sprintf(
+		"inspect jobs %s %d %d",
 		name,
 		cursorOffset,
 		limit,
-	))
-	_, err := c.conn.Write(r)
+	)
+	err := c.writeCommand(line, nil)
 	if err != nil {
-		return nil, NewNetError(err.Error())
+		return nil, err
 	}
 
 	count, err := c.parser.parseOkWithReply()
@@ -327,189 +492,723 @@ func (c *Client) InspectJobs(name string, cursorOffset int, limit int) ([]*Inspe
 	return c.parser.readInspectedJobs(count)
 }
 
-type responseParser struct {
-	rdr *bufio.Reader
-}
-
-// Close client connection.
-func (c *Client) Close() error {
-	return c.conn.Close()
-}
-
-// Parse "OK\r\n" response.
-func (p *responseParser) parseOk() error {
-	line, err := p.readLine()
+// "inspect job" command: https://github.com/iamduo/workq/blob/master/doc/protocol.md#inspect-job
+//
+// Inspect a single job by ID, @see PROTOCOL_DOC
+// Returns ResponseError for Workq response errors.
+// Returns NetError on any network errors.
+// Returns ErrMalformed if response can't be parsed.
+// Returns ErrPayloadMustFollowSize if payload is not directly preceded by payload size in key value list.
+func (c *Client) InspectJob(id string) (*InspectedJob, error) {
+	err := c.writeCommand(fmt.Sprintf("inspect job %s", id), nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if len(line) < 3 {
-		return ErrMalformed
+	count, err := c.parser.parseOkWithReply()
+	if err != nil {
+		return nil, err
 	}
-
-	sign := string(line[0])
-	if sign == "+" && string(line[1:3]) == "OK" && len(line) == 3 {
-		return nil
+	if count != 1 {
+		return nil, c.parser.malformed("reply", `"+OK 1"`)
 	}
 
-	if sign != "-" {
-		return ErrMalformed
+	jobs, err := c.parser.readInspectedJobs(count)
+	if err != nil {
+		return nil, err
 	}
 
-	err, _ = p.errorFromLine(line)
-	return err
+	return jobs[0], nil
 }
 
-// Parse "OK <reply-count>\r\n" response.
-func (p *responseParser) parseOkWithReply() (int, error) {
-	line, err := p.readLine()
+// "inspect server" command: https://github.com/iamduo/workq/blob/master/doc/protocol.md#inspect-server
+//
+// Inspect server stats, @see PROTOCOL_DOC
+// Returns ResponseError for Workq response errors.
+// Returns NetError on any network errors.
+// Returns ErrMalformed if response can't be parsed.
+func (c *Client) InspectServer() (*InspectedServer, error) {
+	err := c.writeCommand("inspect server", nil)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	if len(line) < 5 {
-		return 0, ErrMalformed
+	count, err := c.parser.parseOkWithReply()
+	if err != nil {
+		return nil, err
+	}
+	if count != 1 {
+		return nil, c.parser.malformed("reply", `"+OK 1"`)
 	}
 
-	sign := string(line[0])
-	if sign == "+" && string(line[1:3]) == "OK" {
-		count, err := strconv.Atoi(string(line[4:]))
-		if err != nil {
-			return 0, ErrMalformed
-		}
+	return c.parser.readInspectedServer()
+}
 
-		return count, nil
+// "inspect queues" command: https://github.com/iamduo/workq/blob/master/doc/protocol.md#inspect-queues
+//
+// Inspect queues ordered by name, @see PROTOCOL_DOC
+// Returns ResponseError for Workq response errors.
+// Returns NetError on any network errors.
+// Returns ErrMalformed if response can't be parsed.
+func (c *Client) InspectQueues(cursorOffset int, limit int) ([]*InspectedQueue, error) {
+	line := fmt.Sprintf(
+		"inspect queues %d %d",
+		cursorOffset,
+		limit,
+	)
+	err := c.writeCommand(line, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	if sign != "-" {
-		return 0, ErrMalformed
+	count, err := c.parser.parseOkWithReply()
+	if err != nil {
+		return nil, err
 	}
 
-	err, _ = p.errorFromLine(line)
-	return 0, err
+	return c.parser.readInspectedQueues(count)
 }
 
-// Read valid line terminated by "\r\n"
-func (p *responseParser) readLine() ([]byte, error) {
-	line, err := p.rdr.ReadBytes(byte('\n'))
+// "inspect queue" command: https://github.com/iamduo/workq/blob/master/doc/protocol.md#inspect-queue
+//
+// Inspect a single queue by name, @see PROTOCOL_DOC
+// Returns ResponseError for Workq response errors.
+// Returns NetError on any network errors.
+// Returns ErrMalformed if response can't be parsed.
+func (c *Client) InspectQueue(name string) (*InspectedQueue, error) {
+	err := c.writeCommand(fmt.Sprintf("inspect queue %s", name), nil)
 	if err != nil {
-		return nil, NewNetError(err.Error())
+		return nil, err
 	}
 
-	if len(line) < termLen {
-		return nil, ErrMalformed
+	count, err := c.parser.parseOkWithReply()
+	if err != nil {
+		return nil, err
+	}
+	if count != 1 {
+		return nil, c.parser.malformed("reply", `"+OK 1"`)
 	}
 
-	if len(line) >= termLen {
-		if line[len(line)-termLen] != '\r' {
-			return nil, ErrMalformed
-		}
-
-		line = line[:len(line)-termLen]
+	queues, err := c.parser.readInspectedQueues(count)
+	if err != nil {
+		return nil, err
 	}
 
-	return line, nil
+	return queues[0], nil
 }
 
-// Read data block up to size terminated by "\r\n"
-func (p *responseParser) readBlock(size int) ([]byte, error) {
-	if size < 0 || size > maxDataBlock {
-		return nil, ErrMalformed
-	}
+// Close client connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
 
-	block := make([]byte, size)
-	n, err := io.ReadAtLeast(p.rdr, block, size)
-	if n != size || err != nil {
-		return nil, ErrMalformed
+// Write command line followed by an optional data block.
+// The block is written as is without an intermediate copy of the command.
+func (c *Client) writeCommand(line string, block []byte) error {
+	c.parser.cmd = commandName(line)
+	c.log.command(c.parser.cmd, line, block)
+	if len(block) > c.maxDataBlock {
+		return c.log.failed(NewPayloadTooLargeError(len(block), c.maxDataBlock))
 	}
 
-	b := make([]byte, termLen)
-	n, err = p.rdr.Read(b)
-	if err != nil || n != termLen || string(b) != crnl {
-		// Size does not match end of line.
-		// Trailing garbage is not allowed.
-		return nil, ErrMalformed
+	c.wrt.WriteString(line)
+	c.wrt.WriteString(crnl)
+	c.trace.sent(line)
+	if block != nil {
+		c.wrt.Write(block)
+		c.wrt.WriteString(crnl)
+		c.trace.sentBlock(block, len(block))
 	}
 
-	return block, nil
+	return c.flush()
 }
 
-// Read job result consisting of 2 separate terminated lines.
-// "<id> <success> <result-length>\r\n
-// <result-block>\r\n"
-func (p *responseParser) readResult() (*JobResult, error) {
-	line, err := p.readLine()
-	split := strings.Split(string(line), " ")
-	if len(split) != 3 {
-		return nil, ErrMalformed
-	}
-
-	if split[1] != "0" && split[1] != "1" {
-		return nil, ErrMalformed
+// Return b as a data block, commands with a data block always send one even
+// when empty.
+func dataBlock(b []byte) []byte {
+	if b == nil {
+		return []byte{}
 	}
 
-	result := &JobResult{}
-	if split[1] == "1" {
-		result.Success = true
-	}
+	return b
+}
 
-	resultLen, err := strconv.ParseUint(split[2], 10, 64)
-	if err != nil {
-		return nil, ErrMalformed
+// Write command line followed by a data block of size bytes copied from r.
+func (c *Client) writeCommandFrom(line string, r io.Reader, size int) error {
+	c.parser.cmd = commandName(line)
+	c.log.command(c.parser.cmd, line, nil)
+	if size > c.maxDataBlock {
+		return c.log.failed(NewPayloadTooLargeError(size, c.maxDataBlock))
 	}
 
-	result.Result, err = p.readBlock(int(resultLen))
+	c.wrt.WriteString(line)
+	c.wrt.WriteString(crnl)
+	c.trace.sent(line)
+	c.trace.sentBlock(nil, size)
+	n, err := io.CopyN(c.wrt, r, int64(size))
 	if err != nil {
-		return nil, err
+		if n < int64(size) && err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		// Drop partially written command so the writer can be reused.
+		c.wrt.Reset(c.conn)
+		return c.log.failed(NewNetError(err.Error()))
 	}
+	c.wrt.WriteString(crnl)
 
-	return result, nil
+	return c.flush()
 }
 
-// Read leased job consisting of 2 separate terminated lines.
-// "<id> <name> <payload-length>\r\n
-// <payload-block>\r\n"
-func (p *responseParser) readLeasedJob() (*LeasedJob, error) {
-	line, err := p.readLine()
-	split := strings.Split(string(line), " ")
-	if len(split) != 4 {
-		return nil, ErrMalformed
+// Return the command name of a command line, including the subcommand of
+// "inspect" commands.
+func commandName(line string) string {
+	fields := strings.SplitN(line, " ", 3)
+	if fields[0] == "inspect" && len(fields) > 1 {
+		return fields[0] + " " + fields[1]
 	}
 
-	j := &LeasedJob{}
-	j.ID, err = idFromString(split[0])
-	if err != nil {
-		return nil, err
-	}
+	return fields[0]
+}
 
-	j.Name, err = nameFromString(split[1])
+// Flush buffered command to the connection.
+func (c *Client) flush() error {
+	err := c.wrt.Flush()
 	if err != nil {
-		return nil, err
+		// Drop partially written command so the writer can be reused.
+		c.wrt.Reset(c.conn)
+		return c.log.failed(NewNetError(err.Error()))
 	}
 
-	ttr, err := strconv.ParseInt(split[2], 10, 64)
-	if err != nil {
-		return nil, ErrMalformed
-	}
+	return nil
+}
 
-	j.TTR = int(ttr)
+// responseParser parses responses directly from the read buffer.
+// Lines returned by readLine are only valid until the next read, values
+// that outlive a line are copied out of it.
+type responseParser struct {
+	rdr *bufio.Reader
+	// Unread streamed data block from the previous response.
+	pending *blockReader
+	// Reused buffer for lines exceeding the read buffer.
+	line []byte
+	// Reused storage for split line fields.
+	fields [][]byte
+	// Max data block size accepted within a response.
+	maxDataBlock int
+	// Reject unknown keys of inspected objects.
+	strict bool
+	// Command of the response being parsed, attached to malformed errors.
+	cmd string
+	// Copy of the last raw line read, attached to malformed errors.
+	raw []byte
+	// Optional transcript of received replies.
+	trace *wireTrace
+	// Optional log of failed commands.
+	log *clientLog
+	// Leave payload envelopes undecoded.
+	rawPayloads bool
+}
 
-	payloadLen, err := strconv.ParseUint(split[3], 10, 64)
-	if err != nil {
-		return nil, ErrMalformed
-	}
+// Return a MalformedError for the current command with the last raw line read
+// as snippet.
+func (p *responseParser) malformed(stage string, expected string) error {
+	return p.malformedBytes(stage, expected, p.raw)
+}
 
-	j.Payload, err = p.readBlock(int(payloadLen))
-	if err != nil {
-		return nil, err
+// Return a MalformedError for the current command with snippet b.
+func (p *responseParser) malformedBytes(stage string, expected string, b []byte) error {
+	return p.log.failed(NewMalformedError(p.cmd, stage, expected, b))
+}
+
+// Return up to maxMalformedSnippet already buffered bytes without consuming them.
+func (p *responseParser) peekBuffered() []byte {
+	n := p.rdr.Buffered()
+	if n > maxMalformedSnippet {
+		n = maxMalformedSnippet
 	}
 
-	return j, nil
+	b, _ := p.rdr.Peek(n)
+	return b
 }
 
-// Read inspected jobs.
-// <id> <key-count>\r\n
-// <key> <value>\r\n
+// Parse "OK\r\n" response.
+func (p *responseParser) parseOk() error {
+	line, err := p.readLine()
+	if err != nil {
+		return err
+	}
+
+	if len(line) < 3 {
+		return p.malformed("reply", `"+OK" or "-<code> [<text>]"`)
+	}
+
+	if line[0] == '+' && line[1] == 'O' && line[2] == 'K' && len(line) == 3 {
+		return nil
+	}
+
+	if line[0] != '-' {
+		return p.malformed("reply", `"+OK" or "-<code> [<text>]"`)
+	}
+
+	err, _ = p.errorFromLine(line)
+	return err
+}
+
+// Parse "OK <reply-count>\r\n" response.
+func (p *responseParser) parseOkWithReply() (int, error) {
+	line, err := p.readLine()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(line) < 5 {
+		return 0, p.malformed("reply", `"+OK <reply-count>" or "-<code> [<text>]"`)
+	}
+
+	if line[0] == '+' && line[1] == 'O' && line[2] == 'K' {
+		if line[3] != ' ' {
+			return 0, p.malformed("reply", `"+OK <reply-count>" or "-<code> [<text>]"`)
+		}
+
+		count, ok := parseUint(line[4:], 31)
+		if !ok {
+			return 0, p.malformed("reply", `"+OK <reply-count>" or "-<code> [<text>]"`)
+		}
+
+		return int(count), nil
+	}
+
+	if line[0] != '-' {
+		return 0, p.malformed("reply", `"+OK <reply-count>" or "-<code> [<text>]"`)
+	}
+
+	err, _ = p.errorFromLine(line)
+	return 0, err
+}
+
+// Parse either "OK\r\n" or "OK <reply-count>\r\n" response.
+func (p *responseParser) parseReply() (*Reply, error) {
+	line, err := p.readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) < 3 {
+		return nil, p.malformed("reply", `"+OK", "+OK <reply-count>" or "-<code> [<text>]"`)
+	}
+
+	if line[0] == '+' && line[1] == 'O' && line[2] == 'K' {
+		if len(line) == 3 {
+			return &Reply{}, nil
+		}
+
+		if line[3] != ' ' {
+			return nil, p.malformed("reply", `"+OK", "+OK <reply-count>" or "-<code> [<text>]"`)
+		}
+
+		count, ok := parseUint(line[4:], 0)
+		if !ok {
+			return nil, p.malformed("reply", `"+OK", "+OK <reply-count>" or "-<code> [<text>]"`)
+		}
+
+		return &Reply{Count: int(count), HasCount: true}, nil
+	}
+
+	if line[0] != '-' {
+		return nil, p.malformed("reply", `"+OK", "+OK <reply-count>" or "-<code> [<text>]"`)
+	}
+
+	err, _ = p.errorFromLine(line)
+	return nil, err
+}
+
+// Read valid line terminated by "\r\n"
+// The returned line is only valid until the next read.
+func (p *responseParser) readLine() ([]byte, error) {
+	return p.readLineIn("", "")
+}
+
+// Read a line at stage of a reply. The stream ending before the line truncates
+// the reply and returns a MalformedError expecting expected, other read errors
+// return a NetError. Without stage, both return a NetError.
+func (p *responseParser) readLineIn(stage string, expected string) ([]byte, error) {
+	err := p.discardPending()
+	if err != nil {
+		return nil, err
+	}
+
+	line, err := p.rdr.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		// Lines exceeding the read buffer are bounded by the max data block.
+		p.line = append(p.line[:0], line...)
+		for err == bufio.ErrBufferFull {
+			if len(p.line) > p.maxDataBlock {
+				return nil, p.malformedBytes("line", "line within max data block size", p.line)
+			}
+
+			line, err = p.rdr.ReadSlice('\n')
+			p.line = append(p.line, line...)
+		}
+		line = p.line
+	}
+
+	// Keep the raw line for malformed errors, line is overwritten by the next read.
+	p.raw = append(p.raw[:0], line...)
+	p.trace.received(line)
+	if err == io.EOF && stage != "" {
+		return nil, p.malformed(stage, expected)
+	}
+	if err != nil {
+		return nil, p.log.failed(NewNetError(err.Error()))
+	}
+
+	if len(line) < termLen {
+		return nil, p.malformed("line", `line terminated by "\r\n"`)
+	}
+
+	if line[len(line)-termLen] != '\r' {
+		return nil, p.malformed("line", `line terminated by "\r\n"`)
+	}
+
+	return line[:len(line)-termLen], nil
+}
+
+// Split line on single spaces, reusing field storage between calls.
+func (p *responseParser) split(line []byte) [][]byte {
+	p.fields = p.fields[:0]
+	for {
+		i := bytes.IndexByte(line, ' ')
+		if i < 0 {
+			break
+		}
+		p.fields = append(p.fields, line[:i])
+		line = line[i+1:]
+	}
+
+	p.fields = append(p.fields, line)
+	return p.fields
+}
+
+// Read data block up to size terminated by "\r\n"
+func (p *responseParser) readBlock(size int) ([]byte, error) {
+	if size < 0 {
+		return nil, p.malformed("data block", "non-negative size")
+	}
+
+	if size > p.maxDataBlock {
+		return nil, p.log.failed(NewPayloadTooLargeError(size, p.maxDataBlock))
+	}
+
+	block := make([]byte, size)
+	n, err := io.ReadFull(p.rdr, block)
+	if n != size || err != nil {
+		return nil, p.malformedBytes("data block", strconv.Itoa(size)+" bytes", block[:n])
+	}
+
+	if !p.readTerm() {
+		// Size does not match end of line.
+		// Trailing garbage is not allowed.
+		return nil, p.malformedBytes("data block", `"\r\n" after data block`, p.peekBuffered())
+	}
+
+	p.trace.receivedBlock(block, size)
+	return block, nil
+}
+
+// Read "\r\n" terminating a data block.
+func (p *responseParser) readTerm() bool {
+	b, err := p.rdr.Peek(termLen)
+	if err != nil || b[0] != '\r' || b[1] != '\n' {
+		return false
+	}
+
+	p.rdr.Discard(termLen)
+	return true
+}
+
+// Read job result consisting of 2 separate terminated lines.
+// "<id> <success> <result-length>\r\n
+// <result-block>\r\n"
+func (p *responseParser) readResult() (*JobResult, error) {
+	line, err := p.readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	split := p.split(line)
+	if len(split) != 3 {
+		return nil, p.malformed("result", `"<id> <success> <result-length>"`)
+	}
+
+	if len(split[1]) != 1 || (split[1][0] != '0' && split[1][0] != '1') {
+		return nil, p.malformed("result", `<success> of "0" or "1"`)
+	}
+
+	result := &JobResult{}
+	if split[1][0] == '1' {
+		result.Success = true
+	}
+
+	resultLen, ok := parseUint(split[2], 64)
+	if !ok {
+		return nil, p.malformed("result", "numeric <result-length>")
+	}
+
+	result.Result, err = p.readBlock(int(resultLen))
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Read leased job consisting of 2 separate terminated lines.
+// "<id> <name> <payload-length>\r\n
+// <payload-block>\r\n"
+func (p *responseParser) readLeasedJob() (*LeasedJob, error) {
+	j, payloadLen, err := p.readLeasedJobHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	j.Payload, err = p.readBlock(payloadLen)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.decodeEnvelope(j)
+	if err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// Read leased job header line, leaving the payload block on the connection.
+// The payload is exposed through LeasedJob.PayloadReader.
+func (p *responseParser) readLeasedJobStream() (*LeasedJob, error) {
+	j, payloadLen, err := p.readLeasedJobHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	p.trace.receivedBlock(nil, payloadLen)
+	h, head, payloadLen, err := p.readEnvelope(payloadLen)
+	p.pending = &blockReader{p: p, cmd: p.cmd, remaining: payloadLen}
+	if err != nil {
+		return nil, err
+	}
+
+	j.headers, j.payload = h, p.pending
+	if len(head) > 0 {
+		j.payload = io.MultiReader(bytes.NewReader(head), p.pending)
+	}
+	return j, nil
+}
+
+// Read leased job header line.
+// "<id> <name> <ttr> <payload-length>\r\n"
+func (p *responseParser) readLeasedJobHeader() (*LeasedJob, int, error) {
+	line, err := p.readLine()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	split := p.split(line)
+	if len(split) != 4 {
+		return nil, 0, p.malformed("leased job", `"<id> <name> <ttr> <payload-length>"`)
+	}
+
+	j := &LeasedJob{}
+	j.ID, err = idFromBytes(split[0])
+	if err != nil {
+		return nil, 0, p.malformed("leased job", "UUID <id>")
+	}
+
+	j.Name, err = nameFromBytes(split[1])
+	if err != nil {
+		return nil, 0, p.malformed("leased job", "<name> of [a-zA-Z0-9_.-]{1,128}")
+	}
+
+	ttr, ok := parseInt(split[2], 64)
+	if !ok {
+		return nil, 0, p.malformed("leased job", "numeric <ttr>")
+	}
+
+	j.TTR = int(ttr)
+
+	payloadLen, ok := parseUint(split[3], 64)
+	if !ok {
+		return nil, 0, p.malformed("leased job", "numeric <payload-length>")
+	}
+
+	if payloadLen > uint64(p.maxDataBlock) {
+		return nil, 0, p.log.failed(NewPayloadTooLargeError(int(payloadLen), p.maxDataBlock))
+	}
+
+	p.log.leased(j)
+	return j, int(payloadLen), nil
+}
+
+// Decode the envelope of a leased payload read in full, which is left as is
+// without a valid envelope.
+// Returns MalformedError if the payload starts with the envelope magic line
+// but has no empty line within maxEnvelopeHeader bytes.
+func (p *responseParser) decodeEnvelope(j *LeasedJob) error {
+	if p.rawPayloads || !bytes.HasPrefix(j.Payload, []byte(envelopeMagic)) {
+		return nil
+	}
+
+	b := j.Payload
+	if len(b) > maxEnvelopeHeader {
+		b = b[:maxEnvelopeHeader]
+	}
+	end := bytes.Index(b, []byte(crnl+crnl))
+	if end < 0 {
+		if len(j.Payload) > maxEnvelopeHeader {
+			return p.malformedEnvelope(b)
+		}
+		return nil
+	}
+
+	end += 2 * termLen
+	if h, _, ok := DecodeEnvelope(b[:end]); ok {
+		j.headers, j.Payload = h, j.Payload[end:]
+	}
+	return nil
+}
+
+// Read the envelope header at the start of a streamed data block of size
+// bytes, decoding it like decodeEnvelope. Returns its headers and the
+// remaining block size. Bytes read without finding a valid envelope are
+// returned as head, to be read before the remaining block.
+// Returns MalformedError if the block starts with the envelope magic line but
+// has no empty line within maxEnvelopeHeader bytes.
+func (p *responseParser) readEnvelope(size int) (h Headers, head []byte, remaining int, err error) {
+	if p.rawPayloads || size < len(envelopeMagic) {
+		return nil, nil, size, nil
+	}
+
+	b, err := p.rdr.Peek(len(envelopeMagic))
+	if err != nil || string(b) != envelopeMagic {
+		return nil, nil, size, nil
+	}
+
+	limit := size
+	if limit > maxEnvelopeHeader {
+		limit = maxEnvelopeHeader
+	}
+	for !bytes.HasSuffix(head, []byte(crnl+crnl)) {
+		if len(head) == limit {
+			if size > maxEnvelopeHeader {
+				return nil, nil, size - len(head), p.malformedEnvelope(head)
+			}
+			return nil, head, size - len(head), nil
+		}
+
+		c, err := p.rdr.ReadByte()
+		if err != nil {
+			return nil, nil, 0, p.log.failed(NewNetError(err.Error()))
+		}
+		head = append(head, c)
+	}
+
+	if h, _, ok := DecodeEnvelope(head); ok {
+		return h, nil, size - len(head), nil
+	}
+	return nil, head, size - len(head), nil
+}
+
+// Return a MalformedError for an envelope header exceeding maxEnvelopeHeader.
+func (p *responseParser) malformedEnvelope(b []byte) error {
+	return p.malformedBytes("envelope", "empty line ending the envelope header within 64 KiB", b)
+}
+
+// Discard the unread remainder of a streamed data block, if any.
+// Errors are returned as by reading the block, a NetError or MalformedError
+// already logged.
+func (p *responseParser) discardPending() error {
+	if p.pending == nil {
+		return nil
+	}
+
+	b := p.pending
+	p.pending = nil
+	_, err := io.Copy(ioutil.Discard, b)
+	return err
+}
+
+// blockReader reads a data block of known size directly from the connection
+// and verifies the trailing "\r\n" once the block is exhausted.
+type blockReader struct {
+	p         *responseParser
+	cmd       string // Command of the response holding the block.
+	remaining int
+	err       error
+}
+
+func (b *blockReader) Read(buf []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	if b.remaining == 0 {
+		b.err = b.readTerm()
+		return 0, b.err
+	}
+
+	if len(buf) > b.remaining {
+		buf = buf[:b.remaining]
+	}
+
+	n, err := b.p.rdr.Read(buf)
+	b.remaining -= n
+	if err == io.EOF {
+		b.err = b.malformed(strconv.Itoa(b.remaining)+" more bytes", buf[:n])
+		return n, b.err
+	}
+	if err != nil {
+		b.err = b.p.log.failed(NewNetError(err.Error()))
+		return n, b.err
+	}
+
+	if b.remaining == 0 {
+		b.err = b.readTerm()
+		if b.err != io.EOF {
+			return n, b.err
+		}
+	}
+
+	return n, nil
+}
+
+// Read trailing "\r\n" returning io.EOF on success.
+func (b *blockReader) readTerm() error {
+	for i := 0; i < termLen; i++ {
+		c, err := b.p.rdr.ReadByte()
+		if err == io.EOF {
+			return b.malformed(`"\r\n" after data block`, nil)
+		}
+		if err != nil {
+			return b.p.log.failed(NewNetError(err.Error()))
+		}
+		if c != crnl[i] {
+			return b.malformed(`"\r\n" after data block`, append([]byte{c}, b.p.peekBuffered()...))
+		}
+	}
+
+	return io.EOF
+}
+
+// Return a MalformedError for the command of the block, which may be read
+// after the parser moved on to the next command.
+func (b *blockReader) malformed(expected string, snippet []byte) error {
+	return b.p.log.failed(NewMalformedError(b.cmd, "data block", expected, snippet))
+}
+
+// Read inspected jobs.
+// <id> <key-count>\r\n
+// <key> <value>\r\n
 // ... Repeats up to <key-count>
 // ... Repeats up to <reply-count>
 func (p *responseParser) readInspectedJobs(replyCount int) ([]*InspectedJob, error) {
@@ -522,69 +1221,119 @@ func (p *responseParser) readInspectedJobs(replyCount int) ([]*InspectedJob, err
 		jobs = append(jobs, job)
 	}
 
-	// Check for unexpected trailing bytes
-	block := make([]byte, 1)
-	_, err := io.ReadAtLeast(p.rdr, block, 1)
-	if err == nil {
-		return nil, ErrMalformed
+	err := p.checkTrailing()
+	if err != nil {
+		return nil, err
 	}
 
 	return jobs, nil
 }
 
-// Parse a single job from an inspected job response.
-// <id> <key-count>\r\n
-// <key> <value>\r\n
-// ... Repeats up to <key-count>
-func (p *responseParser) parseInspectedJob() (*InspectedJob, error) {
-	line, err := p.readLine()
+// Check for unexpected trailing bytes already received after a response.
+// Only buffered bytes are checked, waiting for more would block on a live
+// connection.
+func (p *responseParser) checkTrailing() error {
+	if p.rdr.Buffered() > 0 {
+		return p.malformed("trailing bytes", "end of reply")
+	}
+
+	return nil
+}
+
+// Read the header of an inspected object.
+// <object> <key-count>\r\n
+// The returned object is only valid until the next read.
+func (p *responseParser) readInspectHeader() ([]byte, int, error) {
+	line, err := p.readLineIn("inspect header", `"<object> <key-count>"`)
 	if err != nil {
-		return nil, ErrMalformed
+		return nil, 0, err
 	}
-	split := strings.Split(string(line), " ")
+
+	split := p.split(line)
 	if len(split) != 2 {
-		return nil, ErrMalformed
+		return nil, 0, p.malformed("inspect header", `"<object> <key-count>"`)
 	}
 
-	j := &InspectedJob{}
+	keyCount, ok := parseUint(split[1], 31)
+	if !ok {
+		return nil, 0, p.malformed("inspect header", "numeric <key-count>")
+	}
+
+	return split[0], int(keyCount), nil
+}
 
-	j.ID, err = idFromString(split[0])
+// Read a single key value line of an inspected object.
+// <key> <value>\r\n
+// The returned key and value are only valid until the next read.
+func (p *responseParser) readKeyValue() ([]byte, []byte, error) {
+	line, err := p.readLineIn("key value", `"<key> <value>"`)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Values are split from the key on the first space only, so that values of
+	// unknown keys may contain spaces. Known keys reject them when parsed.
+	i := bytes.IndexByte(line, ' ')
+	if i < 0 {
+		return nil, nil, p.malformed("key value", `"<key> <value>"`)
+	}
+
+	return line[:i], line[i+1:], nil
+}
+
+// Keep an unknown key of an inspected object in extra.
+// Returns ErrMalformed in strict mode.
+func (p *responseParser) unknownKey(extra *map[string]string, key []byte, value []byte) error {
+	if p.strict {
+		return p.malformed("key "+string(key), "known key in strict mode")
+	}
+
+	if *extra == nil {
+		*extra = make(map[string]string)
+	}
+	(*extra)[string(key)] = string(value)
+	return nil
+}
+
+// Parse a single job from an inspected job response.
+// <id> <key-count>\r\n
+// <key> <value>\r\n
+// ... Repeats up to <key-count>
+func (p *responseParser) parseInspectedJob() (*InspectedJob, error) {
+	id, keyCount, err := p.readInspectHeader()
 	if err != nil {
 		return nil, err
 	}
 
-	keyCount, err := strconv.Atoi(split[1])
+	j := &InspectedJob{}
+
+	j.ID, err = idFromBytes(id)
 	if err != nil {
-		return nil, ErrMalformed
+		return nil, p.malformed("inspect header", "UUID <id>")
 	}
 
 	for k := 0; k < keyCount; k++ {
-		line, err := p.readLine()
+		key, value, err := p.readKeyValue()
 		if err != nil {
-			return nil, ErrMalformed
-		}
-
-		split := strings.Split(string(line), " ")
-		if len(split) != 2 {
-			return nil, ErrMalformed
+			return nil, err
 		}
 
-		switch split[0] {
+		switch string(key) {
 		case "name":
-			j.Name, err = nameFromString(split[1])
+			j.Name, err = nameFromBytes(value)
 			if err != nil {
-				return nil, ErrMalformed
+				return nil, p.malformed("key name", "[a-zA-Z0-9_.-]{1,128}")
 			}
 		case "ttr":
-			ttr, err := strconv.ParseUint(split[1], 10, 32)
-			if err != nil {
-				return nil, ErrMalformed
+			ttr, ok := parseUint(value, 32)
+			if !ok {
+				return nil, p.malformed("key ttr", "unsigned 32-bit integer")
 			}
 			j.TTR = int(ttr)
 		case "ttl":
-			ttl, err := strconv.ParseUint(split[1], 10, 64)
-			if err != nil {
-				return nil, ErrMalformed
+			ttl, ok := parseUint(value, 64)
+			if !ok {
+				return nil, p.malformed("key ttl", "unsigned integer")
 			}
 			j.TTL = int(ttl)
 		case "payload":
@@ -592,90 +1341,224 @@ func (p *responseParser) parseInspectedJob() (*InspectedJob, error) {
 			// Encountering it here means that the order of keys is incorrect.
 			return nil, ErrPayloadMustFollowSize
 		case "payload-size":
-			payloadSize, err := strconv.ParseUint(split[1], 10, 64)
-			if err != nil {
-				return nil, ErrMalformed
+			payloadSize, ok := parseUint(value, 64)
+			if !ok {
+				return nil, p.malformed("key payload-size", "unsigned integer")
 			}
 			// Payload line has to immediately follow payload-size line because the entire
 			// payload must be read as bytes regardless of the newlines it may contain.
-			b := make([]byte, len(payloadKey))
-			n, err := p.rdr.Read(b)
-			if err != nil || n != len(payloadKey) || string(b) != payloadKey {
+			b, err := p.rdr.Peek(len(payloadKey))
+			if err != nil || string(b) != payloadKey {
 				return nil, ErrPayloadMustFollowSize
 			}
+			p.trace.received(b)
+			p.rdr.Discard(len(payloadKey))
 			j.Payload, err = p.readBlock(int(payloadSize))
 			if err != nil {
 				return nil, err
 			}
+			if h, body, ok := DecodeEnvelope(j.Payload); ok && !p.rawPayloads {
+				j.Headers, j.Payload = h, body
+			}
 			k++ // because payload line has been processed outside of loop
 		case "max-attempts":
-			maxAttempts, err := strconv.ParseUint(split[1], 10, 8)
-			if err != nil {
-				return nil, ErrMalformed
+			maxAttempts, ok := parseUint(value, 8)
+			if !ok {
+				return nil, p.malformed("key max-attempts", "unsigned 8-bit integer")
 			}
 			j.MaxAttempts = int(maxAttempts)
 		case "attempts":
-			attempts, err := strconv.ParseUint(split[1], 10, 8)
-			if err != nil {
-				return nil, ErrMalformed
+			attempts, ok := parseUint(value, 8)
+			if !ok {
+				return nil, p.malformed("key attempts", "unsigned 8-bit integer")
 			}
 			j.Attempts = int(attempts)
 		case "max-fails":
-			maxFails, err := strconv.ParseUint(split[1], 10, 8)
-			if err != nil {
-				return nil, ErrMalformed
+			maxFails, ok := parseUint(value, 8)
+			if !ok {
+				return nil, p.malformed("key max-fails", "unsigned 8-bit integer")
 			}
 			j.MaxFails = int(maxFails)
 		case "fails":
-			fails, err := strconv.ParseUint(split[1], 10, 8)
-			if err != nil {
-				return nil, ErrMalformed
+			fails, ok := parseUint(value, 8)
+			if !ok {
+				return nil, p.malformed("key fails", "unsigned 8-bit integer")
 			}
 			j.Fails = int(fails)
 		case "priority":
-			priority, err := strconv.ParseInt(split[1], 10, 32)
-			if err != nil {
-				return nil, ErrMalformed
+			priority, ok := parseInt(value, 32)
+			if !ok {
+				return nil, p.malformed("key priority", "32-bit integer")
 			}
 			j.Priority = int(priority)
 		case "state":
-			state, err := strconv.ParseUint(split[1], 10, 8)
-			if err != nil {
-				return nil, ErrMalformed
+			state, ok := parseUint(value, 8)
+			if !ok {
+				return nil, p.malformed("key state", "unsigned 8-bit integer")
 			}
-			j.State = int(state)
+			j.State = JobState(state)
 		case "created":
 			var created time.Time
-			created, err = time.Parse(time.RFC3339, split[1])
+			created, err = time.Parse(time.RFC3339, string(value))
 			if err != nil {
-				return nil, ErrMalformed
+				return nil, p.malformed("key created", "RFC3339 time")
 			}
 			j.Created = created
 		default:
-			return nil, ErrMalformed
+			err = p.unknownKey(&j.Extra, key, value)
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
 	return j, nil
 }
 
-// Parse an error from "-CODE TEXT"
-func (p *responseParser) errorFromLine(line []byte) (error, bool) {
-	split := strings.SplitN(string(line), " ", 2)
-	if len(split[0]) <= 1 {
-		return ErrMalformed, false
+// Read inspected server.
+// server <key-count>\r\n
+// <key> <value>\r\n
+// ... Repeats up to <key-count>
+func (p *responseParser) readInspectedServer() (*InspectedServer, error) {
+	object, keyCount, err := p.readInspectHeader()
+	if err != nil {
+		return nil, err
 	}
 
-	code := split[0][1:]
-	var text string
-	if len(split) == 2 {
-		if len(split[1]) == 0 {
-			return ErrMalformed, false
+	if string(object) != "server" {
+		return nil, p.malformed("inspect header", `"server <key-count>"`)
+	}
+
+	srv := &InspectedServer{}
+	for k := 0; k < keyCount; k++ {
+		key, value, err := p.readKeyValue()
+		if err != nil {
+			return nil, err
 		}
 
-		text = split[1]
+		switch string(key) {
+		case "active-clients":
+			activeClients, ok := parseUint(value, 64)
+			if !ok {
+				return nil, p.malformed("key active-clients", "unsigned integer")
+			}
+			srv.ActiveClients = int(activeClients)
+		case "evicted-jobs":
+			evictedJobs, ok := parseUint(value, 64)
+			if !ok {
+				return nil, p.malformed("key evicted-jobs", "unsigned integer")
+			}
+			srv.EvictedJobs = int(evictedJobs)
+		case "started":
+			srv.Started, err = time.Parse(time.RFC3339, string(value))
+			if err != nil {
+				return nil, p.malformed("key started", "RFC3339 time")
+			}
+		default:
+			err = p.unknownKey(&srv.Extra, key, value)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err = p.checkTrailing()
+	if err != nil {
+		return nil, err
+	}
+
+	return srv, nil
+}
+
+// Read inspected queues.
+// <name> <key-count>\r\n
+// <key> <value>\r\n
+// ... Repeats up to <key-count>
+// ... Repeats up to <reply-count>
+func (p *responseParser) readInspectedQueues(replyCount int) ([]*InspectedQueue, error) {
+	var queues []*InspectedQueue
+	for i := 0; i < replyCount; i++ {
+		queue, err := p.parseInspectedQueue()
+		if err != nil {
+			return nil, err
+		}
+		queues = append(queues, queue)
+	}
+
+	err := p.checkTrailing()
+	if err != nil {
+		return nil, err
+	}
+
+	return queues, nil
+}
+
+// Parse a single queue from an inspected queue response.
+// <name> <key-count>\r\n
+// <key> <value>\r\n
+// ... Repeats up to <key-count>
+func (p *responseParser) parseInspectedQueue() (*InspectedQueue, error) {
+	name, keyCount, err := p.readInspectHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	q := &InspectedQueue{}
+	q.Name, err = nameFromBytes(name)
+	if err != nil {
+		return nil, p.malformed("inspect header", "<name> of [a-zA-Z0-9_.-]{1,128}")
+	}
+
+	for k := 0; k < keyCount; k++ {
+		key, value, err := p.readKeyValue()
+		if err != nil {
+			return nil, err
+		}
+
+		switch string(key) {
+		case "ready-len":
+			readyLen, ok := parseUint(value, 64)
+			if !ok {
+				return nil, p.malformed("key ready-len", "unsigned integer")
+			}
+			q.ReadyLen = int(readyLen)
+		case "scheduled-len":
+			scheduledLen, ok := parseUint(value, 64)
+			if !ok {
+				return nil, p.malformed("key scheduled-len", "unsigned integer")
+			}
+			q.ScheduledLen = int(scheduledLen)
+		case "leased-len":
+			leasedLen, ok := parseUint(value, 64)
+			if !ok {
+				return nil, p.malformed("key leased-len", "unsigned integer")
+			}
+			q.LeasedLen = int(leasedLen)
+		default:
+			err = p.unknownKey(&q.Extra, key, value)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return q, nil
+}
+
+// Parse an error from "-CODE TEXT"
+func (p *responseParser) errorFromLine(line []byte) (error, bool) {
+	code, text := line, []byte(nil)
+	if i := bytes.IndexByte(line, ' '); i >= 0 {
+		code, text = line[:i], line[i+1:]
+		if len(text) == 0 {
+			return p.malformed("error", `"-<code> [<text>]"`), false
+		}
 	}
 
-	return NewResponseError(code, text), true
+	if len(code) <= 1 {
+		return p.malformed("error", `"-<code> [<text>]"`), false
+	}
+
+	return p.log.failed(NewResponseError(string(code[1:]), string(text))), true
 }
 
 // Return a valid ID string
@@ -689,15 +1572,130 @@ func idFromString(s string) (string, error) {
 	return s, nil
 }
 
+// Return a valid ID string from b.
+// The canonical UUID form is validated in place, any other form accepted by
+// idFromString falls back to it.
+// Returns ErrMalformed if not a valid UUID.
+func idFromBytes(b []byte) (string, error) {
+	if !isCanonicalUUID(b) {
+		return idFromString(string(b))
+	}
+
+	return string(b), nil
+}
+
+// Report whether b is a UUID in canonical "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx" form.
+func isCanonicalUUID(b []byte) bool {
+	if len(b) != 36 {
+		return false
+	}
+
+	for i, c := range b {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
 var nameRe = regexp.MustCompile("^[a-zA-Z0-9_.-]*$")
 
+// ValidName reports whether name is a valid job name of 1 to 128
+// alphanumeric characters, "_", "." or "-".
+func ValidName(name string) bool {
+	l := len(name)
+	return l > 0 && l <= 128 && nameRe.MatchString(name)
+}
+
 // Return a valid name string
 // Returns ErrMalformed if name is not alphanumeric + special chars: "_", ".", "-"
 func nameFromString(name string) (string, error) {
-	l := len(name)
-	if l > 0 && l <= 128 && nameRe.MatchString(name) {
+	if ValidName(name) {
 		return name, nil
 	}
 
 	return "", ErrMalformed
 }
+
+// Return a valid name string from b.
+// Returns ErrMalformed if name is not alphanumeric + special chars: "_", ".", "-"
+func nameFromBytes(b []byte) (string, error) {
+	l := len(b)
+	if l > 0 && l <= 128 && nameRe.Match(b) {
+		return string(b), nil
+	}
+
+	return "", ErrMalformed
+}
+
+// Parse a base 10 unsigned integer fitting in bitSize (0 for int) from b
+// without allocating. Mirrors strconv.ParseUint(string(b), 10, bitSize).
+func parseUint(b []byte, bitSize int) (uint64, bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+
+	if bitSize == 0 {
+		bitSize = strconv.IntSize
+	}
+	max := uint64(1)<<uint(bitSize) - 1
+
+	var n uint64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		d := uint64(c - '0')
+		if n > (max-d)/10 {
+			return 0, false
+		}
+		n = n*10 + d
+	}
+
+	return n, true
+}
+
+// Parse a base 10 signed integer fitting in bitSize (0 for int) from b
+// without allocating. Mirrors strconv.ParseInt(string(b), 10, bitSize).
+func parseInt(b []byte, bitSize int) (int64, bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+
+	if bitSize == 0 {
+		bitSize = strconv.IntSize
+	}
+
+	neg := false
+	switch b[0] {
+	case '+':
+		b = b[1:]
+	case '-':
+		neg = true
+		b = b[1:]
+	}
+
+	n, ok := parseUint(b, 64)
+	if !ok {
+		return 0, false
+	}
+
+	cutoff := uint64(1) << uint(bitSize-1)
+	if !neg && n >= cutoff || neg && n > cutoff {
+		return 0, false
+	}
+
+	if neg {
+		return -int64(n), true
+	}
+
+	return int64(n), true
+}
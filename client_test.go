@@ -3,7 +3,14 @@ package workq
 import (
 	"bytes"
 	"errors"
+	"io"
+	"io/ioutil"
+	"log/slog"
 	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -122,6 +129,17 @@ func TestAddOptionalFlags(t *testing.T) {
 				"add 6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1 2 0 -priority=1 -max-attempts=3 -max-fails=1\r\n\r\n",
 			),
 		},
+		{
+			job: &BgJob{
+				ID:   "6ba7b810-9dad-11d1-80b4-00c04fd430c4",
+				Name: "j1",
+				TTR:  1,
+				TTL:  2,
+			},
+			expWrite: []byte(
+				"add 6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1 2 0\r\n\r\n",
+			),
+		},
 	}
 
 	for _, tt := range tests {
@@ -159,7 +177,7 @@ func TestAddErrors(t *testing.T) {
 		client := NewClient(conn)
 		j := &BgJob{}
 		err := client.Add(j)
-		if err == nil || tt.expErr == nil || err.Error() != tt.expErr.Error() {
+		if !errMatch(err, tt.expErr) {
 			t.Fatalf("Response mismatch, err=%q", err)
 		}
 	}
@@ -175,6 +193,96 @@ func TestAddBadConnError(t *testing.T) {
 	}
 }
 
+func TestAddPayloadTooLarge(t *testing.T) {
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte("+OK\r\n")),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn, WithMaxDataBlock(2))
+	j := &BgJob{
+		ID:      "6ba7b810-9dad-11d1-80b4-00c04fd430c4",
+		Name:    "j1",
+		Payload: []byte("abc"),
+	}
+	err := client.Add(j)
+	perr, ok := err.(*PayloadTooLargeError)
+	if !ok || perr.Size() != 3 || perr.Max() != 2 {
+		t.Fatalf("Error mismatch, err=%+v", err)
+	}
+
+	err = client.AddFrom(j, bytes.NewReader(j.Payload), 3)
+	if _, ok := err.(*PayloadTooLargeError); !ok {
+		t.Fatalf("Error mismatch, err=%+v", err)
+	}
+
+	if conn.wrt.Len() != 0 {
+		t.Fatalf("Write mismatch, act=%q", conn.wrt.Bytes())
+	}
+}
+
+func TestAddFrom(t *testing.T) {
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte("+OK\r\n")),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	j := &BgJob{
+		ID:       "6ba7b810-9dad-11d1-80b4-00c04fd430c4",
+		Name:     "j1",
+		TTR:      60,
+		TTL:      60000,
+		Payload:  []byte("ignored"),
+		Priority: 1,
+	}
+	err := client.AddFrom(j, bytes.NewReader([]byte("abc")), 3)
+	if err != nil {
+		t.Fatalf("Response mismatch, err=%s", err)
+	}
+
+	expWrite := []byte(
+		"add 6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 60 60000 3 -priority=1\r\nabc\r\n",
+	)
+	if !bytes.Equal(expWrite, conn.wrt.Bytes()) {
+		t.Fatalf("Write mismatch, act=%q", conn.wrt.Bytes())
+	}
+}
+
+func TestAddFromShortReader(t *testing.T) {
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte("+OK\r\n")),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	j := &BgJob{
+		ID:   "6ba7b810-9dad-11d1-80b4-00c04fd430c4",
+		Name: "j1",
+	}
+	err := client.AddFrom(j, bytes.NewReader([]byte("ab")), 3)
+	if _, ok := err.(*NetError); !ok {
+		t.Fatalf("Error mismatch, err=%+v", err)
+	}
+
+	// Partial command is not sent with the next command.
+	err = client.Complete("6ba7b810-9dad-11d1-80b4-00c04fd430c4", []byte("a"))
+	if err != nil {
+		t.Fatalf("Complete failed, err=%s", err)
+	}
+	expWrite := []byte("complete 6ba7b810-9dad-11d1-80b4-00c04fd430c4 1\r\na\r\n")
+	if !bytes.Equal(expWrite, conn.wrt.Bytes()) {
+		t.Fatalf("Write mismatch, act=%q", conn.wrt.Bytes())
+	}
+}
+
+func TestAddFromBadConnError(t *testing.T) {
+	conn := &TestBadWriteConn{}
+	client := NewClient(conn)
+	j := &BgJob{}
+	err := client.AddFrom(j, bytes.NewReader([]byte("a")), 1)
+	if _, ok := err.(*NetError); !ok {
+		t.Fatalf("Error mismatch, err=%+v", err)
+	}
+}
+
 func TestRun(t *testing.T) {
 	conn := &TestConn{
 		rdr: bytes.NewBuffer([]byte(
@@ -276,7 +384,7 @@ func TestRunErrors(t *testing.T) {
 			Payload: []byte("a"),
 		}
 		result, err := client.Run(j)
-		if result != nil || err == nil || tt.expErr == nil || err.Error() != tt.expErr.Error() {
+		if result != nil || !errMatch(err, tt.expErr) {
 			t.Fatalf("Response mismatch, result=%v, err=%q", result, err)
 		}
 
@@ -428,7 +536,7 @@ func TestScheduleErrors(t *testing.T) {
 		client := NewClient(conn)
 		j := &ScheduledJob{}
 		err := client.Schedule(j)
-		if err == nil || tt.expErr == nil || err.Error() != tt.expErr.Error() {
+		if !errMatch(err, tt.expErr) {
 			t.Fatalf("Response mismatch, err=%q", err)
 		}
 	}
@@ -514,7 +622,7 @@ func TestResultErrors(t *testing.T) {
 		}
 		client := NewClient(conn)
 		result, err := client.Result("6ba7b810-9dad-11d1-80b4-00c04fd430c4", 1000)
-		if result != nil || err == nil || tt.expErr == nil || err.Error() != tt.expErr.Error() {
+		if result != nil || !errMatch(err, tt.expErr) {
 			t.Fatalf("Response mismatch, err=%q, expErr=%q", err, tt.expErr)
 		}
 	}
@@ -572,6 +680,198 @@ func TestLease(t *testing.T) {
 	}
 }
 
+func TestLeaseMaxDataBlock(t *testing.T) {
+	resp := "+OK 1\r\n" +
+		"6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1000 3\r\n" +
+		"abc\r\n"
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte(resp)),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn, WithMaxDataBlock(2))
+	_, err := client.Lease([]string{"j1"}, 1000)
+	perr, ok := err.(*PayloadTooLargeError)
+	if !ok || perr.Size() != 3 || perr.Max() != 2 {
+		t.Fatalf("Error mismatch, err=%+v", err)
+	}
+
+	conn = &TestConn{
+		rdr: bytes.NewBuffer([]byte(resp)),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client = NewClient(conn, WithMaxDataBlock(3))
+	j, err := client.Lease([]string{"j1"}, 1000)
+	if err != nil || !bytes.Equal([]byte("abc"), j.Payload) {
+		t.Fatalf("Response mismatch, j=%+v, err=%s", j, err)
+	}
+}
+
+func TestMaxDataBlockDefault(t *testing.T) {
+	for _, size := range []int{0, -1} {
+		conn := &TestConn{
+			rdr: bytes.NewBuffer([]byte(
+				"+OK 1\r\n" +
+					"6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1000 3\r\n" +
+					"abc\r\n",
+			)),
+			wrt: bytes.NewBuffer([]byte("")),
+		}
+		client := NewClient(conn, WithMaxDataBlock(size))
+		if client.maxDataBlock != DefaultMaxDataBlock {
+			t.Fatalf("Max data block mismatch, size=%d, act=%d", size, client.maxDataBlock)
+		}
+
+		j, err := client.Lease([]string{"j1"}, 1000)
+		if err != nil || !bytes.Equal([]byte("abc"), j.Payload) {
+			t.Fatalf("Response mismatch, size=%d, j=%+v, err=%v", size, j, err)
+		}
+	}
+}
+
+func TestLeaseStream(t *testing.T) {
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte(
+			"+OK 1\r\n" +
+				"6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1000 3\r\n" +
+				"abc\r\n" +
+				"+OK\r\n",
+		)),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	j, err := client.LeaseStream([]string{"j1"}, 1000)
+	if err != nil {
+		t.Fatalf("Response mismatch, err=%s", err)
+	}
+
+	if j.ID != "6ba7b810-9dad-11d1-80b4-00c04fd430c4" || j.Name != "j1" || j.TTR != 1000 {
+		t.Fatalf("Job mismatch, job=%+v", j)
+	}
+
+	if j.Payload != nil {
+		t.Fatalf("Payload mismatch, payload=%q", j.Payload)
+	}
+
+	payload, err := ioutil.ReadAll(j.PayloadReader())
+	if err != nil || !bytes.Equal([]byte("abc"), payload) {
+		t.Fatalf("Payload mismatch, payload=%q, err=%s", payload, err)
+	}
+
+	err = client.Complete(j.ID, []byte("a"))
+	if err != nil {
+		t.Fatalf("Response mismatch, err=%s", err)
+	}
+}
+
+func TestLeaseStreamUnreadPayload(t *testing.T) {
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte(
+			"+OK 1\r\n" +
+				"6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1000 3\r\n" +
+				"abc\r\n" +
+				"+OK\r\n",
+		)),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	j, err := client.LeaseStream([]string{"j1"}, 1000)
+	if err != nil {
+		t.Fatalf("Response mismatch, err=%s", err)
+	}
+
+	b := make([]byte, 1)
+	_, err = j.PayloadReader().Read(b)
+	if err != nil || b[0] != 'a' {
+		t.Fatalf("Payload mismatch, b=%q, err=%s", b, err)
+	}
+
+	// Remainder of the payload is discarded before parsing the next response.
+	err = client.Fail(j.ID, []byte("a"))
+	if err != nil {
+		t.Fatalf("Response mismatch, err=%s", err)
+	}
+}
+
+func TestLeaseStreamMalformedPayload(t *testing.T) {
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte(
+			"+OK 1\r\n" +
+				"6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1000 3\r\n" +
+				"abcd\r\n",
+		)),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	j, err := client.LeaseStream([]string{"j1"}, 1000)
+	if err != nil {
+		t.Fatalf("Response mismatch, err=%s", err)
+	}
+
+	_, err = ioutil.ReadAll(j.PayloadReader())
+	merr, ok := err.(*MalformedError)
+	if !ok || merr.command != "lease" || string(merr.snippet) != "d\r\n" {
+		t.Fatalf("Error mismatch, err=%v", err)
+	}
+}
+
+func TestLeaseStreamTruncatedPayload(t *testing.T) {
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte(
+			"+OK 1\r\n" +
+				"6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1000 3\r\n" +
+				"ab",
+		)),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	buf := &bytes.Buffer{}
+	client := NewClient(conn, WithLogger(slog.New(slog.NewJSONHandler(buf, nil))))
+	j, err := client.LeaseStream([]string{"j1"}, 1000)
+	if err != nil {
+		t.Fatalf("Response mismatch, err=%s", err)
+	}
+
+	// The remainder is discarded by the next command.
+	err = client.Complete(j.ID, nil)
+	merr, ok := err.(*MalformedError)
+	if !ok || merr.command != "lease" || merr.stage != "data block" {
+		t.Fatalf("Error mismatch, err=%v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("malformed")) {
+		t.Fatalf("Log mismatch, act=%s", buf.Bytes())
+	}
+}
+
+func TestLeaseStreamNetError(t *testing.T) {
+	conn := &TestReadErrConn{
+		TestConn: TestConn{
+			rdr: bytes.NewBuffer([]byte(
+				"+OK 1\r\n" +
+					"6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1000 3\r\n" +
+					"ab",
+			)),
+			wrt: bytes.NewBuffer([]byte("")),
+		},
+	}
+	client := NewClient(conn)
+	j, err := client.LeaseStream([]string{"j1"}, 1000)
+	if err != nil {
+		t.Fatalf("Response mismatch, err=%s", err)
+	}
+
+	_, err = ioutil.ReadAll(j.PayloadReader())
+	if _, ok := err.(*NetError); !ok {
+		t.Fatalf("Error mismatch, err=%v", err)
+	}
+}
+
+func TestLeasedJobPayloadReader(t *testing.T) {
+	j := &LeasedJob{Payload: []byte("abc")}
+	payload, err := ioutil.ReadAll(j.PayloadReader())
+	if err != nil || !bytes.Equal([]byte("abc"), payload) {
+		t.Fatalf("Payload mismatch, payload=%q, err=%s", payload, err)
+	}
+}
+
 func TestLeaseErrors(t *testing.T) {
 	tests := []RespErrTestCase{
 		// Invalid reply-count
@@ -666,7 +966,7 @@ func TestLeaseErrors(t *testing.T) {
 		}
 		client := NewClient(conn)
 		j, err := client.Lease([]string{"j1"}, 1000)
-		if j != nil || err == nil || tt.expErr == nil || err.Error() != tt.expErr.Error() {
+		if j != nil || !errMatch(err, tt.expErr) {
 			t.Fatalf("Response mismatch, err=%q, expErr=%q", err, tt.expErr)
 		}
 	}
@@ -715,7 +1015,7 @@ func TestCompleteErrors(t *testing.T) {
 		}
 		client := NewClient(conn)
 		err := client.Complete("6ba7b810-9dad-11d1-80b4-00c04fd430c4", []byte("a"))
-		if err == nil || tt.expErr == nil || err.Error() != tt.expErr.Error() {
+		if !errMatch(err, tt.expErr) {
 			t.Fatalf("Response mismatch, err=%q, expErr=%q", err, tt.expErr)
 		}
 	}
@@ -730,6 +1030,25 @@ func TestCompleteBadConnError(t *testing.T) {
 	}
 }
 
+func TestCompleteFrom(t *testing.T) {
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte("+OK\r\n")),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	err := client.CompleteFrom("6ba7b810-9dad-11d1-80b4-00c04fd430c4", bytes.NewReader([]byte("abc")), 3)
+	if err != nil {
+		t.Fatalf("Response mismatch, err=%s", err)
+	}
+
+	expWrite := []byte(
+		"complete 6ba7b810-9dad-11d1-80b4-00c04fd430c4 3\r\nabc\r\n",
+	)
+	if !bytes.Equal(expWrite, conn.wrt.Bytes()) {
+		t.Fatalf("Write mismatch, act=%q", conn.wrt.Bytes())
+	}
+}
+
 func TestFail(t *testing.T) {
 	conn := &TestConn{
 		rdr: bytes.NewBuffer([]byte("+OK\r\n")),
@@ -760,7 +1079,7 @@ func TestFailErrors(t *testing.T) {
 		}
 		client := NewClient(conn)
 		err := client.Fail("6ba7b810-9dad-11d1-80b4-00c04fd430c4", []byte("a"))
-		if err == nil || tt.expErr == nil || err.Error() != tt.expErr.Error() {
+		if !errMatch(err, tt.expErr) {
 			t.Fatalf("Response mismatch, err=%q, expErr=%q", err, tt.expErr)
 		}
 	}
@@ -775,6 +1094,25 @@ func TestFailBadConnError(t *testing.T) {
 	}
 }
 
+func TestFailFrom(t *testing.T) {
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte("+OK\r\n")),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	err := client.FailFrom("6ba7b810-9dad-11d1-80b4-00c04fd430c4", bytes.NewReader([]byte("abc")), 3)
+	if err != nil {
+		t.Fatalf("Response mismatch, err=%s", err)
+	}
+
+	expWrite := []byte(
+		"fail 6ba7b810-9dad-11d1-80b4-00c04fd430c4 3\r\nabc\r\n",
+	)
+	if !bytes.Equal(expWrite, conn.wrt.Bytes()) {
+		t.Fatalf("Write mismatch, act=%q", conn.wrt.Bytes())
+	}
+}
+
 func TestDelete(t *testing.T) {
 	conn := &TestConn{
 		rdr: bytes.NewBuffer([]byte("+OK\r\n")),
@@ -805,7 +1143,7 @@ func TestDeleteErrors(t *testing.T) {
 		}
 		client := NewClient(conn)
 		err := client.Delete("6ba7b810-9dad-11d1-80b4-00c04fd430c4")
-		if err == nil || tt.expErr == nil || err.Error() != tt.expErr.Error() {
+		if !errMatch(err, tt.expErr) {
 			t.Fatalf("Response mismatch, err=%q, expErr=%q", err, tt.expErr)
 		}
 	}
@@ -1267,12 +1605,70 @@ func TestInspectJobsErrors(t *testing.T) {
 		}
 		client := NewClient(conn)
 		j, err := client.InspectJobs("ping", 0,10)
-		if j != nil || err == nil || tt.expErr == nil || err.Error() != tt.expErr.Error() {
+		if j != nil || !errMatch(err, tt.expErr) {
 			t.Fatalf("Response mismatch, err=%q, expErr=%q", err, tt.expErr)
 		}
 	}
 }
 
+func TestInspectJobsUnknownKeys(t *testing.T) {
+	resp := []byte(
+		"+OK 1\r\n" +
+			"6ba7b810-9dad-11d1-80b4-00c04fd430c4 5\r\n" +
+			"name ping\r\n" +
+			"future-key some value\r\n" +
+			"payload-size 4\r\n" +
+			"payload ping\r\n" +
+			"future-count 1\r\n",
+	)
+	conn := &TestConn{
+		rdr: bytes.NewBuffer(resp),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	jobs, err := client.InspectJobs("ping", 0, 10)
+	if err != nil {
+		t.Fatalf("Response mismatch, err=%s", err)
+	}
+
+	expExtra := map[string]string{"future-key": "some value", "future-count": "1"}
+	if len(jobs) != 1 || !reflect.DeepEqual(expExtra, jobs[0].Extra) {
+		t.Fatalf("Extra mismatch, jobs=%+v", jobs)
+	}
+	if jobs[0].Name != "ping" || !bytes.Equal([]byte("ping"), jobs[0].Payload) {
+		t.Fatalf("Job mismatch, job=%+v", jobs[0])
+	}
+
+	conn = &TestConn{
+		rdr: bytes.NewBuffer(resp),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client = NewClient(conn, WithStrictInspect())
+	jobs, err = client.InspectJobs("ping", 0, 10)
+	if jobs != nil || !errors.Is(err, ErrMalformed) {
+		t.Fatalf("Response mismatch, err=%v", err)
+	}
+}
+
+func TestInspectJobsUnknownKeyBeforePayload(t *testing.T) {
+	// Unknown keys may not separate payload-size from payload.
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte(
+			"+OK 1\r\n" +
+				"6ba7b810-9dad-11d1-80b4-00c04fd430c4 3\r\n" +
+				"payload-size 4\r\n" +
+				"future-key 1\r\n" +
+				"payload ping\r\n",
+		)),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	jobs, err := client.InspectJobs("ping", 0, 10)
+	if jobs != nil || err != ErrPayloadMustFollowSize {
+		t.Fatalf("Response mismatch, err=%v", err)
+	}
+}
+
 func TestInspectJobsBadConnError(t *testing.T) {
 	conn := &TestBadWriteConn{}
 	client := NewClient(conn)
@@ -1282,17 +1678,570 @@ func TestInspectJobsBadConnError(t *testing.T) {
 	}
 }
 
-type RespErrTestCase struct {
-	resp   []byte
-	expErr error
+func TestReadLongLine(t *testing.T) {
+	text := strings.Repeat("a", 10000)
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte("-CLIENT-ERROR " + text + "\r\n")),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	err := client.Delete("6ba7b810-9dad-11d1-80b4-00c04fd430c4")
+	rerr, ok := err.(*ResponseError)
+	if !ok || rerr.Code() != "CLIENT-ERROR" || rerr.Text() != text {
+		t.Fatalf("Error mismatch, err=%.40q", err)
+	}
 }
 
-func invalidCommonErrorTests() []RespErrTestCase {
-	return []RespErrTestCase{
-		{
-			resp:   []byte(""),
-			expErr: NewNetError("EOF"),
-		},
+func TestReadLineTooLong(t *testing.T) {
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte("-CLIENT-ERROR " + strings.Repeat("a", 10000) + "\r\n")),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn, WithMaxDataBlock(5000))
+	err := client.Delete("6ba7b810-9dad-11d1-80b4-00c04fd430c4")
+	if !errors.Is(err, ErrMalformed) {
+		t.Fatalf("Error mismatch, err=%.80q", err)
+	}
+}
+
+func TestParseInt(t *testing.T) {
+	tests := []string{
+		"", "0", "1", "-1", "+1", "a", "1a", " 1", "127", "128", "-128", "-129",
+		"255", "256", "2147483647", "2147483648", "-2147483648", "-2147483649",
+		"18446744073709551615", "18446744073709551616", "9223372036854775807",
+		"9223372036854775808", "-9223372036854775808", "-", "+",
+	}
+	for _, tt := range tests {
+		for _, bitSize := range []int{8, 32, 64} {
+			expU, err := strconv.ParseUint(tt, 10, bitSize)
+			u, ok := parseUint([]byte(tt), bitSize)
+			if ok != (err == nil) || (ok && u != expU) {
+				t.Fatalf("parseUint mismatch, s=%q, bitSize=%d, act=%d, exp=%d", tt, bitSize, u, expU)
+			}
+
+			expI, err := strconv.ParseInt(tt, 10, bitSize)
+			i, ok := parseInt([]byte(tt), bitSize)
+			if ok != (err == nil) || (ok && i != expI) {
+				t.Fatalf("parseInt mismatch, s=%q, bitSize=%d, act=%d, exp=%d", tt, bitSize, i, expI)
+			}
+		}
+	}
+}
+
+func TestInspectJob(t *testing.T) {
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte(
+			"+OK 1\r\n" +
+				"6ba7b810-9dad-11d1-80b4-00c04fd430c4 4\r\n" +
+				"name ping\r\n" +
+				"payload-size 4\r\n" +
+				"payload ping\r\n" +
+				"priority 10\r\n",
+		)),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	j, err := client.InspectJob("6ba7b810-9dad-11d1-80b4-00c04fd430c4")
+	if err != nil {
+		t.Fatalf("Response mismatch, err=%s", err)
+	}
+
+	if j.ID != "6ba7b810-9dad-11d1-80b4-00c04fd430c4" || j.Name != "ping" || j.Priority != 10 {
+		t.Fatalf("Job mismatch, job=%+v", j)
+	}
+	if !bytes.Equal([]byte("ping"), j.Payload) {
+		t.Fatalf("Payload mismatch")
+	}
+
+	expWrite := []byte(
+		"inspect job 6ba7b810-9dad-11d1-80b4-00c04fd430c4\r\n",
+	)
+	if !bytes.Equal(expWrite, conn.wrt.Bytes()) {
+		t.Fatalf("Write mismatch, act=%s", conn.wrt.Bytes())
+	}
+}
+
+// State numbers as sent by the workq server.
+func TestInspectJobState(t *testing.T) {
+	tests := []struct {
+		state string
+		exp   JobState
+	}{
+		{"0", JobStateNew},
+		{"1", JobStateCompleted},
+		{"2", JobStateFailed},
+		{"3", JobStatePending},
+		{"4", JobStateLeased},
+	}
+
+	for _, tt := range tests {
+		conn := &TestConn{
+			rdr: bytes.NewBuffer([]byte(
+				"+OK 1\r\n" +
+					"6ba7b810-9dad-11d1-80b4-00c04fd430c4 2\r\n" +
+					"name ping\r\n" +
+					"state " + tt.state + "\r\n",
+			)),
+			wrt: bytes.NewBuffer([]byte("")),
+		}
+		client := NewClient(conn)
+		j, err := client.InspectJob("6ba7b810-9dad-11d1-80b4-00c04fd430c4")
+		if err != nil {
+			t.Fatalf("Response mismatch, err=%s", err)
+		}
+		if j.State != tt.exp {
+			t.Fatalf("State mismatch, state=%s, act=%s, exp=%s", tt.state, j.State, tt.exp)
+		}
+	}
+}
+
+func TestInspectJobErrors(t *testing.T) {
+	tests := []RespErrTestCase{
+		// Invalid reply-count
+		{
+			resp:   []byte("+OK 2\r\n"),
+			expErr: ErrMalformed,
+		},
+		// Trailing bytes
+		{
+			resp: []byte(
+				"+OK 1\r\n" +
+					"6ba7b810-9dad-11d1-80b4-00c04fd430c4 1\r\n" +
+					"name ping\r\n" +
+					"name ping\r\n",
+			),
+			expErr: ErrMalformed,
+		},
+	}
+	tests = append(tests, invalidCommonErrorTests()...)
+
+	for _, tt := range tests {
+		conn := &TestConn{
+			rdr: bytes.NewBuffer(tt.resp),
+			wrt: bytes.NewBuffer([]byte("")),
+		}
+		client := NewClient(conn)
+		j, err := client.InspectJob("6ba7b810-9dad-11d1-80b4-00c04fd430c4")
+		if j != nil || !errMatch(err, tt.expErr) {
+			t.Fatalf("Response mismatch, err=%q, expErr=%q", err, tt.expErr)
+		}
+	}
+}
+
+func TestInspectJobBadConnError(t *testing.T) {
+	conn := &TestBadWriteConn{}
+	client := NewClient(conn)
+	_, err := client.InspectJob("6ba7b810-9dad-11d1-80b4-00c04fd430c4")
+	if _, ok := err.(*NetError); !ok {
+		t.Fatalf("Error mismatch, err=%+v", err)
+	}
+}
+
+func TestInspectServer(t *testing.T) {
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte(
+			"+OK 1\r\n" +
+				"server 3\r\n" +
+				"active-clients 2\r\n" +
+				"evicted-jobs 7\r\n" +
+				"started 2016-08-22T01:50:51Z\r\n",
+		)),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	srv, err := client.InspectServer()
+	if err != nil {
+		t.Fatalf("Response mismatch, err=%s", err)
+	}
+
+	if srv.ActiveClients != 2 {
+		t.Fatalf("ActiveClients mismatch")
+	}
+	if srv.EvictedJobs != 7 {
+		t.Fatalf("EvictedJobs mismatch")
+	}
+	timeRef := time.Date(2016, time.August, 22, 1, 50, 51, 0, time.UTC)
+	if !srv.Started.Equal(timeRef) {
+		t.Fatalf("Started mismatch: %s != %s", srv.Started, timeRef)
+	}
+
+	expWrite := []byte("inspect server\r\n")
+	if !bytes.Equal(expWrite, conn.wrt.Bytes()) {
+		t.Fatalf("Write mismatch, act=%s", conn.wrt.Bytes())
+	}
+}
+
+func TestInspectServerErrors(t *testing.T) {
+	tests := []RespErrTestCase{
+		// Invalid reply-count
+		{
+			resp:   []byte("+OK 2\r\nserver 0\r\n"),
+			expErr: ErrMalformed,
+		},
+		// Invalid object
+		{
+			resp:   []byte("+OK 1\r\nqueue 0\r\n"),
+			expErr: ErrMalformed,
+		},
+		// Invalid active-clients
+		{
+			resp:   []byte("+OK 1\r\nserver 1\r\nactive-clients -1\r\n"),
+			expErr: ErrMalformed,
+		},
+		// Invalid evicted-jobs
+		{
+			resp:   []byte("+OK 1\r\nserver 1\r\nevicted-jobs x\r\n"),
+			expErr: ErrMalformed,
+		},
+		// Invalid started
+		{
+			resp:   []byte("+OK 1\r\nserver 1\r\nstarted 2016\r\n"),
+			expErr: ErrMalformed,
+		},
+		// Malformed "<key> <value>" line
+		{
+			resp:   []byte("+OK 1\r\nserver 1\r\nactive-clients\r\n"),
+			expErr: ErrMalformed,
+		},
+	}
+	tests = append(tests, invalidCommonErrorTests()...)
+
+	for _, tt := range tests {
+		conn := &TestConn{
+			rdr: bytes.NewBuffer(tt.resp),
+			wrt: bytes.NewBuffer([]byte("")),
+		}
+		client := NewClient(conn)
+		srv, err := client.InspectServer()
+		if srv != nil || !errMatch(err, tt.expErr) {
+			t.Fatalf("Response mismatch, err=%q, expErr=%q", err, tt.expErr)
+		}
+	}
+}
+
+func TestInspectServerUnknownKeys(t *testing.T) {
+	resp := []byte("+OK 1\r\nserver 2\r\nactive-clients 1\r\nversion 0.2.0\r\n")
+	conn := &TestConn{
+		rdr: bytes.NewBuffer(resp),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	srv, err := client.InspectServer()
+	if err != nil || srv.ActiveClients != 1 || srv.Extra["version"] != "0.2.0" {
+		t.Fatalf("Response mismatch, srv=%+v, err=%v", srv, err)
+	}
+
+	conn = &TestConn{
+		rdr: bytes.NewBuffer(resp),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client = NewClient(conn, WithStrictInspect())
+	srv, err = client.InspectServer()
+	if srv != nil || !errors.Is(err, ErrMalformed) {
+		t.Fatalf("Response mismatch, err=%v", err)
+	}
+}
+
+func TestInspectServerBadConnError(t *testing.T) {
+	conn := &TestBadWriteConn{}
+	client := NewClient(conn)
+	_, err := client.InspectServer()
+	if _, ok := err.(*NetError); !ok {
+		t.Fatalf("Error mismatch, err=%+v", err)
+	}
+}
+
+func TestInspectQueues(t *testing.T) {
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte(
+			"+OK 2\r\n" +
+				"ping1 3\r\n" +
+				"ready-len 1\r\n" +
+				"scheduled-len 2\r\n" +
+				"leased-len 3\r\n" +
+				"ping2 2\r\n" +
+				"ready-len 4\r\n" +
+				"scheduled-len 5\r\n",
+		)),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	queues, err := client.InspectQueues(0, 10)
+	if err != nil {
+		t.Fatalf("Response mismatch, err=%s", err)
+	}
+
+	if len(queues) != 2 {
+		t.Fatalf("Reply count mismatch")
+	}
+	if !reflect.DeepEqual(*queues[0], InspectedQueue{Name: "ping1", ReadyLen: 1, ScheduledLen: 2, LeasedLen: 3}) {
+		t.Fatalf("Queue mismatch, queue=%+v", queues[0])
+	}
+	if !reflect.DeepEqual(*queues[1], InspectedQueue{Name: "ping2", ReadyLen: 4, ScheduledLen: 5}) {
+		t.Fatalf("Queue mismatch, queue=%+v", queues[1])
+	}
+
+	expWrite := []byte("inspect queues 0 10\r\n")
+	if !bytes.Equal(expWrite, conn.wrt.Bytes()) {
+		t.Fatalf("Write mismatch, act=%s", conn.wrt.Bytes())
+	}
+}
+
+func TestInspectQueuesErrors(t *testing.T) {
+	tests := []RespErrTestCase{
+		// Missing queue
+		{
+			resp:   []byte("+OK 1\r\n"),
+			expErr: ErrMalformed,
+		},
+		// Invalid name
+		{
+			resp:   []byte("+OK 1\r\nping* 0\r\n"),
+			expErr: ErrMalformed,
+		},
+		// Invalid key count
+		{
+			resp:   []byte("+OK 1\r\nping x\r\n"),
+			expErr: ErrMalformed,
+		},
+		// Invalid ready-len
+		{
+			resp:   []byte("+OK 1\r\nping 1\r\nready-len -1\r\n"),
+			expErr: ErrMalformed,
+		},
+		// Invalid scheduled-len
+		{
+			resp:   []byte("+OK 1\r\nping 1\r\nscheduled-len x\r\n"),
+			expErr: ErrMalformed,
+		},
+		// Invalid leased-len
+		{
+			resp:   []byte("+OK 1\r\nping 1\r\nleased-len x\r\n"),
+			expErr: ErrMalformed,
+		},
+		// Trailing bytes
+		{
+			resp:   []byte("+OK 1\r\nping 0\r\nping 0\r\n"),
+			expErr: ErrMalformed,
+		},
+	}
+	tests = append(tests, invalidCommonErrorTests()...)
+
+	for _, tt := range tests {
+		conn := &TestConn{
+			rdr: bytes.NewBuffer(tt.resp),
+			wrt: bytes.NewBuffer([]byte("")),
+		}
+		client := NewClient(conn)
+		queues, err := client.InspectQueues(0, 10)
+		if queues != nil || !errMatch(err, tt.expErr) {
+			t.Fatalf("Response mismatch, err=%q, expErr=%q", err, tt.expErr)
+		}
+	}
+}
+
+func TestInspectQueuesUnknownKeys(t *testing.T) {
+	resp := []byte("+OK 1\r\nping 2\r\nready-len 1\r\nexpired-len 3\r\n")
+	conn := &TestConn{
+		rdr: bytes.NewBuffer(resp),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	queues, err := client.InspectQueues(0, 10)
+	if err != nil || len(queues) != 1 || queues[0].ReadyLen != 1 || queues[0].Extra["expired-len"] != "3" {
+		t.Fatalf("Response mismatch, queues=%+v, err=%v", queues, err)
+	}
+
+	conn = &TestConn{
+		rdr: bytes.NewBuffer(resp),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client = NewClient(conn, WithStrictInspect())
+	queues, err = client.InspectQueues(0, 10)
+	if queues != nil || !errors.Is(err, ErrMalformed) {
+		t.Fatalf("Response mismatch, err=%v", err)
+	}
+}
+
+func TestInspectQueuesBadConnError(t *testing.T) {
+	conn := &TestBadWriteConn{}
+	client := NewClient(conn)
+	_, err := client.InspectQueues(0, 10)
+	if _, ok := err.(*NetError); !ok {
+		t.Fatalf("Error mismatch, err=%+v", err)
+	}
+}
+
+func TestInspectQueue(t *testing.T) {
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte(
+			"+OK 1\r\n" +
+				"ping 2\r\n" +
+				"ready-len 1\r\n" +
+				"scheduled-len 2\r\n",
+		)),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	q, err := client.InspectQueue("ping")
+	if err != nil {
+		t.Fatalf("Response mismatch, err=%s", err)
+	}
+
+	if !reflect.DeepEqual(*q, InspectedQueue{Name: "ping", ReadyLen: 1, ScheduledLen: 2}) {
+		t.Fatalf("Queue mismatch, queue=%+v", q)
+	}
+
+	expWrite := []byte("inspect queue ping\r\n")
+	if !bytes.Equal(expWrite, conn.wrt.Bytes()) {
+		t.Fatalf("Write mismatch, act=%s", conn.wrt.Bytes())
+	}
+}
+
+func TestInspectQueueErrors(t *testing.T) {
+	tests := []RespErrTestCase{
+		// Invalid reply-count
+		{
+			resp:   []byte("+OK 0\r\n"),
+			expErr: ErrMalformed,
+		},
+	}
+	tests = append(tests, invalidCommonErrorTests()...)
+
+	for _, tt := range tests {
+		conn := &TestConn{
+			rdr: bytes.NewBuffer(tt.resp),
+			wrt: bytes.NewBuffer([]byte("")),
+		}
+		client := NewClient(conn)
+		q, err := client.InspectQueue("ping")
+		if q != nil || !errMatch(err, tt.expErr) {
+			t.Fatalf("Response mismatch, err=%q, expErr=%q", err, tt.expErr)
+		}
+	}
+}
+
+func TestInspectQueueBadConnError(t *testing.T) {
+	conn := &TestBadWriteConn{}
+	client := NewClient(conn)
+	_, err := client.InspectQueue("ping")
+	if _, ok := err.(*NetError); !ok {
+		t.Fatalf("Error mismatch, err=%+v", err)
+	}
+}
+
+func TestValidName(t *testing.T) {
+	valid := []string{"a", "ping", "a_b.c-D9", strings.Repeat("a", 128)}
+	invalid := []string{"", "a b", "a\r\n", "a:b", strings.Repeat("a", 129)}
+	for _, name := range valid {
+		if !ValidName(name) {
+			t.Fatalf("Expected valid name %q", name)
+		}
+	}
+	for _, name := range invalid {
+		if ValidName(name) {
+			t.Fatalf("Expected invalid name %q", name)
+		}
+	}
+}
+
+func TestMalformedErrorDetails(t *testing.T) {
+	tests := []struct {
+		resp     string
+		run      func(c *Client) error
+		command  string
+		stage    string
+		expected string
+		snippet  string
+	}{
+		{
+			resp: "+OK 1\r\nbad line\r\n",
+			run: func(c *Client) error {
+				_, err := c.Lease([]string{"j1"}, 1000)
+				return err
+			},
+			command:  "lease",
+			stage:    "leased job",
+			expected: `"<id> <name> <ttr> <payload-length>"`,
+			snippet:  "bad line\r\n",
+		},
+		{
+			resp: "+OK 1\r\n6ba7b810-9dad-11d1-80b4-00c04fd430c4 1 3\r\nabcde\r\n",
+			run: func(c *Client) error {
+				_, err := c.Result("6ba7b810-9dad-11d1-80b4-00c04fd430c4", 1000)
+				return err
+			},
+			command:  "result",
+			stage:    "data block",
+			expected: `"\r\n" after data block`,
+			snippet:  "de\r\n",
+		},
+		{
+			resp: "+OK 1\r\n6ba7b810-9dad-11d1-80b4-00c04fd430c4 1\r\nttr x\r\n",
+			run: func(c *Client) error {
+				_, err := c.InspectJobs("ping", 0, 10)
+				return err
+			},
+			command:  "inspect jobs",
+			stage:    "key ttr",
+			expected: "unsigned 32-bit integer",
+			snippet:  "ttr x\r\n",
+		},
+		{
+			resp: "*OK\r\n",
+			run: func(c *Client) error {
+				return c.Delete("6ba7b810-9dad-11d1-80b4-00c04fd430c4")
+			},
+			command:  "delete",
+			stage:    "reply",
+			expected: `"+OK" or "-<code> [<text>]"`,
+			snippet:  "*OK\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		conn := &TestConn{
+			rdr: bytes.NewBuffer([]byte(tt.resp)),
+			wrt: bytes.NewBuffer([]byte("")),
+		}
+		err := tt.run(NewClient(conn))
+		var merr *MalformedError
+		if !errors.As(err, &merr) {
+			t.Fatalf("Error mismatch, err=%v", err)
+		}
+
+		if merr.Command() != tt.command || merr.Stage() != tt.stage ||
+			merr.Expected() != tt.expected || string(merr.Snippet()) != tt.snippet {
+			t.Fatalf("Error mismatch, err=%s", err)
+		}
+	}
+}
+
+// Match err against expErr, ErrMalformed is matched with errors.Is since
+// malformed errors carry the offending line.
+func errMatch(err error, expErr error) bool {
+	if err == nil || expErr == nil {
+		return false
+	}
+
+	if expErr == ErrMalformed {
+		return errors.Is(err, ErrMalformed)
+	}
+
+	return err.Error() == expErr.Error()
+}
+
+type RespErrTestCase struct {
+	resp   []byte
+	expErr error
+}
+
+func invalidCommonErrorTests() []RespErrTestCase {
+	return []RespErrTestCase{
+		{
+			resp:   []byte(""),
+			expErr: NewNetError("EOF"),
+		},
 		{
 			resp:   []byte("*OK\r\n"),
 			expErr: ErrMalformed,
@@ -1387,7 +2336,7 @@ func invalidResultErrorTests() []RespErrTestCase {
 			resp: []byte("+OK 1\r\n" +
 				"6ba7b810-9dad-11d1-80b4-00c04fd430c4 1 1048577\r\n" +
 				"a\r\n"),
-			expErr: ErrMalformed,
+			expErr: NewPayloadTooLargeError(1048577, DefaultMaxDataBlock),
 		},
 		{
 			resp: []byte("+OK 1\r\n" +
@@ -1395,6 +2344,18 @@ func invalidResultErrorTests() []RespErrTestCase {
 				"a\r\n"),
 			expErr: ErrMalformed,
 		},
+		// Reply count not separated by a space
+		{
+			resp: []byte("+OKx1\r\n" +
+				"6ba7b810-9dad-11d1-80b4-00c04fd430c4 1 1\r\n" +
+				"a\r\n"),
+			expErr: ErrMalformed,
+		},
+		// Missing result line
+		{
+			resp:   []byte("+OK 1\r\n"),
+			expErr: NewNetError("EOF"),
+		},
 	}
 }
 
@@ -1446,6 +2407,19 @@ func (a *TestAddr) String() string {
 	return ""
 }
 
+// TestReadErrConn fails reads with a timeout once its buffer is drained.
+type TestReadErrConn struct {
+	TestConn
+}
+
+func (c *TestReadErrConn) Read(b []byte) (int, error) {
+	if c.rdr.Len() == 0 {
+		return 0, &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}
+	}
+
+	return c.rdr.Read(b)
+}
+
 type TestBadWriteConn struct {
 }
 
@@ -1480,3 +2454,167 @@ func (c *TestBadWriteConn) LocalAddr() net.Addr {
 func (c *TestBadWriteConn) RemoteAddr() net.Addr {
 	return &TestAddr{}
 }
+
+func BenchmarkAdd(b *testing.B) {
+	client := NewClient(&BenchConn{resp: []byte("+OK\r\n")})
+	j := &BgJob{
+		ID:      "6ba7b810-9dad-11d1-80b4-00c04fd430c4",
+		Name:    "j1",
+		TTR:     60,
+		TTL:     60000,
+		Payload: []byte("a"),
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := client.Add(j); err != nil {
+			b.Fatalf("Response mismatch, err=%s", err)
+		}
+	}
+}
+
+func BenchmarkRun(b *testing.B) {
+	client := NewClient(&BenchConn{resp: []byte(
+		"+OK 1\r\n" +
+			"6ba7b810-9dad-11d1-80b4-00c04fd430c4 1 1\r\n" +
+			"a\r\n",
+	)})
+	j := &FgJob{
+		ID:      "6ba7b810-9dad-11d1-80b4-00c04fd430c4",
+		Name:    "j1",
+		TTR:     5000,
+		Timeout: 1000,
+		Payload: []byte("a"),
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.Run(j); err != nil {
+			b.Fatalf("Response mismatch, err=%s", err)
+		}
+	}
+}
+
+func BenchmarkSchedule(b *testing.B) {
+	client := NewClient(&BenchConn{resp: []byte("+OK\r\n")})
+	j := &ScheduledJob{
+		ID:      "6ba7b810-9dad-11d1-80b4-00c04fd430c4",
+		Name:    "j1",
+		TTR:     5000,
+		TTL:     60000,
+		Time:    "2016-01-02T15:04:05Z",
+		Payload: []byte("a"),
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := client.Schedule(j); err != nil {
+			b.Fatalf("Response mismatch, err=%s", err)
+		}
+	}
+}
+
+func BenchmarkResult(b *testing.B) {
+	client := NewClient(&BenchConn{resp: []byte(
+		"+OK 1\r\n" +
+			"6ba7b810-9dad-11d1-80b4-00c04fd430c4 1 1\r\n" +
+			"a\r\n",
+	)})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.Result("6ba7b810-9dad-11d1-80b4-00c04fd430c4", 1000); err != nil {
+			b.Fatalf("Response mismatch, err=%s", err)
+		}
+	}
+}
+
+func BenchmarkLease(b *testing.B) {
+	client := NewClient(&BenchConn{resp: []byte(
+		"+OK 1\r\n" +
+			"6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1000 1\r\n" +
+			"a\r\n",
+	)})
+	names := []string{"j1"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.Lease(names, 1000); err != nil {
+			b.Fatalf("Response mismatch, err=%s", err)
+		}
+	}
+}
+
+func BenchmarkComplete(b *testing.B) {
+	client := NewClient(&BenchConn{resp: []byte("+OK\r\n")})
+	result := []byte("a")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := client.Complete("6ba7b810-9dad-11d1-80b4-00c04fd430c4", result); err != nil {
+			b.Fatalf("Response mismatch, err=%s", err)
+		}
+	}
+}
+
+func BenchmarkFail(b *testing.B) {
+	client := NewClient(&BenchConn{resp: []byte("+OK\r\n")})
+	result := []byte("a")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := client.Fail("6ba7b810-9dad-11d1-80b4-00c04fd430c4", result); err != nil {
+			b.Fatalf("Response mismatch, err=%s", err)
+		}
+	}
+}
+
+func BenchmarkDelete(b *testing.B) {
+	client := NewClient(&BenchConn{resp: []byte("+OK\r\n")})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := client.Delete("6ba7b810-9dad-11d1-80b4-00c04fd430c4"); err != nil {
+			b.Fatalf("Response mismatch, err=%s", err)
+		}
+	}
+}
+
+func BenchmarkInspectJobs(b *testing.B) {
+	client := NewClient(&BenchConn{resp: []byte(
+		"+OK 1\r\n" +
+			"6ba7b810-9dad-11d1-80b4-00c04fd430c4 12\r\n" +
+			"name ping\r\n" +
+			"ttr 1000\r\n" +
+			"ttl 60000\r\n" +
+			"payload-size 4\r\n" +
+			"payload ping\r\n" +
+			"max-attempts 0\r\n" +
+			"attempts 0\r\n" +
+			"max-fails 0\r\n" +
+			"fails 0\r\n" +
+			"priority 0\r\n" +
+			"state 0\r\n" +
+			"created 2016-08-22T02:00:17Z\r\n",
+	)})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.InspectJobs("ping", 0, 10); err != nil {
+			b.Fatalf("Response mismatch, err=%s", err)
+		}
+	}
+}
+
+// BenchConn replays resp once for every write without allocating.
+type BenchConn struct {
+	TestConn
+	resp []byte
+	pos  int
+}
+
+func (c *BenchConn) Read(b []byte) (int, error) {
+	if c.pos >= len(c.resp) {
+		return 0, io.EOF
+	}
+
+	n := copy(b, c.resp[c.pos:])
+	c.pos += n
+	return n, nil
+}
+
+func (c *BenchConn) Write(b []byte) (int, error) {
+	c.pos = 0
+	return len(b), nil
+}
@@ -0,0 +1,23 @@
+package workq
+
+import "time"
+
+// Clock is the time source of client-side timers, such as the deadlines and
+// poll interval of a Pool. workqtest.FakeClock implements it.
+type Clock interface {
+	Now() time.Time
+	// After returns a channel receiving the time once d passed, and a
+	// function releasing the channel when it is no longer waited on.
+	After(d time.Duration) (<-chan time.Time, func())
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTimer(d)
+	return t.C, func() { t.Stop() }
+}
@@ -0,0 +1,113 @@
+package workq
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Reply is a generic reply to a command sent with Client.Do.
+// Error replies are returned as ResponseError instead.
+type Reply struct {
+	Count    int  // Reply count of a "+OK <reply-count>" reply.
+	HasCount bool // Whether the reply carried a reply count.
+}
+
+// Do sends an arbitrary command, for commands not yet supported by Client.
+//
+// The command line is cmd followed by args separated by spaces. A non nil
+// block is written as the data block of the command, its size must be given
+// within args where the command expects it. Replies following "+OK <reply-count>"
+// are left on the connection and must be read with ReadLine and ReadBlock
+// before the next command.
+//
+// The context applies to the whole command. After it is canceled or its
+// deadline passes the connection must not be reused.
+// Returns ResponseError for Workq response errors.
+// Returns NetError on any network errors.
+// Returns ErrMalformed if response can't be parsed.
+func (c *Client) Do(ctx context.Context, cmd string, args []string, block []byte) (*Reply, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	stop := c.watch(ctx)
+	reply, err := c.do(cmd, args, block)
+	stop()
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	// The connection deadline may pass before the context timer fires.
+	if deadline, ok := ctx.Deadline(); ok && err != nil && !time.Now().Before(deadline) {
+		return nil, context.DeadlineExceeded
+	}
+
+	return reply, err
+}
+
+func (c *Client) do(cmd string, args []string, block []byte) (*Reply, error) {
+	line := cmd
+	if len(args) > 0 {
+		line += " " + strings.Join(args, " ")
+	}
+
+	err := c.writeCommand(line, block)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.parser.parseReply()
+}
+
+// ReadLine reads a single reply line following a Do command, without the
+// terminating "\r\n".
+// Returns NetError on any network errors.
+// Returns ErrMalformed if the line is not terminated by "\r\n".
+func (c *Client) ReadLine() ([]byte, error) {
+	line, err := c.parser.readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(nil), line...), nil
+}
+
+// ReadBlock reads a data block of size bytes terminated by "\r\n" following a
+// Do command.
+// Returns PayloadTooLargeError if size exceeds the max data block size.
+// Returns ErrMalformed if the block is not terminated by "\r\n".
+func (c *Client) ReadBlock(size int) ([]byte, error) {
+	return c.parser.readBlock(size)
+}
+
+// Apply the context deadline and cancellation to the connection until the
+// returned stop function is called.
+func (c *Client) watch(ctx context.Context) func() {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetDeadline(deadline)
+	}
+
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			// Unblock any pending read or write.
+			c.conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-exited
+		c.conn.SetDeadline(time.Time{})
+	}
+}
@@ -0,0 +1,140 @@
+package workq
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDo(t *testing.T) {
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte("+OK\r\n")),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	reply, err := client.Do(context.Background(), "touch", []string{"6ba7b810-9dad-11d1-80b4-00c04fd430c4", "1"}, []byte("a"))
+	if err != nil {
+		t.Fatalf("Response mismatch, err=%s", err)
+	}
+
+	if reply.HasCount || reply.Count != 0 {
+		t.Fatalf("Reply mismatch, reply=%+v", reply)
+	}
+
+	expWrite := []byte("touch 6ba7b810-9dad-11d1-80b4-00c04fd430c4 1\r\na\r\n")
+	if !bytes.Equal(expWrite, conn.wrt.Bytes()) {
+		t.Fatalf("Write mismatch, act=%q", conn.wrt.Bytes())
+	}
+}
+
+func TestDoWithReply(t *testing.T) {
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte(
+			"+OK 1\r\n" +
+				"6ba7b810-9dad-11d1-80b4-00c04fd430c4 3\r\n" +
+				"abc\r\n",
+		)),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	reply, err := client.Do(context.Background(), "peek", nil, nil)
+	if err != nil {
+		t.Fatalf("Response mismatch, err=%s", err)
+	}
+
+	if !reply.HasCount || reply.Count != 1 {
+		t.Fatalf("Reply mismatch, reply=%+v", reply)
+	}
+
+	line, err := client.ReadLine()
+	if err != nil || string(line) != "6ba7b810-9dad-11d1-80b4-00c04fd430c4 3" {
+		t.Fatalf("Line mismatch, line=%q, err=%v", line, err)
+	}
+
+	block, err := client.ReadBlock(3)
+	if err != nil || string(block) != "abc" {
+		t.Fatalf("Block mismatch, block=%q, err=%v", block, err)
+	}
+
+	if !bytes.Equal([]byte("peek\r\n"), conn.wrt.Bytes()) {
+		t.Fatalf("Write mismatch, act=%q", conn.wrt.Bytes())
+	}
+}
+
+func TestDoErrors(t *testing.T) {
+	tests := []RespErrTestCase{
+		{
+			resp:   []byte("+OKx1\r\n"),
+			expErr: ErrMalformed,
+		},
+		{
+			resp:   []byte("+OK x\r\n"),
+			expErr: ErrMalformed,
+		},
+		{
+			resp:   []byte("+OK -1\r\n"),
+			expErr: ErrMalformed,
+		},
+	}
+	tests = append(tests, invalidCommonErrorTests()...)
+
+	for _, tt := range tests {
+		conn := &TestConn{
+			rdr: bytes.NewBuffer(tt.resp),
+			wrt: bytes.NewBuffer([]byte("")),
+		}
+		client := NewClient(conn)
+		reply, err := client.Do(context.Background(), "peek", nil, nil)
+		if reply != nil || !errMatch(err, tt.expErr) {
+			t.Fatalf("Response mismatch, resp=%q, err=%q, expErr=%q", tt.resp, err, tt.expErr)
+		}
+	}
+}
+
+func TestDoBadConnError(t *testing.T) {
+	conn := &TestBadWriteConn{}
+	client := NewClient(conn)
+	_, err := client.Do(context.Background(), "peek", nil, nil)
+	if _, ok := err.(*NetError); !ok {
+		t.Fatalf("Error mismatch, err=%+v", err)
+	}
+}
+
+func TestDoContextCanceled(t *testing.T) {
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte("+OK\r\n")),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := client.Do(ctx, "peek", nil, nil)
+	if err != context.Canceled {
+		t.Fatalf("Error mismatch, err=%v", err)
+	}
+
+	if conn.wrt.Len() != 0 {
+		t.Fatalf("Write mismatch, act=%q", conn.wrt.Bytes())
+	}
+}
+
+func TestDoContextDeadline(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	go func() {
+		// Read the command and never reply.
+		b := make([]byte, 64)
+		serverConn.Read(b)
+	}()
+
+	client := NewClient(clientConn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := client.Do(ctx, "peek", nil, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Error mismatch, err=%v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package workq
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidHeader is returned when a job header can not be encoded in an
+// envelope.
+var ErrInvalidHeader = errors.New("Invalid header")
+
+// Payloads of jobs with headers are sent in a versioned envelope of
+// "<key>: <value>\r\n" header lines followed by an empty line and the body.
+//
+//	workq-envelope/1\r\n
+//	content-type: application/json\r\n
+//	traceparent: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01\r\n
+//	\r\n
+//	<body>
+const envelopeMagic = "workq-envelope/1\r\n"
+
+// Max size of an envelope header, from the magic line to the empty line.
+// Leased payloads starting with the magic line without an empty line within
+// it are rejected, so that streamed leases decode envelopes like Lease.
+const maxEnvelopeHeader = 65536
+
+// Standard header keys, keys are lowercase by convention.
+const (
+	HeaderContentType   = "content-type"
+	HeaderProducer      = "producer"
+	HeaderEnqueuedAt    = "enqueued-at" // RFC 3339 time the job was added.
+	HeaderSchemaVersion = "schema-version"
+)
+
+// Headers are metadata carried alongside a job payload.
+type Headers map[string]string
+
+// Get returns the value of key, empty if not set.
+func (h Headers) Get(key string) string {
+	return h[key]
+}
+
+// Set key to value.
+func (h Headers) Set(key string, value string) {
+	h[key] = value
+}
+
+// Clone returns a copy of h, nil if h is nil.
+func (h Headers) Clone() Headers {
+	if h == nil {
+		return nil
+	}
+
+	c := make(Headers, len(h))
+	for k, v := range h {
+		c[k] = v
+	}
+
+	return c
+}
+
+// EncodeEnvelope returns body wrapped in an envelope carrying h.
+// Header lines are sorted by key.
+// Returns ErrInvalidHeader if a key is empty or contains ':', ' ', '\r' or
+// '\n', a value contains '\r' or '\n', or the header lines exceed 64 KiB.
+func EncodeEnvelope(h Headers, body []byte) ([]byte, error) {
+	keys := make([]string, 0, len(h))
+	size := len(envelopeMagic) + termLen
+	for k, v := range h {
+		if k == "" || strings.ContainsAny(k, ": \r\n") || strings.ContainsAny(v, "\r\n") {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidHeader, k)
+		}
+		keys = append(keys, k)
+		size += len(k) + len(v) + 2 + termLen
+	}
+	if size > maxEnvelopeHeader {
+		return nil, fmt.Errorf("%w: header exceeds %d bytes", ErrInvalidHeader, maxEnvelopeHeader)
+	}
+	sort.Strings(keys)
+
+	buf := bytes.NewBufferString(envelopeMagic)
+	for _, k := range keys {
+		buf.WriteString(k)
+		buf.WriteString(": ")
+		buf.WriteString(h[k])
+		buf.WriteString(crnl)
+	}
+	buf.WriteString(crnl)
+	buf.Write(body)
+	return buf.Bytes(), nil
+}
+
+// DecodeEnvelope returns the headers and body of an envelope.
+// Payloads without a valid envelope are returned as is with ok false.
+func DecodeEnvelope(payload []byte) (h Headers, body []byte, ok bool) {
+	if !bytes.HasPrefix(payload, []byte(envelopeMagic)) {
+		return nil, payload, false
+	}
+
+	h = Headers{}
+	rest := payload[len(envelopeMagic):]
+	for {
+		i := bytes.Index(rest, []byte(crnl))
+		if i < 0 {
+			return nil, payload, false
+		}
+
+		line := rest[:i]
+		rest = rest[i+termLen:]
+		if len(line) == 0 {
+			return h, rest, true
+		}
+
+		sep := bytes.Index(line, []byte(": "))
+		if sep <= 0 {
+			return nil, payload, false
+		}
+		h[string(line[:sep])] = string(line[sep+2:])
+	}
+}
+
+// Return the payload of a job, wrapped in an envelope when it has headers.
+func jobPayload(h Headers, payload []byte) ([]byte, error) {
+	if len(h) == 0 {
+		return payload, nil
+	}
+
+	return EncodeEnvelope(h, payload)
+}
+
+// Return a reader over the payload of a job of size bytes read from r,
+// prefixed with an envelope when it has headers, and its total size.
+func jobPayloadReader(h Headers, r io.Reader, size int) (io.Reader, int, error) {
+	if len(h) == 0 {
+		return r, size, nil
+	}
+
+	head, err := EncodeEnvelope(h, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return io.MultiReader(bytes.NewReader(head), r), len(head) + size, nil
+}
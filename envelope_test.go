@@ -0,0 +1,283 @@
+package workq
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+const testEnvelope = envelopeMagic + "content-type: text/plain\r\nproducer: p1\r\n\r\nabc"
+
+func TestEnvelope(t *testing.T) {
+	h := Headers{HeaderProducer: "p1", HeaderContentType: "text/plain"}
+	payload, err := EncodeEnvelope(h, []byte("abc"))
+	if err != nil {
+		t.Fatalf("Encode failed, err=%s", err)
+	}
+	if string(payload) != testEnvelope {
+		t.Fatalf("Envelope mismatch, act=%q", payload)
+	}
+
+	act, body, ok := DecodeEnvelope(payload)
+	if !ok || string(body) != "abc" || len(act) != 2 || act.Get(HeaderProducer) != "p1" {
+		t.Fatalf("Decode mismatch, headers=%v, body=%q, ok=%v", act, body, ok)
+	}
+
+	// Body may contain envelope terminators.
+	payload, _ = EncodeEnvelope(Headers{"a": ""}, []byte("\r\n\r\nb"))
+	act, body, ok = DecodeEnvelope(payload)
+	if !ok || string(body) != "\r\n\r\nb" || act["a"] != "" || len(act) != 1 {
+		t.Fatalf("Decode mismatch, headers=%v, body=%q, ok=%v", act, body, ok)
+	}
+
+	for _, bad := range []string{
+		"",
+		"abc",
+		envelopeMagic + "a",
+		envelopeMagic + "a\r\n\r\n",
+		envelopeMagic + ": a\r\n\r\n",
+		"workq-envelope/2\r\n\r\nabc",
+	} {
+		_, body, ok := DecodeEnvelope([]byte(bad))
+		if ok || string(body) != bad {
+			t.Fatalf("Unexpected decode of %q", bad)
+		}
+	}
+}
+
+func TestEnvelopeInvalidHeader(t *testing.T) {
+	tests := []Headers{
+		{"": "a"},
+		{"a:b": "a"},
+		{"a b": "a"},
+		{"a\r\n": "a"},
+		{"a": "b\r\nc: d"},
+		{"a": "b\n"},
+		{"a": strings.Repeat("b", maxEnvelopeHeader)},
+	}
+
+	for _, h := range tests {
+		_, err := EncodeEnvelope(h, nil)
+		if !errors.Is(err, ErrInvalidHeader) {
+			t.Fatalf("Error mismatch, headers=%v, err=%v", h, err)
+		}
+	}
+
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte("")),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	err := client.Add(&BgJob{ID: "6ba7b810-9dad-11d1-80b4-00c04fd430c4", Name: "j1", Headers: Headers{"a": "\n"}})
+	if !errors.Is(err, ErrInvalidHeader) || conn.wrt.Len() != 0 {
+		t.Fatalf("Error mismatch, err=%v, written=%q", err, conn.wrt.Bytes())
+	}
+}
+
+func TestHeadersClone(t *testing.T) {
+	if Headers(nil).Clone() != nil {
+		t.Fatal("Clone of nil mismatch")
+	}
+
+	h := Headers{"a": "1"}
+	c := h.Clone()
+	c.Set("a", "2")
+	if h.Get("a") != "1" || c.Get("a") != "2" {
+		t.Fatalf("Clone mismatch, h=%v, clone=%v", h, c)
+	}
+}
+
+func TestAddWithHeaders(t *testing.T) {
+	h := Headers{HeaderProducer: "p1", HeaderContentType: "text/plain"}
+	tests := []struct {
+		send func(c *Client) error
+		exp  string
+	}{
+		{
+			send: func(c *Client) error {
+				return c.Add(&BgJob{ID: "6ba7b810-9dad-11d1-80b4-00c04fd430c4", Name: "j1", TTR: 1, TTL: 2, Payload: []byte("abc"), Headers: h})
+			},
+			exp: fmt.Sprintf("add 6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1 2 %d\r\n%s\r\n", len(testEnvelope), testEnvelope),
+		},
+		{
+			send: func(c *Client) error {
+				j := &BgJob{ID: "6ba7b810-9dad-11d1-80b4-00c04fd430c4", Name: "j1", TTR: 1, TTL: 2, Headers: h}
+				return c.AddFrom(j, bytes.NewReader([]byte("abc")), 3)
+			},
+			exp: fmt.Sprintf("add 6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1 2 %d\r\n%s\r\n", len(testEnvelope), testEnvelope),
+		},
+		{
+			send: func(c *Client) error {
+				return c.Schedule(&ScheduledJob{ID: "6ba7b810-9dad-11d1-80b4-00c04fd430c4", Name: "j1", TTR: 1, TTL: 2, Time: "2016-01-02T15:04:05Z", Payload: []byte("abc"), Headers: h})
+			},
+			exp: fmt.Sprintf("schedule 6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1 2 2016-01-02T15:04:05Z %d\r\n%s\r\n", len(testEnvelope), testEnvelope),
+		},
+		{
+			send: func(c *Client) error {
+				return c.Add(&BgJob{ID: "6ba7b810-9dad-11d1-80b4-00c04fd430c4", Name: "j1", TTR: 1, TTL: 2, Payload: []byte("abc"), Headers: Headers{}})
+			},
+			exp: "add 6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1 2 3\r\nabc\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		conn := &TestConn{
+			rdr: bytes.NewBuffer([]byte("+OK\r\n")),
+			wrt: bytes.NewBuffer([]byte("")),
+		}
+		err := tt.send(NewClient(conn))
+		if err != nil {
+			t.Fatalf("Response mismatch, err=%s", err)
+		}
+		if conn.wrt.String() != tt.exp {
+			t.Fatalf("Write mismatch, exp=%q, act=%q", tt.exp, conn.wrt.String())
+		}
+	}
+}
+
+func TestRunWithHeaders(t *testing.T) {
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte("+OK 1\r\n6ba7b810-9dad-11d1-80b4-00c04fd430c4 1 1\r\na\r\n")),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	_, err := client.Run(&FgJob{
+		ID:      "6ba7b810-9dad-11d1-80b4-00c04fd430c4",
+		Name:    "j1",
+		TTR:     1,
+		Timeout: 2,
+		Payload: []byte("abc"),
+		Headers: Headers{HeaderProducer: "p1", HeaderContentType: "text/plain"},
+	})
+	if err != nil {
+		t.Fatalf("Response mismatch, err=%s", err)
+	}
+
+	exp := fmt.Sprintf("run 6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1 2 %d\r\n%s\r\n", len(testEnvelope), testEnvelope)
+	if conn.wrt.String() != exp {
+		t.Fatalf("Write mismatch, act=%q", conn.wrt.String())
+	}
+}
+
+func leaseEnvelopeResp(payload string) string {
+	return fmt.Sprintf("+OK 1\r\n6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1000 %d\r\n%s\r\n+OK\r\n", len(payload), payload)
+}
+
+func TestLeaseWithHeaders(t *testing.T) {
+	longHeader := strings.Repeat("a", 8192)
+	longEnvelope := envelopeMagic + "content-type: text/plain\r\nproducer: " + longHeader + "\r\n\r\nabc"
+	unterminated := envelopeMagic + strings.Repeat("a", maxEnvelopeHeader)
+	tests := []struct {
+		payload string
+		opts    []Option
+		body    string
+		headers int
+		err     bool
+	}{
+		{payload: testEnvelope, body: "abc", headers: 2},
+		{payload: "abc", body: "abc"},
+		// Envelope header exceeding the read buffer.
+		{payload: longEnvelope, body: "abc", headers: 2},
+		// Invalid envelopes are returned as is.
+		{payload: envelopeMagic + "abc", body: envelopeMagic + "abc"},
+		{payload: envelopeMagic + "a\r\n\r\nabc", body: envelopeMagic + "a\r\n\r\nabc"},
+		// Envelope header not ending within the max size.
+		{payload: unterminated, err: true},
+		{payload: testEnvelope, opts: []Option{WithRawPayloads()}, body: testEnvelope},
+	}
+
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			conn := &TestConn{
+				rdr: bytes.NewBuffer([]byte(leaseEnvelopeResp(tt.payload))),
+				wrt: bytes.NewBuffer([]byte("")),
+			}
+			client := NewClient(conn, tt.opts...)
+			lease := client.Lease
+			if stream {
+				lease = client.LeaseStream
+			}
+			j, err := lease([]string{"j1"}, 1000)
+			if tt.err {
+				var merr *MalformedError
+				if !errors.As(err, &merr) || merr.Stage() != "envelope" {
+					t.Fatalf("Error mismatch, stream=%v, err=%v", stream, err)
+				}
+
+				err = client.Complete("6ba7b810-9dad-11d1-80b4-00c04fd430c4", nil)
+				if err != nil {
+					t.Fatalf("Response mismatch, stream=%v, err=%s", stream, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("Response mismatch, err=%s", err)
+			}
+
+			body, err := ioutil.ReadAll(j.PayloadReader())
+			if err != nil || string(body) != tt.body {
+				t.Fatalf("Payload mismatch, stream=%v, act=%q, err=%v", stream, body, err)
+			}
+			if len(j.Headers()) != tt.headers {
+				t.Fatalf("Headers mismatch, stream=%v, act=%v", stream, j.Headers())
+			}
+			if tt.headers > 0 && (j.Headers().Get(HeaderContentType) != "text/plain" || len(j.Headers().Get(HeaderProducer)) == 0) {
+				t.Fatalf("Headers mismatch, stream=%v, act=%v", stream, j.Headers())
+			}
+
+			// The next response is read from the end of the payload.
+			err = client.Complete(j.ID, nil)
+			if err != nil {
+				t.Fatalf("Response mismatch, stream=%v, err=%s", stream, err)
+			}
+		}
+	}
+}
+
+func TestLeasedJobHeaders(t *testing.T) {
+	j := &LeasedJob{}
+	j.Headers().Set("a", "1")
+	if j.Headers().Get("a") != "1" {
+		t.Fatalf("Headers mismatch, act=%v", j.Headers())
+	}
+}
+
+func TestInspectJobWithHeaders(t *testing.T) {
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte(fmt.Sprintf(
+			"+OK 1\r\n"+
+				"6ba7b810-9dad-11d1-80b4-00c04fd430c4 2\r\n"+
+				"payload-size %d\r\n"+
+				"payload %s\r\n",
+			len(testEnvelope),
+			testEnvelope,
+		))),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	client := NewClient(conn)
+	j, err := client.InspectJob("6ba7b810-9dad-11d1-80b4-00c04fd430c4")
+	if err != nil {
+		t.Fatalf("Response mismatch, err=%s", err)
+	}
+
+	if string(j.Payload) != "abc" || j.Headers.Get(HeaderProducer) != "p1" {
+		t.Fatalf("Job mismatch, payload=%q, headers=%v", j.Payload, j.Headers)
+	}
+
+	conn.rdr.Reset()
+	fmt.Fprintf(conn.rdr, "+OK 1\r\n"+
+		"6ba7b810-9dad-11d1-80b4-00c04fd430c4 2\r\n"+
+		"payload-size %d\r\n"+
+		"payload %s\r\n",
+		len(testEnvelope),
+		testEnvelope,
+	)
+	client = NewClient(conn, WithRawPayloads())
+	j, err = client.InspectJob("6ba7b810-9dad-11d1-80b4-00c04fd430c4")
+	if err != nil || string(j.Payload) != testEnvelope || len(j.Headers) != 0 {
+		t.Fatalf("Job mismatch, job=%+v, err=%v", j, err)
+	}
+}
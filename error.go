@@ -1,5 +1,13 @@
 package workq
 
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrPayloadTooLarge is matched by PayloadTooLargeError through errors.Is.
+var ErrPayloadTooLarge = errors.New("Payload too large")
+
 type ResponseError struct {
 	code string
 	text string
@@ -36,3 +44,98 @@ func (e *NetError) Error() string {
 func NewNetError(text string) error {
 	return &NetError{text: text}
 }
+
+// PayloadTooLargeError is returned when a data block exceeds the max data block
+// size of a Client. Sends are rejected before any network I/O.
+type PayloadTooLargeError struct {
+	size int
+	max  int
+}
+
+func NewPayloadTooLargeError(size int, max int) error {
+	return &PayloadTooLargeError{size: size, max: max}
+}
+
+func (e *PayloadTooLargeError) Error() string {
+	return ErrPayloadTooLarge.Error() + ": " + strconv.Itoa(e.size) + " bytes, max " + strconv.Itoa(e.max)
+}
+
+// Size returns the actual size of the data block.
+func (e *PayloadTooLargeError) Size() int {
+	return e.size
+}
+
+// Max returns the allowed max data block size.
+func (e *PayloadTooLargeError) Max() int {
+	return e.max
+}
+
+func (e *PayloadTooLargeError) Unwrap() error {
+	return ErrPayloadTooLarge
+}
+
+// MalformedError is returned when a response can not be parsed.
+// It describes where parsing failed and matches ErrMalformed through errors.Is.
+type MalformedError struct {
+	command  string
+	stage    string
+	expected string
+	snippet  []byte
+}
+
+// Max bytes of received data kept as snippet.
+const maxMalformedSnippet = 64
+
+// NewMalformedError returns a MalformedError, keeping a copy of up to
+// maxMalformedSnippet bytes of b as snippet.
+func NewMalformedError(command string, stage string, expected string, b []byte) error {
+	if len(b) > maxMalformedSnippet {
+		b = b[:maxMalformedSnippet]
+	}
+
+	return &MalformedError{
+		command:  command,
+		stage:    stage,
+		expected: expected,
+		snippet:  append([]byte(nil), b...),
+	}
+}
+
+func (e *MalformedError) Error() string {
+	msg := ErrMalformed.Error()
+	if e.command != "" {
+		msg += ": " + e.command
+	}
+	if e.stage != "" {
+		msg += ": " + e.stage
+	}
+	if e.expected != "" {
+		msg += ": expected " + e.expected
+	}
+
+	return msg + ", got " + strconv.Quote(string(e.snippet))
+}
+
+// Command returns the command whose response was being parsed.
+func (e *MalformedError) Command() string {
+	return e.command
+}
+
+// Stage returns the part of the response being parsed, e.g. "reply" or "key ttr".
+func (e *MalformedError) Stage() string {
+	return e.stage
+}
+
+// Expected returns a description of the expected format.
+func (e *MalformedError) Expected() string {
+	return e.expected
+}
+
+// Snippet returns up to 64 bytes of the data received where parsing failed.
+func (e *MalformedError) Snippet() []byte {
+	return e.snippet
+}
+
+func (e *MalformedError) Unwrap() error {
+	return ErrMalformed
+}
@@ -1,6 +1,8 @@
 package workq
 
 import (
+	"bytes"
+	"errors"
 	"testing"
 )
 
@@ -19,3 +21,42 @@ func TestNetError(t *testing.T) {
 		t.Fatalf("Error mismatch, err=%s", err)
 	}
 }
+
+func TestPayloadTooLargeError(t *testing.T) {
+	err := NewPayloadTooLargeError(11, 10)
+	perr := err.(*PayloadTooLargeError)
+	if err.Error() != "Payload too large: 11 bytes, max 10" || perr.Size() != 11 || perr.Max() != 10 {
+		t.Fatalf("Error mismatch, err=%+v", perr)
+	}
+
+	if !errors.Is(err, ErrPayloadTooLarge) {
+		t.Fatalf("Error mismatch, err=%s", err)
+	}
+}
+
+func TestMalformedError(t *testing.T) {
+	err := NewMalformedError("lease", "leased job", "numeric <ttr>", []byte("a\n"))
+	merr := err.(*MalformedError)
+	if err.Error() != `Malformed response: lease: leased job: expected numeric <ttr>, got "a\n"` {
+		t.Fatalf("Error mismatch, err=%s", err)
+	}
+
+	if merr.Command() != "lease" || merr.Stage() != "leased job" || merr.Expected() != "numeric <ttr>" || string(merr.Snippet()) != "a\n" {
+		t.Fatalf("Error mismatch, err=%+v", merr)
+	}
+
+	if !errors.Is(err, ErrMalformed) {
+		t.Fatalf("Error mismatch, err=%s", err)
+	}
+
+	b := bytes.Repeat([]byte("a"), 200)
+	merr = NewMalformedError("", "", "", b).(*MalformedError)
+	if len(merr.Snippet()) != maxMalformedSnippet {
+		t.Fatalf("Snippet mismatch, len=%d", len(merr.Snippet()))
+	}
+
+	b[0] = 'b'
+	if merr.Snippet()[0] != 'a' {
+		t.Fatalf("Snippet mismatch, snippet must be copied")
+	}
+}
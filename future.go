@@ -0,0 +1,70 @@
+package workq
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Future is the pending result of a job submitted by Pool.RunAsync or awaited
+// by Pool.ResultAsync.
+type Future struct {
+	id       string
+	deadline time.Time
+	pool     *Pool
+	done     chan struct{}
+	once     sync.Once
+	result   *JobResult
+	err      error
+}
+
+func newFuture(p *Pool, id string) *Future {
+	return &Future{id: id, pool: p, done: make(chan struct{})}
+}
+
+// ID returns the ID of the job.
+func (f *Future) ID() string {
+	return f.id
+}
+
+// Done returns a channel closed once the result is available or the future
+// failed.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait for the job result until ctx is done.
+// The future remains pending when ctx is done first and may be waited on
+// again.
+// Returns ctx.Err() if ctx is done before the future.
+// Returns context.Canceled if the future was canceled.
+// Returns ErrPoolClosed if the pool was closed before the result was
+// available.
+// Returns ResponseError for Workq response errors, "TIMED-OUT" if no result
+// was available within the timeout of the job.
+// Returns NetError on any network errors.
+// Returns ErrMalformed if response can't be parsed.
+func (f *Future) Wait(ctx context.Context) (*JobResult, error) {
+	select {
+	case <-f.done:
+		return f.result, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Cancel stops waiting for the job result, Wait returns context.Canceled.
+// The job itself is left on the server. Cancel has no effect once the future
+// is done.
+func (f *Future) Cancel() {
+	f.pool.remove(f)
+	f.resolve(nil, context.Canceled)
+}
+
+// Resolve the future once, later calls have no effect.
+func (f *Future) resolve(result *JobResult, err error) {
+	f.once.Do(func() {
+		f.result, f.err = result, err
+		close(f.done)
+	})
+}
@@ -0,0 +1,213 @@
+package workq
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+// Small max data block so that allocations stay bounded while fuzzing.
+const fuzzMaxDataBlock = 64
+
+// Valid replies used as seeds, each with the reply line stripped for entry
+// points starting after it.
+var fuzzValidReplies = []string{
+	"+OK\r\n",
+	"+OK 1\r\n",
+	"-NOT-FOUND\r\n",
+	"-CLIENT-ERROR Invalid Job ID\r\n",
+	"+OK 1\r\n6ba7b810-9dad-11d1-80b4-00c04fd430c4 1 1\r\na\r\n",
+	"+OK 1\r\n6ba7b810-9dad-11d1-80b4-00c04fd430c4 ping 1000 4\r\nping\r\n",
+	"+OK 1\r\n6ba7b810-9dad-11d1-80b4-00c04fd430c4 12\r\n" +
+		"name ping\r\n" +
+		"ttr 1000\r\n" +
+		"ttl 60000\r\n" +
+		"payload-size 4\r\n" +
+		"payload ping\r\n" +
+		"max-attempts 0\r\n" +
+		"attempts 0\r\n" +
+		"max-fails 0\r\n" +
+		"fails 0\r\n" +
+		"priority 0\r\n" +
+		"state 0\r\n" +
+		"created 2016-08-22T01:50:51Z\r\n",
+	"+OK 1\r\nserver 3\r\nactive-clients 2\r\nevicted-jobs 7\r\nstarted 2016-08-22T01:50:51Z\r\n",
+	"+OK 1\r\nping1 3\r\nready-len 1\r\nscheduled-len 2\r\nleased-len 3\r\n",
+}
+
+// Add seeds from valid replies and the existing error test cases, both as is
+// and with the reply line stripped.
+func addFuzzSeeds(f *testing.F) {
+	var seeds [][]byte
+	for _, resp := range fuzzValidReplies {
+		seeds = append(seeds, []byte(resp))
+	}
+	for _, tt := range invalidCommonErrorTests() {
+		seeds = append(seeds, tt.resp)
+	}
+	for _, tt := range invalidResultErrorTests() {
+		seeds = append(seeds, tt.resp)
+	}
+
+	for _, seed := range seeds {
+		f.Add(seed)
+		if i := bytes.Index(seed, []byte(crnl)); i >= 0 && seed[0] == '+' {
+			f.Add(seed[i+termLen:])
+		}
+	}
+}
+
+// fuzzParser is a responseParser over fuzzed input.
+type fuzzParser struct {
+	*responseParser
+	in  []byte
+	src *bytes.Reader
+}
+
+func newFuzzParser(in []byte) *fuzzParser {
+	src := bytes.NewReader(in)
+	return &fuzzParser{
+		responseParser: &responseParser{
+			rdr:          bufio.NewReader(src),
+			maxDataBlock: fuzzMaxDataBlock,
+		},
+		in:  in,
+		src: src,
+	}
+}
+
+// Check invariants after parsing:
+// Errors are one of the documented error types.
+// Successful parses consume input up to a strict "\r\n".
+func (p *fuzzParser) check(t *testing.T, err error) {
+	if err != nil {
+		var rerr *ResponseError
+		var nerr *NetError
+		if !errors.Is(err, ErrMalformed) && !errors.Is(err, ErrPayloadTooLarge) &&
+			!errors.As(err, &rerr) && !errors.As(err, &nerr) && err != ErrPayloadMustFollowSize {
+			t.Fatalf("Unexpected error type, err=%#v", err)
+		}
+		return
+	}
+
+	consumed := len(p.in) - p.src.Len() - p.rdr.Buffered()
+	if consumed < termLen || string(p.in[consumed-termLen:consumed]) != crnl {
+		t.Fatalf("Parse succeeded without terminating \"\\r\\n\", in=%q", p.in[:consumed])
+	}
+}
+
+func checkFuzzBlock(t *testing.T, b []byte) {
+	if len(b) > fuzzMaxDataBlock {
+		t.Fatalf("Data block exceeds max, len=%d", len(b))
+	}
+}
+
+func FuzzParseOk(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, in []byte) {
+		p := newFuzzParser(in)
+		err := p.parseOk()
+		p.check(t, err)
+		if err == nil && !bytes.HasPrefix(in, []byte("+OK\r\n")) {
+			t.Fatalf("Unexpected OK, in=%q", in)
+		}
+	})
+}
+
+func FuzzParseOkWithReply(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, in []byte) {
+		p := newFuzzParser(in)
+		count, err := p.parseOkWithReply()
+		p.check(t, err)
+		if err == nil && (count < 0 || !bytes.HasPrefix(in, []byte("+OK "))) {
+			t.Fatalf("Unexpected OK, count=%d, in=%q", count, in)
+		}
+	})
+}
+
+func FuzzParseReply(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, in []byte) {
+		p := newFuzzParser(in)
+		reply, err := p.parseReply()
+		p.check(t, err)
+		if err == nil && (reply.Count < 0 || !bytes.HasPrefix(in, []byte("+OK"))) {
+			t.Fatalf("Unexpected OK, reply=%+v, in=%q", reply, in)
+		}
+	})
+}
+
+func FuzzReadResult(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, in []byte) {
+		p := newFuzzParser(in)
+		result, err := p.readResult()
+		p.check(t, err)
+		if err == nil {
+			checkFuzzBlock(t, result.Result)
+		}
+	})
+}
+
+func FuzzReadLeasedJob(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, in []byte) {
+		p := newFuzzParser(in)
+		j, err := p.readLeasedJob()
+		p.check(t, err)
+		if err == nil {
+			checkFuzzBlock(t, j.Payload)
+		}
+	})
+}
+
+func FuzzReadLeasedJobStream(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, in []byte) {
+		p := newFuzzParser(in)
+		j, err := p.readLeasedJobStream()
+		if err != nil {
+			p.check(t, err)
+			return
+		}
+
+		b, err := ioutil.ReadAll(j.PayloadReader())
+		p.check(t, err)
+		if err == nil {
+			checkFuzzBlock(t, b)
+		}
+	})
+}
+
+func FuzzParseInspectedJob(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, in []byte) {
+		p := newFuzzParser(in)
+		j, err := p.parseInspectedJob()
+		p.check(t, err)
+		if err == nil {
+			checkFuzzBlock(t, j.Payload)
+		}
+	})
+}
+
+func FuzzReadInspectedServer(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, in []byte) {
+		p := newFuzzParser(in)
+		_, err := p.readInspectedServer()
+		p.check(t, err)
+	})
+}
+
+func FuzzParseInspectedQueue(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, in []byte) {
+		p := newFuzzParser(in)
+		_, err := p.parseInspectedQueue()
+		p.check(t, err)
+	})
+}
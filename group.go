@@ -0,0 +1,149 @@
+package workq
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// GroupResult is the outcome of a single job of a Group.
+type GroupResult struct {
+	ID     string
+	Result *JobResult
+	// Error adding the job or waiting for its result, ctx.Err() for jobs
+	// canceled by the Group context.
+	Err error
+}
+
+// Group fans out a batch of background jobs and gathers their results.
+//
+//	g := workq.NewGroup(pool, workq.WithGroupLimit(100))
+//	for _, j := range jobs {
+//		g.Add(j)
+//	}
+//	results := g.Run(ctx)
+type Group struct {
+	pool           *Pool
+	jobs           []*BgJob
+	limit          int
+	deleteOnCancel bool
+}
+
+// GroupOption configures a Group.
+type GroupOption func(*Group)
+
+// WithGroupLimit bounds the number of jobs of a Group that are added and
+// awaiting results at once, unbounded by default.
+func WithGroupLimit(n int) GroupOption {
+	return func(g *Group) {
+		g.limit = n
+	}
+}
+
+// WithDeleteOnCancel deletes the jobs of a Group without a result when its
+// context is done or their result wait timed out.
+func WithDeleteOnCancel() GroupOption {
+	return func(g *Group) {
+		g.deleteOnCancel = true
+	}
+}
+
+// NewGroup returns an empty Group adding jobs and gathering results through
+// pool.
+func NewGroup(pool *Pool, opts ...GroupOption) *Group {
+	g := &Group{pool: pool}
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	return g
+}
+
+// Add a job to the group, jobs are only added to the server by Run.
+func (g *Group) Add(j *BgJob) {
+	g.jobs = append(g.jobs, j)
+}
+
+// Run adds the jobs of the group and waits for their results until ctx is
+// done, returning a result per job in the order they were added to the group.
+//
+// The result of each job is awaited for up to its TTL or until the deadline of
+// ctx, whichever is first. Jobs which could not be added or have no result
+// in time are reported individually in GroupResult.Err, a failed job is not an
+// error and is reported with JobResult.Success false.
+func (g *Group) Run(ctx context.Context) []GroupResult {
+	limit := g.limit
+	if limit <= 0 {
+		limit = len(g.jobs)
+	}
+
+	results := make([]GroupResult, len(g.jobs))
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, j := range g.jobs {
+		results[i].ID = j.ID
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(r *GroupResult, j *BgJob) {
+			defer wg.Done()
+			r.Result, r.Err = g.run(ctx, j)
+			<-sem
+		}(&results[i], j)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// Add a job and wait for its result.
+func (g *Group) run(ctx context.Context, j *BgJob) (*JobResult, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.pool.Add(j)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := j.TTL
+	atDeadline := false
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := int(time.Until(deadline) / time.Millisecond); remaining < timeout {
+			timeout, atDeadline = remaining, true
+		}
+	}
+
+	f := g.pool.ResultAsync(j.ID, timeout)
+	result, err := f.Wait(ctx)
+	if err == nil {
+		return result, nil
+	}
+
+	rerr, timedOut := err.(*ResponseError)
+	timedOut = timedOut && rerr.Code() == "TIMED-OUT"
+	if timedOut && atDeadline {
+		// The wait timed out at the context deadline, rounded down to whole
+		// milliseconds, possibly before the context is done.
+		<-ctx.Done()
+	}
+
+	if ctx.Err() != nil {
+		f.Cancel()
+		err = ctx.Err()
+	}
+
+	if g.deleteOnCancel && (err == ctx.Err() || timedOut) {
+		// The job may have been completed or expired meanwhile.
+		g.pool.Delete(j.ID)
+	}
+
+	return nil, err
+}
@@ -0,0 +1,120 @@
+package workq_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/iamduo/go-workq"
+	"github.com/iamduo/go-workq/workqtest"
+)
+
+func groupJob(i int) *workq.BgJob {
+	return &workq.BgJob{ID: jobID(i), Name: "g", TTR: 1000, TTL: 60000, Payload: []byte(fmt.Sprint(i))}
+}
+
+func TestGroup(t *testing.T) {
+	srv := workqtest.NewUnstartedServer()
+	defer srv.Close()
+	pool := newTestPool(srv, 2)
+	defer pool.Close()
+
+	n, limit := 10, 3
+	g := workq.NewGroup(pool, workq.WithGroupLimit(limit))
+	for i := 0; i < n; i++ {
+		g.Add(groupJob(i))
+	}
+	// Duplicate job failing to be added.
+	g.Add(groupJob(0))
+
+	maxOutstanding := make(chan int, 1)
+	go func() {
+		worker := srv.Client()
+		max := 0
+		defer func() { maxOutstanding <- max }()
+		for i := 0; i < n; i++ {
+			j, err := worker.Lease([]string{"g"}, 5000)
+			if err != nil {
+				return
+			}
+
+			q, err := worker.InspectQueue("g")
+			if err != nil {
+				return
+			}
+			if outstanding := q.ReadyLen + q.LeasedLen; outstanding > max {
+				max = outstanding
+			}
+
+			// Fail odd jobs.
+			if j.Payload[0]%2 == 1 {
+				worker.Fail(j.ID, j.Payload)
+			} else {
+				worker.Complete(j.ID, j.Payload)
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	results := g.Run(ctx)
+	if len(results) != n+1 {
+		t.Fatalf("Results len mismatch, act=%d", len(results))
+	}
+
+	for i, r := range results[:n] {
+		if r.ID != jobID(i) || r.Err != nil {
+			t.Fatalf("Result mismatch, i=%d, result=%+v", i, r)
+		}
+		if string(r.Result.Result) != fmt.Sprint(i) || r.Result.Success != (i%2 == 0) {
+			t.Fatalf("Job result mismatch, i=%d, result=%+v", i, r.Result)
+		}
+	}
+
+	expCode(t, results[n].Err, "CLIENT-ERROR")
+	if max := <-maxOutstanding; max > limit {
+		t.Fatalf("Limit exceeded, outstanding=%d", max)
+	}
+}
+
+func TestGroupCancel(t *testing.T) {
+	for _, del := range []bool{false, true} {
+		srv := workqtest.NewUnstartedServer()
+		pool := newTestPool(srv, 1)
+
+		var opts []workq.GroupOption
+		if del {
+			opts = append(opts, workq.WithDeleteOnCancel())
+		}
+		g := workq.NewGroup(pool, append(opts, workq.WithGroupLimit(2))...)
+		for i := 0; i < 4; i++ {
+			g.Add(groupJob(i))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		results := g.Run(ctx)
+		cancel()
+		for _, r := range results {
+			if r.Err != context.DeadlineExceeded {
+				t.Fatalf("Error mismatch, result=%+v", r)
+			}
+		}
+
+		// Only the jobs within the limit were added.
+		client := srv.Client()
+		for i := 0; i < 4; i++ {
+			_, err := client.InspectJob(jobID(i))
+			if i < 2 && !del {
+				if err != nil {
+					t.Fatalf("Inspect failed, i=%d, err=%s", i, err)
+				}
+				continue
+			}
+			expCode(t, err, "NOT-FOUND")
+		}
+
+		pool.Close()
+		srv.Close()
+	}
+}
@@ -0,0 +1,24 @@
+package workq
+
+import "time"
+
+// InspectedServer is returned by the "inspect server" command.
+type InspectedServer struct {
+	ActiveClients int       // Number of connected clients.
+	EvictedJobs   int       // Number of jobs evicted since start.
+	Started       time.Time // Time of server start.
+
+	// Keys not known to this client, unless WithStrictInspect is set.
+	Extra map[string]string
+}
+
+// InspectedQueue is returned by the "inspect queues" & "inspect queue" commands.
+type InspectedQueue struct {
+	Name         string
+	ReadyLen     int // Number of jobs ready to be leased.
+	ScheduledLen int // Number of jobs scheduled for a future time.
+	LeasedLen    int // Number of jobs currently leased.
+
+	// Keys not known to this client, unless WithStrictInspect is set.
+	Extra map[string]string
+}
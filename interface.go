@@ -0,0 +1,41 @@
+package workq
+
+import "io"
+
+// Producer submits jobs and fetches their results.
+// Implemented by Client.
+type Producer interface {
+	Add(j *BgJob) error
+	AddFrom(j *BgJob, r io.Reader, size int) error
+	Run(j *FgJob) (*JobResult, error)
+	Schedule(j *ScheduledJob) error
+	Result(id string, timeout int) (*JobResult, error)
+}
+
+// Consumer leases jobs and reports their outcome.
+// Implemented by Client.
+type Consumer interface {
+	Lease(names []string, timeout int) (*LeasedJob, error)
+	LeaseStream(names []string, timeout int) (*LeasedJob, error)
+	Complete(id string, result []byte) error
+	CompleteFrom(id string, r io.Reader, size int) error
+	Fail(id string, result []byte) error
+	FailFrom(id string, r io.Reader, size int) error
+}
+
+// Admin deletes and inspects jobs, queues and the server.
+// Implemented by Client.
+type Admin interface {
+	Delete(id string) error
+	InspectJobs(name string, cursorOffset int, limit int) ([]*InspectedJob, error)
+	InspectJob(id string) (*InspectedJob, error)
+	InspectServer() (*InspectedServer, error)
+	InspectQueues(cursorOffset int, limit int) ([]*InspectedQueue, error)
+	InspectQueue(name string) (*InspectedQueue, error)
+}
+
+var (
+	_ Producer = (*Client)(nil)
+	_ Consumer = (*Client)(nil)
+	_ Admin    = (*Client)(nil)
+)
@@ -1,6 +1,11 @@
 package workq
 
-import "time"
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"time"
+)
 
 // FgJob is executed by the "run" command.
 // Describes a foreground job specification.
@@ -10,7 +15,8 @@ type FgJob struct {
 	TTR      int
 	Timeout  int // Milliseconds to wait for job completion.
 	Payload  []byte
-	Priority int // Numeric priority
+	Priority int     // Numeric priority
+	Headers  Headers // Sent in an envelope with the payload when set.
 }
 
 // BgJob is executed by the "add" command.
@@ -21,9 +27,10 @@ type BgJob struct {
 	TTR         int // Time-to-run
 	TTL         int // Time-to-live
 	Payload     []byte
-	Priority    int // Numeric priority
-	MaxAttempts int // Absoulute max num of attempts.
-	MaxFails    int // Absolute max number of failures.
+	Priority    int     // Numeric priority
+	MaxAttempts int     // Absoulute max num of attempts.
+	MaxFails    int     // Absolute max number of failures.
+	Headers     Headers // Sent in an envelope with the payload when set.
 }
 
 // ScheduledJob is executed by the "schedule" command.
@@ -35,17 +42,47 @@ type ScheduledJob struct {
 	TTL         int
 	Payload     []byte
 	Time        string
-	Priority    int // Numeric priority
-	MaxAttempts int // Absoulute max num of attempts.
-	MaxFails    int // Absolute max number of failures.
+	Priority    int     // Numeric priority
+	MaxAttempts int     // Absoulute max num of attempts.
+	MaxFails    int     // Absolute max number of failures.
+	Headers     Headers // Sent in an envelope with the payload when set.
 }
 
 // LeasedJob is returned by the "lease" command.
+// Payloads sent in an envelope are unwrapped, Payload holds the body and the
+// envelope headers are returned by Headers.
 type LeasedJob struct {
 	ID      string
 	Name    string
 	TTR     int
 	Payload []byte
+
+	// Payload left on the connection by Client.LeaseStream.
+	payload io.Reader
+	// Headers of the payload envelope.
+	headers Headers
+}
+
+// Headers returns the headers carried by the job payload envelope, empty if
+// it was sent without one. The returned map is owned by the job and may be
+// modified, such as by middleware passing metadata to handlers.
+func (j *LeasedJob) Headers() Headers {
+	if j.headers == nil {
+		j.headers = Headers{}
+	}
+
+	return j.headers
+}
+
+// PayloadReader returns a reader over the job payload.
+// For jobs leased with Client.LeaseStream the payload is read directly from
+// the connection and must be consumed before the next command on the Client.
+func (j *LeasedJob) PayloadReader() io.Reader {
+	if j.payload != nil {
+		return j.payload
+	}
+
+	return bytes.NewReader(j.Payload)
 }
 
 // JobResult is returned by the "run" & "result" commands.
@@ -54,11 +91,86 @@ type JobResult struct {
 	Result  []byte
 }
 
-// InspectedJob is returned by the "inspect jobs" command.
+// InspectedJob is returned by the "inspect jobs" & "inspect job" commands.
 type InspectedJob struct {
 	BgJob
-	Attempts int // Number of already made attempts.
-	Fails int // Number of already occured fails.
-	State int // Current state of the job.
-	Created time.Time // Time of job creation
-}
\ No newline at end of file
+	Attempts int       // Number of already made attempts.
+	Fails    int       // Number of already occured fails.
+	State    JobState  // Current state of the job.
+	Created  time.Time // Time of job creation
+
+	// Keys not known to this client, unless WithStrictInspect is set.
+	Extra map[string]string
+}
+
+// JobState is the state of an inspected job as reported by workq.
+//
+// Values are the "state" numbers of inspect replies, as defined by the State
+// constants of the workq server's job package (github.com/iamduo/workq/int/job).
+// Scheduled jobs waiting for their time are reported as new.
+type JobState int
+
+const (
+	JobStateNew       JobState = 0 // Added and waiting to be leased.
+	JobStateCompleted JobState = 1 // Successfully completed.
+	JobStateFailed    JobState = 2 // Failed without remaining attempts.
+	JobStatePending   JobState = 3 // Failed or timed out, waiting to be leased again.
+	JobStateLeased    JobState = 4 // Leased by a worker.
+)
+
+var jobStateNames = [...]string{
+	JobStateNew:       "new",
+	JobStateCompleted: "completed",
+	JobStateFailed:    "failed",
+	JobStatePending:   "pending",
+	JobStateLeased:    "leased",
+}
+
+func (s JobState) String() string {
+	if s >= 0 && int(s) < len(jobStateNames) {
+		return jobStateNames[s]
+	}
+
+	return "JobState(" + strconv.Itoa(int(s)) + ")"
+}
+
+// JobFilter reports whether an inspected job should be selected.
+type JobFilter func(j *InspectedJob) bool
+
+// FilterJobs returns the jobs selected by filter, preserving order.
+func FilterJobs(jobs []*InspectedJob, filter JobFilter) []*InspectedJob {
+	var selected []*InspectedJob
+	for _, j := range jobs {
+		if filter(j) {
+			selected = append(selected, j)
+		}
+	}
+
+	return selected
+}
+
+// InState selects jobs in any of the given states.
+func InState(states ...JobState) JobFilter {
+	return func(j *InspectedJob) bool {
+		for _, s := range states {
+			if j.State == s {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// AllOf selects jobs matching every filter.
+func AllOf(filters ...JobFilter) JobFilter {
+	return func(j *InspectedJob) bool {
+		for _, f := range filters {
+			if !f(j) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
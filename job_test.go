@@ -0,0 +1,53 @@
+package workq
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJobStateString(t *testing.T) {
+	tests := []struct {
+		state JobState
+		exp   string
+	}{
+		{JobStateNew, "new"},
+		{JobStateCompleted, "completed"},
+		{JobStateFailed, "failed"},
+		{JobStatePending, "pending"},
+		{JobStateLeased, "leased"},
+		{JobState(5), "JobState(5)"},
+		{JobState(-1), "JobState(-1)"},
+	}
+
+	for _, tt := range tests {
+		if tt.state.String() != tt.exp {
+			t.Fatalf("String mismatch, act=%s, exp=%s", tt.state, tt.exp)
+		}
+	}
+}
+
+func TestFilterJobs(t *testing.T) {
+	now := time.Date(2016, time.August, 22, 2, 0, 0, 0, time.UTC)
+	jobs := []*InspectedJob{
+		{BgJob: BgJob{Name: "j1", TTR: 1000}, State: JobStateLeased, Created: now.Add(-2 * time.Second)},
+		{BgJob: BgJob{Name: "j2", TTR: 1000}, State: JobStateLeased, Created: now},
+		{BgJob: BgJob{Name: "j3", TTR: 1000}, State: JobStateNew, Created: now.Add(-2 * time.Second)},
+		{BgJob: BgJob{Name: "j4", TTR: 1000}, State: JobStateFailed, Created: now},
+	}
+
+	selected := FilterJobs(jobs, InState(JobStateLeased, JobStateFailed))
+	if len(selected) != 3 || selected[0].Name != "j1" || selected[1].Name != "j2" || selected[2].Name != "j4" {
+		t.Fatalf("Filter mismatch, jobs=%+v", selected)
+	}
+
+	old := func(j *InspectedJob) bool { return now.Sub(j.Created) > time.Second }
+	selected = FilterJobs(jobs, AllOf(InState(JobStateLeased), old))
+	if len(selected) != 1 || selected[0].Name != "j1" {
+		t.Fatalf("Filter mismatch, jobs=%+v", selected)
+	}
+
+	selected = FilterJobs(jobs, InState(JobStatePending))
+	if selected != nil {
+		t.Fatalf("Filter mismatch, jobs=%+v", selected)
+	}
+}
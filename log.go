@@ -0,0 +1,184 @@
+package workq
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+)
+
+// Max bytes of a payload or raw reply included in a record when payload
+// logging is enabled.
+const maxLogPayload = 64
+
+// clientLog emits structured records of client events to an slog.Logger.
+// Records carry the command in flight along with its job ID and name when
+// known. Payloads and raw replies are only included when enabled as they may
+// hold sensitive data.
+type clientLog struct {
+	l        *slog.Logger
+	payloads bool
+	// Command in flight and its job ID, name and sent data block.
+	cmd   string
+	id    string
+	name  string
+	block []byte
+}
+
+// Record the command line about to be sent with its data block, nil when
+// streamed or without one.
+func (l *clientLog) command(cmd string, line string, block []byte) {
+	if l == nil {
+		return
+	}
+
+	l.cmd = cmd
+	l.id, l.name = commandJob(cmd, line)
+	l.block = nil
+	if l.payloads {
+		l.block = block
+	}
+}
+
+// Record a leased job, attaching its ID and name to further records of the
+// lease.
+func (l *clientLog) leased(j *LeasedJob) {
+	if l == nil {
+		return
+	}
+
+	l.id, l.name = j.ID, j.Name
+	l.log(slog.LevelDebug, "workq: leased job", slog.Int("ttr", j.TTR))
+}
+
+// Record a connect to addr, err is nil on success.
+func (l *clientLog) connect(addr string, err error) {
+	if l == nil {
+		return
+	}
+
+	if err != nil {
+		l.l.LogAttrs(context.Background(), slog.LevelError, "workq: connect failed",
+			slog.String("addr", addr),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	l.l.LogAttrs(context.Background(), slog.LevelInfo, "workq: connected",
+		slog.String("addr", addr),
+	)
+}
+
+// Record a connection closed after a command failed with err, to be replaced
+// by a new connection on the next command.
+func (l *clientLog) reconnect(err error) {
+	if l == nil {
+		return
+	}
+
+	l.log(slog.LevelWarn, "workq: reconnecting", slog.String("error", err.Error()))
+}
+
+// Record a failed command and return err unchanged.
+// A "TIMED-OUT" reply to "lease" or "result" is not a failure and is logged at
+// debug level.
+func (l *clientLog) failed(err error) error {
+	if l == nil {
+		return err
+	}
+
+	var (
+		rerr *ResponseError
+		merr *MalformedError
+		nerr *NetError
+		perr *PayloadTooLargeError
+	)
+	switch {
+	case errors.As(err, &rerr):
+		if (l.cmd == "lease" || l.cmd == "result") && rerr.Code() == "TIMED-OUT" {
+			l.log(slog.LevelDebug, "workq: "+l.cmd+" timed out")
+			break
+		}
+		l.log(slog.LevelWarn, "workq: command failed",
+			slog.String("code", rerr.Code()),
+			slog.String("text", rerr.Text()),
+		)
+	case errors.As(err, &merr):
+		attrs := []slog.Attr{
+			slog.String("stage", merr.Stage()),
+			slog.String("expected", merr.Expected()),
+		}
+		if l.payloads {
+			attrs = append(attrs, slog.String("snippet", string(merr.Snippet())))
+		}
+		l.log(slog.LevelError, "workq: malformed response", attrs...)
+	case errors.As(err, &nerr):
+		l.log(slog.LevelError, "workq: network error", slog.String("error", nerr.Error()))
+	case errors.As(err, &perr):
+		l.log(slog.LevelWarn, "workq: payload too large",
+			slog.Int("size", perr.Size()),
+			slog.Int("max", perr.Max()),
+		)
+	default:
+		l.log(slog.LevelError, "workq: command failed", slog.String("error", err.Error()))
+	}
+
+	return err
+}
+
+// Log a record of the command in flight with attrs.
+func (l *clientLog) log(level slog.Level, msg string, attrs ...slog.Attr) {
+	ctx := context.Background()
+	if !l.l.Enabled(ctx, level) {
+		return
+	}
+
+	rec := make([]slog.Attr, 0, len(attrs)+4)
+	rec = append(rec, slog.String("command", l.cmd))
+	if l.id != "" {
+		rec = append(rec, slog.String("job_id", l.id))
+	}
+	if l.name != "" {
+		rec = append(rec, slog.String("job_name", l.name))
+	}
+	rec = append(rec, attrs...)
+	if l.block != nil {
+		b := l.block
+		if len(b) > maxLogPayload {
+			b = b[:maxLogPayload]
+		}
+		rec = append(rec, slog.String("payload", string(b)))
+	}
+
+	l.l.LogAttrs(ctx, level, msg, rec...)
+}
+
+// Return the job ID and name addressed by a command line, empty if the command
+// does not address them.
+func commandJob(cmd string, line string) (string, string) {
+	fields := strings.Split(line, " ")
+	if strings.HasPrefix(cmd, "inspect ") {
+		fields = fields[1:]
+	}
+	if len(fields) < 2 {
+		return "", ""
+	}
+
+	switch cmd {
+	case "add", "run", "schedule":
+		if len(fields) < 3 {
+			return fields[1], ""
+		}
+		return fields[1], fields[2]
+	case "result", "complete", "fail", "delete", "inspect job":
+		return fields[1], ""
+	case "inspect jobs", "inspect queue":
+		return "", fields[1]
+	case "lease":
+		// Names of the lease until a job is leased.
+		return "", strings.Join(fields[1:len(fields)-1], ",")
+	}
+
+	return "", ""
+}
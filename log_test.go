@@ -0,0 +1,276 @@
+package workq
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// Return the records logged to buf as JSON, without their time.
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var recs []map[string]interface{}
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+
+		rec := map[string]interface{}{}
+		err := json.Unmarshal(line, &rec)
+		if err != nil {
+			t.Fatalf("Record decode failed, err=%s", err)
+		}
+		delete(rec, "time")
+		recs = append(recs, rec)
+	}
+
+	return recs
+}
+
+func newLogClient(resp string, buf *bytes.Buffer, opts ...Option) *Client {
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte(resp)),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	return NewClient(conn, append([]Option{WithLogger(logger)}, opts...)...)
+}
+
+func TestLogCommandError(t *testing.T) {
+	buf := &bytes.Buffer{}
+	client := newLogClient("-CLIENT-ERROR Invalid TTR\r\n", buf)
+	err := client.Add(&BgJob{
+		ID:      "6ba7b810-9dad-11d1-80b4-00c04fd430c4",
+		Name:    "j1",
+		TTR:     60,
+		TTL:     60000,
+		Payload: []byte("secret"),
+	})
+	if err == nil {
+		t.Fatalf("Expected error")
+	}
+
+	exp := []map[string]interface{}{
+		{
+			"level":    "WARN",
+			"msg":      "workq: command failed",
+			"command":  "add",
+			"job_id":   "6ba7b810-9dad-11d1-80b4-00c04fd430c4",
+			"job_name": "j1",
+			"code":     "CLIENT-ERROR",
+			"text":     "Invalid TTR",
+		},
+	}
+	if act := logRecords(t, buf); !reflect.DeepEqual(act, exp) {
+		t.Fatalf("Records mismatch, act=%v", act)
+	}
+}
+
+func TestLogPayloads(t *testing.T) {
+	buf := &bytes.Buffer{}
+	client := newLogClient("-CLIENT-ERROR Invalid TTR\r\n", buf, WithLogPayloads())
+	client.Add(&BgJob{
+		ID:      "6ba7b810-9dad-11d1-80b4-00c04fd430c4",
+		Name:    "j1",
+		Payload: []byte("secret"),
+	})
+
+	recs := logRecords(t, buf)
+	if len(recs) != 1 || recs[0]["payload"] != "secret" {
+		t.Fatalf("Records mismatch, act=%v", recs)
+	}
+}
+
+func TestLogMalformed(t *testing.T) {
+	tests := []struct {
+		opts    []Option
+		snippet interface{}
+	}{
+		{snippet: nil},
+		{opts: []Option{WithLogPayloads()}, snippet: "secret\r\n"},
+	}
+
+	for _, tt := range tests {
+		buf := &bytes.Buffer{}
+		client := newLogClient("+OK 1\r\nsecret\r\n", buf, tt.opts...)
+		_, err := client.Lease([]string{"j1", "j2"}, 1000)
+		if err == nil {
+			t.Fatalf("Expected error")
+		}
+
+		recs := logRecords(t, buf)
+		if len(recs) != 1 {
+			t.Fatalf("Records mismatch, act=%v", recs)
+		}
+
+		rec := recs[0]
+		if rec["level"] != "ERROR" || rec["msg"] != "workq: malformed response" {
+			t.Fatalf("Record mismatch, act=%v", rec)
+		}
+		if rec["command"] != "lease" || rec["job_name"] != "j1,j2" || rec["stage"] != "leased job" {
+			t.Fatalf("Record attrs mismatch, act=%v", rec)
+		}
+		if rec["snippet"] != tt.snippet {
+			t.Fatalf("Snippet mismatch, exp=%q, act=%q", tt.snippet, rec["snippet"])
+		}
+	}
+}
+
+func TestLogInspectNetError(t *testing.T) {
+	conn := &TestReadErrConn{
+		TestConn: TestConn{
+			rdr: bytes.NewBuffer([]byte(
+				"+OK 1\r\n" +
+					"6ba7b810-9dad-11d1-80b4-00c04fd430c4 2\r\n" +
+					"name ping\r\n",
+			)),
+			wrt: bytes.NewBuffer([]byte("")),
+		},
+	}
+	buf := &bytes.Buffer{}
+	client := NewClient(conn, WithLogger(slog.New(slog.NewJSONHandler(buf, nil))))
+	_, err := client.InspectJob("6ba7b810-9dad-11d1-80b4-00c04fd430c4")
+	if _, ok := err.(*NetError); !ok {
+		t.Fatalf("Error mismatch, err=%v", err)
+	}
+
+	recs := logRecords(t, buf)
+	if len(recs) != 1 || recs[0]["command"] != "inspect job" {
+		t.Fatalf("Records mismatch, act=%v", recs)
+	}
+}
+
+func TestLogLease(t *testing.T) {
+	buf := &bytes.Buffer{}
+	client := newLogClient(
+		"-TIMED-OUT\r\n"+
+			"+OK 1\r\n"+
+			"6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1000 3\r\n"+
+			"abc\r\n",
+		buf,
+	)
+	_, err := client.Lease([]string{"j1"}, 1000)
+	if err == nil {
+		t.Fatalf("Expected error")
+	}
+
+	_, err = client.Lease([]string{"j1"}, 1000)
+	if err != nil {
+		t.Fatalf("Lease failed, err=%s", err)
+	}
+
+	exp := []map[string]interface{}{
+		{
+			"level":    "DEBUG",
+			"msg":      "workq: lease timed out",
+			"command":  "lease",
+			"job_name": "j1",
+		},
+		{
+			"level":    "DEBUG",
+			"msg":      "workq: leased job",
+			"command":  "lease",
+			"job_id":   "6ba7b810-9dad-11d1-80b4-00c04fd430c4",
+			"job_name": "j1",
+			"ttr":      float64(1000),
+		},
+	}
+	if act := logRecords(t, buf); !reflect.DeepEqual(act, exp) {
+		t.Fatalf("Records mismatch, act=%v", act)
+	}
+}
+
+func TestLogConnect(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed, err=%s", err)
+	}
+	addr := l.Addr().String()
+
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, nil))
+	client, err := Connect(addr, WithLogger(logger))
+	if err != nil {
+		t.Fatalf("Connect failed, err=%s", err)
+	}
+	client.Close()
+	l.Close()
+
+	_, err = Connect(addr, WithLogger(logger))
+	if err == nil {
+		t.Fatalf("Expected connect error")
+	}
+
+	recs := logRecords(t, buf)
+	if len(recs) != 2 {
+		t.Fatalf("Records mismatch, act=%v", recs)
+	}
+	if recs[0]["msg"] != "workq: connected" || recs[0]["addr"] != addr {
+		t.Fatalf("Record mismatch, act=%v", recs[0])
+	}
+	if recs[1]["msg"] != "workq: connect failed" || recs[1]["level"] != "ERROR" {
+		t.Fatalf("Record mismatch, act=%v", recs[1])
+	}
+}
+
+func TestLogReconnect(t *testing.T) {
+	buf := &bytes.Buffer{}
+	pool := NewPool(func() (*Client, error) {
+		// Replies end before the response to add.
+		return newLogClient("", buf), nil
+	}, 1)
+	defer pool.Close()
+
+	err := pool.Add(&BgJob{ID: "6ba7b810-9dad-11d1-80b4-00c04fd430c4", Name: "j1", TTR: 60, TTL: 60000})
+	if _, ok := err.(*NetError); !ok {
+		t.Fatalf("Error mismatch, err=%v", err)
+	}
+
+	recs := logRecords(t, buf)
+	if len(recs) != 2 || recs[0]["msg"] != "workq: network error" {
+		t.Fatalf("Records mismatch, act=%v", recs)
+	}
+	exp := map[string]interface{}{
+		"level":    "WARN",
+		"msg":      "workq: reconnecting",
+		"command":  "add",
+		"job_id":   "6ba7b810-9dad-11d1-80b4-00c04fd430c4",
+		"job_name": "j1",
+		"error":    err.Error(),
+	}
+	if !reflect.DeepEqual(recs[1], exp) {
+		t.Fatalf("Record mismatch, act=%v", recs[1])
+	}
+}
+
+func TestCommandJob(t *testing.T) {
+	id := "6ba7b810-9dad-11d1-80b4-00c04fd430c4"
+	tests := []struct {
+		line string
+		id   string
+		name string
+	}{
+		{"add " + id + " j1 60 60000 5", id, "j1"},
+		{"run " + id + " j1 60 1000 5", id, "j1"},
+		{"schedule " + id + " j1 60 60000 2016-01-02T15:04:05Z 5", id, "j1"},
+		{"result " + id + " 1000", id, ""},
+		{"complete " + id + " 3", id, ""},
+		{"fail " + id + " 3", id, ""},
+		{"delete " + id, id, ""},
+		{"lease j1 j2 1000", "", "j1,j2"},
+		{"inspect job " + id, id, ""},
+		{"inspect jobs j1 0 10", "", "j1"},
+		{"inspect queue j1", "", "j1"},
+		{"inspect queues 0 10", "", ""},
+		{"inspect server", "", ""},
+	}
+
+	for _, tt := range tests {
+		id, name := commandJob(commandName(tt.line), tt.line)
+		if id != tt.id || name != tt.name {
+			t.Fatalf("Job mismatch, line=%q, id=%q, name=%q", tt.line, id, name)
+		}
+	}
+}
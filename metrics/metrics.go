@@ -0,0 +1,339 @@
+// Package metrics instruments workq clients.
+//
+// Client wraps any workq.Producer, workq.Consumer and workq.Admin, such as
+// *workq.Client, reporting every command to a Recorder: latency and outcome
+// per command and job name, lease hits and timeouts and payload sizes.
+// Recorder is a small interface for any metrics backend, package prom
+// implements it for Prometheus.
+//
+//	rec := prom.NewCollector()
+//	prometheus.MustRegister(rec)
+//	client := metrics.New(workqClient, rec)
+package metrics
+
+import (
+	"container/list"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iamduo/go-workq"
+)
+
+// Recorder receives measurements of a Client.
+// Implementations must be safe for concurrent use.
+type Recorder interface {
+	// ObserveCommand is called after every command with its latency and
+	// outcome, an empty code on success, see ErrorCode.
+	ObserveCommand(command string, name string, d time.Duration, code string)
+	// ObservePayload is called with the size of every payload or result sent
+	// or received by a successful command. Sizes count body bytes only, the
+	// envelope header of jobs with Headers is not included.
+	ObservePayload(command string, name string, size int)
+	// ObserveLease is called for every lease, hit is false when the lease
+	// timed out. The name of a timed out lease joins all requested names with ",".
+	ObserveLease(name string, hit bool)
+}
+
+// Error codes of ErrorCode besides ResponseError codes.
+const (
+	CodeNet             = "NET-ERROR"
+	CodeMalformed       = "MALFORMED"
+	CodePayloadTooLarge = "PAYLOAD-TOO-LARGE"
+	CodeOther           = "OTHER"
+)
+
+// ErrorCode returns the code of err for metrics: the code of a
+// workq.ResponseError, one of the Code constants otherwise or an empty code
+// for nil.
+func ErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var rerr *workq.ResponseError
+	var nerr *workq.NetError
+	switch {
+	case errors.As(err, &rerr):
+		return rerr.Code()
+	case errors.As(err, &nerr):
+		return CodeNet
+	case errors.Is(err, workq.ErrMalformed), err == workq.ErrPayloadMustFollowSize:
+		return CodeMalformed
+	case errors.Is(err, workq.ErrPayloadTooLarge):
+		return CodePayloadTooLarge
+	}
+
+	return CodeOther
+}
+
+// Conn is the set of commands instrumented by Client.
+type Conn interface {
+	workq.Producer
+	workq.Consumer
+	workq.Admin
+}
+
+// Max leased jobs tracked to attribute job names to "complete" and "fail".
+// The oldest leases are evicted beyond it.
+const maxTrackedLeases = 10000
+
+// Client instruments the commands of a Conn.
+type Client struct {
+	conn Conn
+	rec  Recorder
+
+	mu sync.Mutex
+	// Leased jobs by ID, "complete" and "fail" only carry the ID.
+	leased map[string]*list.Element
+	// Leased jobs as *lease, oldest first.
+	leases *list.List
+}
+
+// Leased job tracked for its name.
+type lease struct {
+	id      string
+	name    string
+	expires time.Time
+}
+
+var _ Conn = (*Client)(nil)
+
+// New returns a Client reporting the commands of conn to rec.
+func New(conn Conn, rec Recorder) *Client {
+	return &Client{
+		conn:   conn,
+		rec:    rec,
+		leased: make(map[string]*list.Element),
+		leases: list.New(),
+	}
+}
+
+func (c *Client) observe(command string, name string, start time.Time, err error) {
+	c.rec.ObserveCommand(command, name, time.Since(start), ErrorCode(err))
+}
+
+// Track the name of a leased job until its TTR passes.
+// Leases past their TTR, which are never completed or failed when abandoned,
+// and the oldest leases beyond maxTrackedLeases are evicted.
+func (c *Client) track(j *workq.LeasedJob) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.leased[j.ID]; ok {
+		c.leases.Remove(e)
+	}
+	c.leased[j.ID] = c.leases.PushBack(&lease{
+		id:      j.ID,
+		name:    j.Name,
+		expires: now.Add(time.Duration(j.TTR) * time.Millisecond),
+	})
+
+	for {
+		e := c.leases.Front()
+		if len(c.leased) <= maxTrackedLeases && !e.Value.(*lease).expires.Before(now) {
+			return
+		}
+
+		c.leases.Remove(e)
+		delete(c.leased, e.Value.(*lease).id)
+	}
+}
+
+// Return the name of a leased job after a command on it, empty if unknown.
+// The job is no longer tracked unless the command failed with a network
+// error, after which it may be retried.
+func (c *Client) untrack(id string, err error) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.leased[id]
+	if !ok {
+		return ""
+	}
+
+	if ErrorCode(err) != CodeNet {
+		c.leases.Remove(e)
+		delete(c.leased, id)
+	}
+	return e.Value.(*lease).name
+}
+
+func (c *Client) Add(j *workq.BgJob) error {
+	start := time.Now()
+	err := c.conn.Add(j)
+	c.observe("add", j.Name, start, err)
+	if err == nil {
+		c.rec.ObservePayload("add", j.Name, len(j.Payload))
+	}
+	return err
+}
+
+func (c *Client) AddFrom(j *workq.BgJob, r io.Reader, size int) error {
+	start := time.Now()
+	err := c.conn.AddFrom(j, r, size)
+	c.observe("add", j.Name, start, err)
+	if err == nil {
+		c.rec.ObservePayload("add", j.Name, size)
+	}
+	return err
+}
+
+func (c *Client) Run(j *workq.FgJob) (*workq.JobResult, error) {
+	start := time.Now()
+	result, err := c.conn.Run(j)
+	c.observe("run", j.Name, start, err)
+	if err == nil {
+		c.rec.ObservePayload("run", j.Name, len(j.Payload))
+		c.rec.ObservePayload("run", j.Name, len(result.Result))
+	}
+
+	return result, err
+}
+
+func (c *Client) Schedule(j *workq.ScheduledJob) error {
+	start := time.Now()
+	err := c.conn.Schedule(j)
+	c.observe("schedule", j.Name, start, err)
+	if err == nil {
+		c.rec.ObservePayload("schedule", j.Name, len(j.Payload))
+	}
+	return err
+}
+
+func (c *Client) Result(id string, timeout int) (*workq.JobResult, error) {
+	start := time.Now()
+	result, err := c.conn.Result(id, timeout)
+	c.observe("result", "", start, err)
+	if err == nil {
+		c.rec.ObservePayload("result", "", len(result.Result))
+	}
+
+	return result, err
+}
+
+func (c *Client) Lease(names []string, timeout int) (*workq.LeasedJob, error) {
+	start := time.Now()
+	j, err := c.conn.Lease(names, timeout)
+	c.observeLease(names, start, j, err)
+	if err == nil {
+		c.rec.ObservePayload("lease", j.Name, len(j.Payload))
+	}
+
+	return j, err
+}
+
+// LeaseStream leases a job like Lease, the streamed payload size is not
+// observed.
+func (c *Client) LeaseStream(names []string, timeout int) (*workq.LeasedJob, error) {
+	start := time.Now()
+	j, err := c.conn.LeaseStream(names, timeout)
+	c.observeLease(names, start, j, err)
+	return j, err
+}
+
+func (c *Client) observeLease(names []string, start time.Time, j *workq.LeasedJob, err error) {
+	if err != nil {
+		name := strings.Join(names, ",")
+		c.observe("lease", name, start, err)
+		if ErrorCode(err) == "TIMED-OUT" {
+			c.rec.ObserveLease(name, false)
+		}
+		return
+	}
+
+	c.observe("lease", j.Name, start, nil)
+	c.rec.ObserveLease(j.Name, true)
+	c.track(j)
+}
+
+func (c *Client) Complete(id string, result []byte) error {
+	start := time.Now()
+	err := c.conn.Complete(id, result)
+	name := c.untrack(id, err)
+	c.observe("complete", name, start, err)
+	if err == nil {
+		c.rec.ObservePayload("complete", name, len(result))
+	}
+	return err
+}
+
+func (c *Client) CompleteFrom(id string, r io.Reader, size int) error {
+	start := time.Now()
+	err := c.conn.CompleteFrom(id, r, size)
+	name := c.untrack(id, err)
+	c.observe("complete", name, start, err)
+	if err == nil {
+		c.rec.ObservePayload("complete", name, size)
+	}
+	return err
+}
+
+func (c *Client) Fail(id string, result []byte) error {
+	start := time.Now()
+	err := c.conn.Fail(id, result)
+	name := c.untrack(id, err)
+	c.observe("fail", name, start, err)
+	if err == nil {
+		c.rec.ObservePayload("fail", name, len(result))
+	}
+	return err
+}
+
+func (c *Client) FailFrom(id string, r io.Reader, size int) error {
+	start := time.Now()
+	err := c.conn.FailFrom(id, r, size)
+	name := c.untrack(id, err)
+	c.observe("fail", name, start, err)
+	if err == nil {
+		c.rec.ObservePayload("fail", name, size)
+	}
+	return err
+}
+
+func (c *Client) Delete(id string) error {
+	start := time.Now()
+	err := c.conn.Delete(id)
+	c.observe("delete", c.untrack(id, err), start, err)
+	return err
+}
+
+func (c *Client) InspectJobs(name string, cursorOffset int, limit int) ([]*workq.InspectedJob, error) {
+	start := time.Now()
+	jobs, err := c.conn.InspectJobs(name, cursorOffset, limit)
+	c.observe("inspect jobs", name, start, err)
+	return jobs, err
+}
+
+func (c *Client) InspectJob(id string) (*workq.InspectedJob, error) {
+	start := time.Now()
+	j, err := c.conn.InspectJob(id)
+	name := ""
+	if err == nil {
+		name = j.Name
+	}
+	c.observe("inspect job", name, start, err)
+	return j, err
+}
+
+func (c *Client) InspectServer() (*workq.InspectedServer, error) {
+	start := time.Now()
+	srv, err := c.conn.InspectServer()
+	c.observe("inspect server", "", start, err)
+	return srv, err
+}
+
+func (c *Client) InspectQueues(cursorOffset int, limit int) ([]*workq.InspectedQueue, error) {
+	start := time.Now()
+	queues, err := c.conn.InspectQueues(cursorOffset, limit)
+	c.observe("inspect queues", "", start, err)
+	return queues, err
+}
+
+func (c *Client) InspectQueue(name string) (*workq.InspectedQueue, error) {
+	start := time.Now()
+	q, err := c.conn.InspectQueue(name)
+	c.observe("inspect queue", name, start, err)
+	return q, err
+}
@@ -0,0 +1,209 @@
+package metrics
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iamduo/go-workq"
+	"github.com/iamduo/go-workq/workqtest"
+)
+
+const id1 = "6ba7b810-9dad-11d1-80b4-00c04fd430c4"
+
+type observation struct {
+	kind    string
+	command string
+	name    string
+	value   string
+}
+
+type testRecorder struct {
+	mu  sync.Mutex
+	obs []observation
+}
+
+func (r *testRecorder) ObserveCommand(command string, name string, d time.Duration, code string) {
+	r.add(observation{"command", command, name, code})
+}
+
+func (r *testRecorder) ObservePayload(command string, name string, size int) {
+	r.add(observation{"payload", command, name, strconv.Itoa(size)})
+}
+
+func (r *testRecorder) ObserveLease(name string, hit bool) {
+	value := "timeout"
+	if hit {
+		value = "hit"
+	}
+	r.add(observation{"lease", "lease", name, value})
+}
+
+func (r *testRecorder) add(o observation) {
+	r.mu.Lock()
+	r.obs = append(r.obs, o)
+	r.mu.Unlock()
+}
+
+func TestClient(t *testing.T) {
+	srv := workqtest.NewUnstartedServer()
+	defer srv.Close()
+	rec := &testRecorder{}
+	client := New(srv.Client(), rec)
+
+	err := client.Add(&workq.BgJob{ID: id1, Name: "j1", TTR: 1000, TTL: 60000, Payload: []byte("ab")})
+	if err != nil {
+		t.Fatalf("Add failed, err=%s", err)
+	}
+
+	_, err = client.Lease([]string{"j1"}, 0)
+	if err != nil {
+		t.Fatalf("Lease failed, err=%s", err)
+	}
+
+	err = client.Complete(id1, []byte("abc"))
+	if err != nil {
+		t.Fatalf("Complete failed, err=%s", err)
+	}
+
+	_, err = client.Lease([]string{"j1", "j2"}, 0)
+	if err == nil {
+		t.Fatal("Expected lease timeout")
+	}
+
+	err = client.Delete("6ba7b811-9dad-11d1-80b4-00c04fd430c4")
+	if err == nil {
+		t.Fatal("Expected NOT-FOUND")
+	}
+
+	exp := []observation{
+		{"command", "add", "j1", ""},
+		{"payload", "add", "j1", "2"},
+		{"command", "lease", "j1", ""},
+		{"lease", "lease", "j1", "hit"},
+		{"payload", "lease", "j1", "2"},
+		{"command", "complete", "j1", ""},
+		{"payload", "complete", "j1", "3"},
+		{"command", "lease", "j1,j2", "TIMED-OUT"},
+		{"lease", "lease", "j1,j2", "timeout"},
+		{"command", "delete", "", "NOT-FOUND"},
+	}
+	if !reflect.DeepEqual(rec.obs, exp) {
+		t.Fatalf("Observations mismatch, act=%+v", rec.obs)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code string
+	}{
+		{nil, ""},
+		{workq.NewResponseError("NOT-FOUND", ""), "NOT-FOUND"},
+		{workq.NewNetError("EOF"), CodeNet},
+		{workq.ErrMalformed, CodeMalformed},
+		{workq.NewMalformedError("add", "reply", "+OK", nil), CodeMalformed},
+		{workq.ErrPayloadMustFollowSize, CodeMalformed},
+		{workq.NewPayloadTooLargeError(2, 1), CodePayloadTooLarge},
+		{errors.New("other"), CodeOther},
+	}
+	for _, tt := range tests {
+		if code := ErrorCode(tt.err); code != tt.code {
+			t.Fatalf("Code mismatch, err=%v, exp=%s, act=%s", tt.err, tt.code, code)
+		}
+	}
+}
+
+func TestClientTrackedLeases(t *testing.T) {
+	n := 0
+	leased := func(ttr int) func(names []string, timeout int) (*workq.LeasedJob, error) {
+		return func(names []string, timeout int) (*workq.LeasedJob, error) {
+			n++
+			return &workq.LeasedJob{ID: strconv.Itoa(n), Name: "j" + strconv.Itoa(n), TTR: ttr}, nil
+		}
+	}
+	m := &workqtest.Mock{LeaseFunc: leased(60000)}
+	client := New(m, &testRecorder{})
+
+	for i := 0; i <= maxTrackedLeases; i++ {
+		_, err := client.Lease([]string{"j"}, 0)
+		if err != nil {
+			t.Fatalf("Lease failed, err=%s", err)
+		}
+	}
+
+	// The oldest lease is evicted beyond the limit.
+	if len(client.leased) != maxTrackedLeases || client.leases.Len() != maxTrackedLeases {
+		t.Fatalf("Tracked leases mismatch, act=%d", len(client.leased))
+	}
+	if name := client.untrack("1", nil); name != "" {
+		t.Fatalf("Name mismatch, act=%s", name)
+	}
+	last := strconv.Itoa(maxTrackedLeases + 1)
+	if name := client.untrack(last, nil); name != "j"+last {
+		t.Fatalf("Name mismatch, act=%s", name)
+	}
+
+	// Leases past their TTR are evicted.
+	client = New(m, &testRecorder{})
+	m.LeaseFunc = leased(1)
+	client.Lease([]string{"j"}, 0)
+	time.Sleep(5 * time.Millisecond)
+	client.Lease([]string{"j"}, 0)
+	if len(client.leased) != 1 || client.leases.Len() != 1 {
+		t.Fatalf("Tracked leases mismatch, act=%d", len(client.leased))
+	}
+}
+
+func TestClientFailedCommands(t *testing.T) {
+	netErr := workq.NewNetError("EOF")
+	completeErrs := []error{netErr, nil}
+	m := &workqtest.Mock{
+		AddFunc: func(j *workq.BgJob) error {
+			return workq.NewResponseError("CLIENT-ERROR", "Invalid TTR")
+		},
+		LeaseFunc: func(names []string, timeout int) (*workq.LeasedJob, error) {
+			return &workq.LeasedJob{ID: id1, Name: "j1", TTR: 60000}, nil
+		},
+		CompleteFunc: func(id string, result []byte) error {
+			err := completeErrs[0]
+			completeErrs = completeErrs[1:]
+			return err
+		},
+	}
+	rec := &testRecorder{}
+	client := New(m, rec)
+
+	client.Add(&workq.BgJob{ID: id1, Name: "j1", Payload: []byte("ab")})
+	if !reflect.DeepEqual(rec.obs, []observation{{"command", "add", "j1", "CLIENT-ERROR"}}) {
+		t.Fatalf("Observations mismatch, act=%+v", rec.obs)
+	}
+
+	client.Lease([]string{"j1"}, 0)
+	rec.obs = nil
+
+	// The job name is kept for a retry after a network error.
+	err := client.Complete(id1, []byte("abc"))
+	if err != netErr {
+		t.Fatalf("Error mismatch, err=%v", err)
+	}
+	err = client.Complete(id1, []byte("abc"))
+	if err != nil {
+		t.Fatalf("Complete failed, err=%s", err)
+	}
+
+	exp := []observation{
+		{"command", "complete", "j1", CodeNet},
+		{"command", "complete", "j1", ""},
+		{"payload", "complete", "j1", "3"},
+	}
+	if !reflect.DeepEqual(rec.obs, exp) {
+		t.Fatalf("Observations mismatch, act=%+v", rec.obs)
+	}
+	if len(client.leased) != 0 {
+		t.Fatalf("Tracked leases mismatch, act=%d", len(client.leased))
+	}
+}
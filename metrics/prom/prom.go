@@ -0,0 +1,109 @@
+// Package prom implements metrics.Recorder for Prometheus.
+//
+//	collector := prom.NewCollector()
+//	prometheus.MustRegister(collector)
+//	client := metrics.New(workqClient, collector)
+//
+// Exported metrics:
+//
+//	workq_client_commands_total{command,name,code}        Commands by outcome, code is empty on success.
+//	workq_client_errors_total{command,code}               Failed commands by error code.
+//	workq_client_command_duration_seconds{command,name}   Command latency.
+//	workq_client_payload_bytes{command,name}              Payload and result body sizes.
+//	workq_client_leases_total{name,result}                Leases by result, "hit" or "timeout".
+package prom
+
+import (
+	"time"
+
+	"github.com/iamduo/go-workq/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is a prometheus.Collector recording workq client metrics.
+type Collector struct {
+	commands *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	payload  *prometheus.HistogramVec
+	leases   *prometheus.CounterVec
+}
+
+var _ metrics.Recorder = (*Collector)(nil)
+
+// NewCollector returns a Collector with the default metric names.
+func NewCollector() *Collector {
+	return &Collector{
+		commands: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "workq",
+			Subsystem: "client",
+			Name:      "commands_total",
+			Help:      "Workq commands by command, job name and error code, empty on success.",
+		}, []string{"command", "name", "code"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "workq",
+			Subsystem: "client",
+			Name:      "errors_total",
+			Help:      "Failed workq commands by command and error code.",
+		}, []string{"command", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "workq",
+			Subsystem: "client",
+			Name:      "command_duration_seconds",
+			Help:      "Workq command latency by command and job name.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"command", "name"}),
+		payload: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "workq",
+			Subsystem: "client",
+			Name:      "payload_bytes",
+			Help:      "Workq payload and result body sizes by command and job name, excluding envelope headers.",
+			Buckets:   prometheus.ExponentialBuckets(64, 4, 8),
+		}, []string{"command", "name"}),
+		leases: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "workq",
+			Subsystem: "client",
+			Name:      "leases_total",
+			Help:      "Workq leases by job name and result, hit or timeout.",
+		}, []string{"name", "result"}),
+	}
+}
+
+func (c *Collector) ObserveCommand(command string, name string, d time.Duration, code string) {
+	c.commands.WithLabelValues(command, name, code).Inc()
+	c.duration.WithLabelValues(command, name).Observe(d.Seconds())
+	if code != "" {
+		c.errors.WithLabelValues(command, code).Inc()
+	}
+}
+
+func (c *Collector) ObservePayload(command string, name string, size int) {
+	c.payload.WithLabelValues(command, name).Observe(float64(size))
+}
+
+func (c *Collector) ObserveLease(name string, hit bool) {
+	result := "timeout"
+	if hit {
+		result = "hit"
+	}
+
+	c.leases.WithLabelValues(name, result).Inc()
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.commands.Describe(ch)
+	c.errors.Describe(ch)
+	c.duration.Describe(ch)
+	c.payload.Describe(ch)
+	c.leases.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.commands.Collect(ch)
+	c.errors.Collect(ch)
+	c.duration.Collect(ch)
+	c.payload.Collect(ch)
+	c.leases.Collect(ch)
+}
@@ -0,0 +1,48 @@
+package prom
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector()
+	reg := prometheus.NewPedanticRegistry()
+	err := reg.Register(c)
+	if err != nil {
+		t.Fatalf("Register failed, err=%s", err)
+	}
+
+	c.ObserveCommand("add", "j1", time.Millisecond, "")
+	c.ObserveCommand("add", "j1", time.Millisecond, "CLIENT-ERROR")
+	c.ObservePayload("add", "j1", 100)
+	c.ObserveLease("j1", true)
+	c.ObserveLease("j1", false)
+
+	exp := `
+# HELP workq_client_commands_total Workq commands by command, job name and error code, empty on success.
+# TYPE workq_client_commands_total counter
+workq_client_commands_total{code="",command="add",name="j1"} 1
+workq_client_commands_total{code="CLIENT-ERROR",command="add",name="j1"} 1
+# HELP workq_client_errors_total Failed workq commands by command and error code.
+# TYPE workq_client_errors_total counter
+workq_client_errors_total{code="CLIENT-ERROR",command="add"} 1
+# HELP workq_client_leases_total Workq leases by job name and result, hit or timeout.
+# TYPE workq_client_leases_total counter
+workq_client_leases_total{name="j1",result="hit"} 1
+workq_client_leases_total{name="j1",result="timeout"} 1
+`
+	err = testutil.GatherAndCompare(reg, strings.NewReader(exp),
+		"workq_client_commands_total", "workq_client_errors_total", "workq_client_leases_total")
+	if err != nil {
+		t.Fatalf("Metrics mismatch, err=%s", err)
+	}
+
+	if n := testutil.CollectAndCount(c, "workq_client_command_duration_seconds", "workq_client_payload_bytes"); n != 2 {
+		t.Fatalf("Histogram count mismatch, act=%d", n)
+	}
+}
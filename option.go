@@ -0,0 +1,69 @@
+package workq
+
+import (
+	"io"
+	"log/slog"
+)
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithMaxDataBlock sets the max data block size in bytes that can be sent or
+// read within a command, defaults to DefaultMaxDataBlock, as does a size of
+// zero or less. Should match the limit configured on the workq server.
+func WithMaxDataBlock(size int) Option {
+	return func(c *Client) {
+		if size <= 0 {
+			size = DefaultMaxDataBlock
+		}
+		c.maxDataBlock = size
+	}
+}
+
+// WithStrictInspect rejects inspect replies containing keys unknown to this
+// client with ErrMalformed. By default unknown keys are kept in the Extra map
+// of the inspected object so that newer servers remain readable.
+func WithStrictInspect() Option {
+	return func(c *Client) {
+		c.strictInspect = true
+	}
+}
+
+// WithWireTrace writes a transcript of every command sent and reply received
+// to w, for diagnosing protocol issues. Up to maxBlock bytes of each data
+// block are included, 0 redacts data blocks and a negative maxBlock includes
+// them in full. Writes of all clients are serialized one line at a time, so
+// that clients, such as those of a Pool, may share w.
+func WithWireTrace(w io.Writer, maxBlock int) Option {
+	return func(c *Client) {
+		c.trace = &wireTrace{w: w, maxBlock: maxBlock}
+	}
+}
+
+// WithLogger emits structured records of connects, failed commands,
+// malformed responses and lease timeouts to logger. Records carry the command
+// along with the job ID and name when known.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// WithLogPayloads includes up to 64 bytes of the sent data block and of the
+// raw reply of malformed responses in log records. Payloads are left out of
+// log records by default as they may hold sensitive data.
+func WithLogPayloads() Option {
+	return func(c *Client) {
+		c.logPayloads = true
+	}
+}
+
+// WithRawPayloads disables decoding of payload envelopes. Leased and inspected
+// payloads are returned as sent, including any envelope, and job headers are
+// left empty. Use it when payloads from other producers may start with the
+// envelope magic line.
+func WithRawPayloads() Option {
+	return func(c *Client) {
+		c.rawPayloads = true
+	}
+}
@@ -0,0 +1,226 @@
+// Package otelworkq propagates OpenTelemetry trace context through workq jobs.
+//
+// Client wraps the producer and consumer commands of a workq client with
+// context aware methods. Add, Run and Schedule inject the trace context of
+// their span into the job headers, Lease extracts it so that worker spans
+// continue the producer trace. Every command runs in a span with the job name,
+// ID, TTR and priority as attributes.
+//
+//	client := otelworkq.New(workqClient)
+//	err := client.Add(ctx, job)
+//
+//	// Worker
+//	job, ctx, err := client.Lease(ctx, []string{"ping"}, 60000)
+//	ctx, span := tracer.Start(ctx, "handle ping") // child of the producer span
+//
+// The trace context travels in the payload envelope of the workq client, see
+// workq.Headers.
+package otelworkq
+
+import (
+	"context"
+
+	"github.com/iamduo/go-workq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/iamduo/go-workq/otelworkq"
+
+// Span attribute keys.
+const (
+	SystemKey      = attribute.Key("messaging.system")
+	OperationKey   = attribute.Key("messaging.operation")
+	JobNameKey     = attribute.Key("workq.job.name")
+	JobIDKey       = attribute.Key("workq.job.id")
+	JobTTRKey      = attribute.Key("workq.job.ttr")
+	JobPriorityKey = attribute.Key("workq.job.priority")
+)
+
+// Conn is the set of commands traced by Client, implemented by workq.Client.
+type Conn interface {
+	workq.Producer
+	workq.Consumer
+	Delete(id string) error
+}
+
+// Client traces the commands of a Conn.
+type Client struct {
+	conn       Conn
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// Option configures a Client.
+type Option func(*options)
+
+type options struct {
+	provider   trace.TracerProvider
+	propagator propagation.TextMapPropagator
+}
+
+// WithTracerProvider sets the tracer provider, the global provider by default.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(o *options) {
+		o.provider = provider
+	}
+}
+
+// WithPropagator sets the propagator of the trace context within payloads,
+// the global propagator by default.
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(o *options) {
+		o.propagator = propagator
+	}
+}
+
+// New returns a Client tracing the commands of conn.
+func New(conn Conn, opts ...Option) *Client {
+	o := &options{
+		provider:   otel.GetTracerProvider(),
+		propagator: otel.GetTextMapPropagator(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return &Client{
+		conn:       conn,
+		tracer:     o.provider.Tracer(instrumentationName),
+		propagator: o.propagator,
+	}
+}
+
+// Start a span for command with the given kind and attributes.
+func (c *Client) start(ctx context.Context, command string, kind trace.SpanKind, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, SystemKey.String("workq"), OperationKey.String(command))
+	return c.tracer.Start(ctx, "workq "+command, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+}
+
+// End span recording err.
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Return headers with the trace context of ctx added, h is not modified.
+func (c *Client) inject(ctx context.Context, h workq.Headers) workq.Headers {
+	carrier := propagation.MapCarrier{}
+	c.propagator.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return h
+	}
+
+	h = h.Clone()
+	if h == nil {
+		h = workq.Headers{}
+	}
+	for k, v := range carrier {
+		h[k] = v
+	}
+
+	return h
+}
+
+func jobAttrs(id string, name string, ttr int, priority int) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		JobIDKey.String(id),
+		JobNameKey.String(name),
+		JobTTRKey.Int(ttr),
+		JobPriorityKey.Int(priority),
+	}
+}
+
+// Add a background job, see workq.Client.Add.
+// The trace context is sent in the job headers, j is not modified.
+func (c *Client) Add(ctx context.Context, j *workq.BgJob) error {
+	ctx, span := c.start(ctx, "add", trace.SpanKindProducer, jobAttrs(j.ID, j.Name, j.TTR, j.Priority)...)
+	job := *j
+	job.Headers = c.inject(ctx, j.Headers)
+	err := c.conn.Add(&job)
+	end(span, err)
+	return err
+}
+
+// Run a foreground job, see workq.Client.Run.
+// The trace context is sent in the job headers, j is not modified.
+func (c *Client) Run(ctx context.Context, j *workq.FgJob) (*workq.JobResult, error) {
+	ctx, span := c.start(ctx, "run", trace.SpanKindProducer, jobAttrs(j.ID, j.Name, j.TTR, j.Priority)...)
+	job := *j
+	job.Headers = c.inject(ctx, j.Headers)
+	result, err := c.conn.Run(&job)
+	end(span, err)
+	return result, err
+}
+
+// Schedule a job, see workq.Client.Schedule.
+// The trace context is sent in the job headers, j is not modified.
+func (c *Client) Schedule(ctx context.Context, j *workq.ScheduledJob) error {
+	ctx, span := c.start(ctx, "schedule", trace.SpanKindProducer, jobAttrs(j.ID, j.Name, j.TTR, j.Priority)...)
+	job := *j
+	job.Headers = c.inject(ctx, j.Headers)
+	err := c.conn.Schedule(&job)
+	end(span, err)
+	return err
+}
+
+// Result fetches a job result, see workq.Client.Result.
+func (c *Client) Result(ctx context.Context, id string, timeout int) (*workq.JobResult, error) {
+	_, span := c.start(ctx, "result", trace.SpanKindClient, JobIDKey.String(id))
+	result, err := c.conn.Result(id, timeout)
+	end(span, err)
+	return result, err
+}
+
+// Lease a job, see workq.Client.Lease.
+//
+// The returned context carries the trace context of the job headers as the
+// remote parent of spans handling the job and the lease span links to the
+// producer span.
+func (c *Client) Lease(ctx context.Context, names []string, timeout int) (*workq.LeasedJob, context.Context, error) {
+	_, span := c.start(ctx, "lease", trace.SpanKindConsumer)
+	j, err := c.conn.Lease(names, timeout)
+	if err != nil {
+		end(span, err)
+		return nil, ctx, err
+	}
+
+	span.SetAttributes(JobIDKey.String(j.ID), JobNameKey.String(j.Name), JobTTRKey.Int(j.TTR))
+	ctx = c.propagator.Extract(ctx, propagation.MapCarrier(j.Headers()))
+	if sc := trace.SpanContextFromContext(ctx); sc.IsRemote() {
+		span.AddLink(trace.Link{SpanContext: sc})
+	}
+
+	end(span, nil)
+	return j, ctx, nil
+}
+
+// Complete a leased job, see workq.Client.Complete.
+func (c *Client) Complete(ctx context.Context, id string, result []byte) error {
+	_, span := c.start(ctx, "complete", trace.SpanKindClient, JobIDKey.String(id))
+	err := c.conn.Complete(id, result)
+	end(span, err)
+	return err
+}
+
+// Fail a leased job, see workq.Client.Fail.
+func (c *Client) Fail(ctx context.Context, id string, result []byte) error {
+	_, span := c.start(ctx, "fail", trace.SpanKindClient, JobIDKey.String(id))
+	err := c.conn.Fail(id, result)
+	end(span, err)
+	return err
+}
+
+// Delete a job, see workq.Client.Delete.
+func (c *Client) Delete(ctx context.Context, id string) error {
+	_, span := c.start(ctx, "delete", trace.SpanKindClient, JobIDKey.String(id))
+	err := c.conn.Delete(id)
+	end(span, err)
+	return err
+}
@@ -0,0 +1,149 @@
+package otelworkq
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iamduo/go-workq"
+	"github.com/iamduo/go-workq/workqtest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const id1 = "6ba7b810-9dad-11d1-80b4-00c04fd430c4"
+
+func newTestClient(srv *workqtest.Server) (*Client, *tracetest.SpanRecorder, trace.Tracer) {
+	rec := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	client := New(
+		srv.Client(),
+		WithTracerProvider(provider),
+		WithPropagator(propagation.TraceContext{}),
+	)
+	return client, rec, provider.Tracer("test")
+}
+
+func attr(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+
+	return attribute.Value{}
+}
+
+func TestPropagation(t *testing.T) {
+	srv := workqtest.NewUnstartedServer()
+	defer srv.Close()
+	client, rec, tracer := newTestClient(srv)
+
+	ctx, parent := tracer.Start(context.Background(), "produce")
+	j := &workq.BgJob{
+		ID:       id1,
+		Name:     "j1",
+		TTR:      1000,
+		TTL:      60000,
+		Payload:  []byte("ping"),
+		Priority: 3,
+		Headers:  workq.Headers{workq.HeaderContentType: "text/plain"},
+	}
+	err := client.Add(ctx, j)
+	if err != nil {
+		t.Fatalf("Add failed, err=%s", err)
+	}
+	parent.End()
+
+	if string(j.Payload) != "ping" || len(j.Headers) != 1 {
+		t.Fatalf("Job modified, payload=%q, headers=%v", j.Payload, j.Headers)
+	}
+
+	leased, workerCtx, err := client.Lease(context.Background(), []string{"j1"}, 0)
+	if err != nil {
+		t.Fatalf("Lease failed, err=%s", err)
+	}
+	if string(leased.Payload) != "ping" {
+		t.Fatalf("Payload mismatch, act=%q", leased.Payload)
+	}
+	if leased.Headers().Get(workq.HeaderContentType) != "text/plain" || leased.Headers().Get("traceparent") == "" {
+		t.Fatalf("Headers mismatch, act=%v", leased.Headers())
+	}
+
+	_, handle := tracer.Start(workerCtx, "handle")
+	handle.End()
+
+	err = client.Complete(workerCtx, leased.ID, nil)
+	if err != nil {
+		t.Fatalf("Complete failed, err=%s", err)
+	}
+
+	spans := rec.Ended()
+	byName := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range spans {
+		byName[span.Name()] = span
+	}
+
+	add := byName["workq add"]
+	if add == nil || add.Parent().SpanID() != parent.SpanContext().SpanID() || add.SpanKind() != trace.SpanKindProducer {
+		t.Fatalf("Add span mismatch, span=%+v", add)
+	}
+	if attr(add, JobNameKey).AsString() != "j1" || attr(add, JobIDKey).AsString() != id1 ||
+		attr(add, JobTTRKey).AsInt64() != 1000 || attr(add, JobPriorityKey).AsInt64() != 3 {
+		t.Fatalf("Add attributes mismatch, act=%v", add.Attributes())
+	}
+
+	lease := byName["workq lease"]
+	if lease == nil || len(lease.Links()) != 1 || lease.Links()[0].SpanContext.SpanID() != add.SpanContext().SpanID() {
+		t.Fatalf("Lease span mismatch, span=%+v", lease)
+	}
+
+	handleSpan := byName["handle"]
+	if handleSpan.SpanContext().TraceID() != add.SpanContext().TraceID() || handleSpan.Parent().SpanID() != add.SpanContext().SpanID() {
+		t.Fatal("Worker span not continuing producer trace")
+	}
+
+	if byName["workq complete"] == nil {
+		t.Fatal("Missing complete span")
+	}
+}
+
+func TestLeaseWithoutTraceContext(t *testing.T) {
+	srv := workqtest.NewUnstartedServer()
+	defer srv.Close()
+	client, rec, _ := newTestClient(srv)
+
+	err := srv.Client().Add(&workq.BgJob{ID: id1, Name: "j1", TTR: 1000, TTL: 60000, Payload: []byte("ping")})
+	if err != nil {
+		t.Fatalf("Add failed, err=%s", err)
+	}
+
+	leased, ctx, err := client.Lease(context.Background(), []string{"j1"}, 0)
+	if err != nil || string(leased.Payload) != "ping" {
+		t.Fatalf("Lease mismatch, job=%+v, err=%v", leased, err)
+	}
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		t.Fatal("Unexpected span context")
+	}
+	if len(rec.Ended()[0].Links()) != 0 {
+		t.Fatal("Unexpected link")
+	}
+}
+
+func TestCommandError(t *testing.T) {
+	srv := workqtest.NewUnstartedServer()
+	defer srv.Close()
+	client, rec, _ := newTestClient(srv)
+
+	_, _, err := client.Lease(context.Background(), []string{"j1"}, 0)
+	if err == nil {
+		t.Fatal("Expected lease timeout")
+	}
+
+	span := rec.Ended()[0]
+	if span.Status().Description != err.Error() || len(span.Events()) != 1 {
+		t.Fatalf("Span error mismatch, status=%+v", span.Status())
+	}
+}
@@ -0,0 +1,350 @@
+package workq
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosed is returned for futures of a closed Pool.
+var ErrPoolClosed = errors.New("Pool closed")
+
+// DefaultPollInterval is the default interval at which a Pool polls the
+// results of pending futures.
+const DefaultPollInterval = 100 * time.Millisecond
+
+// Pool multiplexes asynchronous foreground jobs over a bounded set of
+// connections.
+//
+// Instead of holding a connection for the duration of each job like Run,
+// RunAsync submits the job with "add" and a single poller checks the results
+// of all pending futures with pipelined "result" commands every poll interval.
+// Thousands of foreground jobs may be outstanding at once without a goroutine
+// or connection each.
+//
+// Pool is safe for concurrent use.
+type Pool struct {
+	dial     func() (*Client, error)
+	interval time.Duration
+	clock    Clock
+	// One token per open connection.
+	slots chan struct{}
+	idle  chan *Client
+
+	mu       sync.Mutex
+	pending  map[*Future]struct{}
+	isClosed bool
+	closed   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// PoolOption configures a Pool.
+type PoolOption func(*Pool)
+
+// WithPollInterval sets the interval at which results of pending futures are
+// polled, defaults to DefaultPollInterval.
+func WithPollInterval(d time.Duration) PoolOption {
+	return func(p *Pool) {
+		p.interval = d
+	}
+}
+
+// WithPoolClock sets the time source of future deadlines and the poll
+// interval, defaults to the system time. See workqtest.FakeClock.
+func WithPoolClock(clock Clock) PoolOption {
+	return func(p *Pool) {
+		p.clock = clock
+	}
+}
+
+// NewPool returns a Pool of up to size connections opened with dial.
+//
+//	pool := workq.NewPool(func() (*workq.Client, error) {
+//		return workq.Connect("localhost:9922")
+//	}, 4)
+func NewPool(dial func() (*Client, error), size int, opts ...PoolOption) *Pool {
+	p := &Pool{
+		dial:     dial,
+		interval: DefaultPollInterval,
+		clock:    realClock{},
+		slots:    make(chan struct{}, size),
+		idle:     make(chan *Client, size),
+		pending:  make(map[*Future]struct{}),
+		closed:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	p.wg.Add(1)
+	go p.poll()
+	return p
+}
+
+// RunAsync submits a foreground job and returns a Future of its result.
+//
+// Unlike Run, the job is not submitted with "run" but added with "add" as a
+// background job, and its result is polled with "result <id> 0":
+//
+//   - The job has a TTL of j.Timeout plus the poll interval, so that a result
+//     completed just before the timeout is still observed. It is evicted on
+//     its TTL rather than with the end of a "run" command.
+//   - The job has no max attempts or max fails, leases expiring on TTR and
+//     failures follow the server's defaults for background jobs rather than
+//     those of "run".
+//   - Results are observed up to one poll interval after the job completes.
+//   - Polling relies on the server accepting a zero "result" timeout, see the
+//     ResultZeroTimeout subtest of workqtest.RunConformance.
+//
+// Use Run on a dedicated connection where the semantics of "run" matter.
+//
+// The future fails with a "TIMED-OUT" ResponseError when no result is
+// available within j.Timeout. RunAsync blocks until the job is added or
+// adding it failed, in which case the future fails with the error.
+func (p *Pool) RunAsync(j *FgJob) *Future {
+	f := newFuture(p, j.ID)
+	err := p.Add(&BgJob{
+		ID:       j.ID,
+		Name:     j.Name,
+		TTR:      j.TTR,
+		TTL:      j.Timeout + int(p.interval/time.Millisecond),
+		Payload:  j.Payload,
+		Priority: j.Priority,
+		Headers:  j.Headers,
+	})
+	if err != nil {
+		f.resolve(nil, err)
+		return f
+	}
+
+	p.add(f, j.Timeout)
+	return f
+}
+
+// ResultAsync returns a Future of the result of job id, failing with a
+// "TIMED-OUT" ResponseError when no result is available within timeout
+// milliseconds.
+func (p *Pool) ResultAsync(id string, timeout int) *Future {
+	f := newFuture(p, id)
+	p.add(f, timeout)
+	return f
+}
+
+// Add a background job on a pooled connection, see Client.Add.
+func (p *Pool) Add(j *BgJob) error {
+	c, err := p.get()
+	if err != nil {
+		return err
+	}
+
+	err = c.Add(j)
+	p.put(c, err)
+	return err
+}
+
+// Delete a job on a pooled connection, see Client.Delete.
+func (p *Pool) Delete(id string) error {
+	c, err := p.get()
+	if err != nil {
+		return err
+	}
+
+	err = c.Delete(id)
+	p.put(c, err)
+	return err
+}
+
+// Close the pool and its connections.
+// Pending futures fail with ErrPoolClosed.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.isClosed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.isClosed = true
+	close(p.closed)
+	pending := p.pending
+	p.pending = make(map[*Future]struct{})
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	for f := range pending {
+		f.resolve(nil, ErrPoolClosed)
+	}
+
+	for {
+		select {
+		case c := <-p.idle:
+			c.Close()
+			<-p.slots
+		default:
+			return nil
+		}
+	}
+}
+
+// Add a pending future expiring after timeout milliseconds.
+func (p *Pool) add(f *Future, timeout int) {
+	f.deadline = p.clock.Now().Add(time.Duration(timeout) * time.Millisecond)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.isClosed {
+		f.resolve(nil, ErrPoolClosed)
+		return
+	}
+
+	p.pending[f] = struct{}{}
+}
+
+// Remove a pending future.
+func (p *Pool) remove(f *Future) {
+	p.mu.Lock()
+	delete(p.pending, f)
+	p.mu.Unlock()
+}
+
+// Return an idle connection, opening one while below the pool size and
+// waiting for one otherwise.
+// Closed is checked in selects of its own, a select picks at random among
+// ready cases and would hand out idle connections of a closed pool.
+func (p *Pool) get() (*Client, error) {
+	select {
+	case <-p.closed:
+		return nil, ErrPoolClosed
+	default:
+	}
+
+	var c *Client
+	select {
+	case c = <-p.idle:
+	default:
+		select {
+		case c = <-p.idle:
+		case p.slots <- struct{}{}:
+			var err error
+			c, err = p.dial()
+			if err != nil {
+				<-p.slots
+				return nil, err
+			}
+		case <-p.closed:
+			return nil, ErrPoolClosed
+		}
+	}
+
+	// Closed while getting the connection.
+	select {
+	case <-p.closed:
+		p.put(c, nil)
+		return nil, ErrPoolClosed
+	default:
+		return c, nil
+	}
+}
+
+// Return a connection to the pool after a command returning err.
+// Connections are closed after errors leaving them in an unknown state, a new
+// connection is dialed in their place when needed. Closing them is logged
+// through the logger of the connection, see WithLogger.
+func (p *Pool) put(c *Client, err error) {
+	_, ok := err.(*ResponseError)
+	reuse := err == nil || ok || errors.Is(err, ErrInvalidHeader)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !reuse || p.isClosed {
+		if !p.isClosed {
+			c.log.reconnect(err)
+		}
+		c.Close()
+		<-p.slots
+		return
+	}
+
+	p.idle <- c
+}
+
+// Poll the results of pending futures every poll interval until closed.
+func (p *Pool) poll() {
+	defer p.wg.Done()
+	for {
+		tick, stop := p.clock.After(p.interval)
+		select {
+		case <-tick:
+			p.pollPending()
+		case <-p.closed:
+			stop()
+			return
+		}
+	}
+}
+
+// Poll the results of all pending futures once.
+func (p *Pool) pollPending() {
+	p.mu.Lock()
+	futures := make([]*Future, 0, len(p.pending))
+	for f := range p.pending {
+		futures = append(futures, f)
+	}
+	p.mu.Unlock()
+	if len(futures) == 0 {
+		return
+	}
+
+	c, err := p.get()
+	if err != nil {
+		p.expire(futures, err)
+		return
+	}
+
+	for start := 0; start < len(futures); start += maxPipelinedResults {
+		batch := futures[start:]
+		if len(batch) > maxPipelinedResults {
+			batch = batch[:maxPipelinedResults]
+		}
+
+		ids := make([]string, len(batch))
+		for i, f := range batch {
+			ids[i] = f.id
+		}
+
+		results, errs, err := c.pollResults(ids)
+		if err != nil {
+			p.put(c, err)
+			p.expire(futures[start:], err)
+			return
+		}
+
+		now := p.clock.Now()
+		for i, f := range batch {
+			switch {
+			case errs[i] == nil:
+				p.finish(f, results[i], nil)
+			case !now.Before(f.deadline):
+				p.finish(f, nil, NewResponseError("TIMED-OUT", ""))
+			case errs[i].(*ResponseError).Code() != "TIMED-OUT":
+				p.finish(f, nil, errs[i])
+			}
+		}
+	}
+
+	p.put(c, nil)
+}
+
+// Fail futures past their deadline with err after a failed poll, the others
+// are polled again.
+func (p *Pool) expire(futures []*Future, err error) {
+	now := p.clock.Now()
+	for _, f := range futures {
+		if !now.Before(f.deadline) {
+			p.finish(f, nil, err)
+		}
+	}
+}
+
+// Remove a pending future and resolve it.
+func (p *Pool) finish(f *Future, result *JobResult, err error) {
+	p.remove(f)
+	f.resolve(result, err)
+}
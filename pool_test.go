@@ -0,0 +1,231 @@
+package workq_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/iamduo/go-workq"
+	"github.com/iamduo/go-workq/workqtest"
+)
+
+func jobID(i int) string {
+	return fmt.Sprintf("%08x-9dad-11d1-80b4-00c04fd430c4", i)
+}
+
+func newTestPool(srv *workqtest.Server, size int) *workq.Pool {
+	return workq.NewPool(func() (*workq.Client, error) {
+		return srv.Client(), nil
+	}, size, workq.WithPollInterval(5*time.Millisecond))
+}
+
+func expCode(t *testing.T, err error, code string) {
+	var rerr *workq.ResponseError
+	if !errors.As(err, &rerr) || rerr.Code() != code {
+		t.Fatalf("Error mismatch, exp=%s, act=%v", code, err)
+	}
+}
+
+func TestPoolRunAsync(t *testing.T) {
+	srv := workqtest.NewUnstartedServer()
+	defer srv.Close()
+	pool := newTestPool(srv, 2)
+	defer pool.Close()
+
+	// More outstanding jobs than pipelined in a single batch.
+	n := 150
+	futures := make([]*workq.Future, n)
+	for i := range futures {
+		futures[i] = pool.RunAsync(&workq.FgJob{
+			ID:      jobID(i),
+			Name:    "j1",
+			TTR:     1000,
+			Timeout: 10000,
+			Payload: []byte(fmt.Sprint(i)),
+		})
+	}
+
+	go func() {
+		worker := srv.Client()
+		for i := 0; i < n; i++ {
+			j, err := worker.Lease([]string{"j1"}, 1000)
+			if err != nil {
+				return
+			}
+			worker.Complete(j.ID, j.Payload)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for i, f := range futures {
+		if f.ID() != jobID(i) {
+			t.Fatalf("ID mismatch, act=%s", f.ID())
+		}
+
+		result, err := f.Wait(ctx)
+		if err != nil {
+			t.Fatalf("Wait failed, err=%s", err)
+		}
+		if !result.Success || string(result.Result) != fmt.Sprint(i) {
+			t.Fatalf("Result mismatch, result=%+v", result)
+		}
+
+		select {
+		case <-f.Done():
+		default:
+			t.Fatal("Future not done")
+		}
+	}
+}
+
+func TestPoolResultAsync(t *testing.T) {
+	srv := workqtest.NewUnstartedServer()
+	defer srv.Close()
+	pool := newTestPool(srv, 1)
+	defer pool.Close()
+
+	client := srv.Client()
+	err := client.Add(&workq.BgJob{ID: jobID(1), Name: "j1", TTR: 1000, TTL: 60000})
+	if err != nil {
+		t.Fatalf("Add failed, err=%s", err)
+	}
+
+	f := pool.ResultAsync(jobID(1), 10000)
+	j, err := client.Lease([]string{"j1"}, 1000)
+	if err != nil {
+		t.Fatalf("Lease failed, err=%s", err)
+	}
+	err = client.Fail(j.ID, []byte("e"))
+	if err != nil {
+		t.Fatalf("Fail failed, err=%s", err)
+	}
+
+	result, err := f.Wait(context.Background())
+	if err != nil || result.Success || string(result.Result) != "e" {
+		t.Fatalf("Result mismatch, result=%+v, err=%v", result, err)
+	}
+}
+
+func TestPoolErrors(t *testing.T) {
+	srv := workqtest.NewUnstartedServer()
+	defer srv.Close()
+	pool := newTestPool(srv, 1)
+	defer pool.Close()
+
+	_, err := pool.ResultAsync(jobID(1), 10000).Wait(context.Background())
+	expCode(t, err, "NOT-FOUND")
+
+	err = pool.Add(&workq.BgJob{ID: jobID(1), Name: "j1", TTR: 1000, TTL: 60000})
+	if err != nil {
+		t.Fatalf("Add failed, err=%s", err)
+	}
+	_, err = pool.ResultAsync(jobID(1), 0).Wait(context.Background())
+	expCode(t, err, "TIMED-OUT")
+
+	// Duplicate job.
+	pool.RunAsync(&workq.FgJob{ID: jobID(2), Name: "j1", TTR: 1000, Timeout: 60000})
+	f := pool.RunAsync(&workq.FgJob{ID: jobID(2), Name: "j1", TTR: 1000, Timeout: 60000})
+	select {
+	case <-f.Done():
+	default:
+		t.Fatal("Future not done after failed add")
+	}
+	_, err = f.Wait(context.Background())
+	expCode(t, err, "CLIENT-ERROR")
+}
+
+func TestPoolExpiry(t *testing.T) {
+	clock := workqtest.NewFakeClock(time.Now())
+	srv := workqtest.NewUnstartedServer(workqtest.WithClock(clock))
+	defer srv.Close()
+	pool := workq.NewPool(func() (*workq.Client, error) {
+		return srv.Client(), nil
+	}, 1, workq.WithPollInterval(100*time.Millisecond), workq.WithPoolClock(clock))
+	defer pool.Close()
+
+	// Job without result within timeout.
+	f := pool.RunAsync(&workq.FgJob{ID: jobID(1), Name: "j1", TTR: 1000, Timeout: 1000})
+
+	// Wait for the poller between advances, it polls once per interval.
+	clock.BlockUntil(1)
+	clock.Advance(900 * time.Millisecond)
+	clock.BlockUntil(1)
+	select {
+	case <-f.Done():
+		t.Fatal("Future done before timeout")
+	default:
+	}
+
+	clock.Advance(100 * time.Millisecond)
+	clock.BlockUntil(1)
+	select {
+	case <-f.Done():
+	default:
+		t.Fatal("Future not done after timeout")
+	}
+	_, err := f.Wait(context.Background())
+	expCode(t, err, "TIMED-OUT")
+}
+
+func TestPoolDialError(t *testing.T) {
+	dialErr := errors.New("dial")
+	pool := workq.NewPool(func() (*workq.Client, error) {
+		return nil, dialErr
+	}, 1)
+	defer pool.Close()
+
+	_, err := pool.RunAsync(&workq.FgJob{ID: jobID(1), Name: "j1", TTR: 1000, Timeout: 1000}).Wait(context.Background())
+	if err != dialErr {
+		t.Fatalf("Error mismatch, err=%v", err)
+	}
+}
+
+func TestFutureWaitCancel(t *testing.T) {
+	srv := workqtest.NewUnstartedServer()
+	defer srv.Close()
+	pool := newTestPool(srv, 1)
+	defer pool.Close()
+
+	f := pool.RunAsync(&workq.FgJob{ID: jobID(1), Name: "j1", TTR: 1000, Timeout: 60000})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := f.Wait(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Error mismatch, err=%v", err)
+	}
+
+	f.Cancel()
+	_, err = f.Wait(context.Background())
+	if err != context.Canceled {
+		t.Fatalf("Error mismatch, err=%v", err)
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	srv := workqtest.NewUnstartedServer()
+	defer srv.Close()
+	pool := newTestPool(srv, 1)
+
+	f := pool.RunAsync(&workq.FgJob{ID: jobID(1), Name: "j1", TTR: 1000, Timeout: 60000})
+	err := pool.Close()
+	if err != nil {
+		t.Fatalf("Close failed, err=%s", err)
+	}
+
+	_, err = f.Wait(context.Background())
+	if err != workq.ErrPoolClosed {
+		t.Fatalf("Error mismatch, err=%v", err)
+	}
+
+	_, err = pool.RunAsync(&workq.FgJob{ID: jobID(2), Name: "j1", TTR: 1000, Timeout: 1000}).Wait(context.Background())
+	if err != workq.ErrPoolClosed {
+		t.Fatalf("Error mismatch, err=%v", err)
+	}
+	_, err = pool.ResultAsync(jobID(1), 1000).Wait(context.Background())
+	if err != workq.ErrPoolClosed {
+		t.Fatalf("Error mismatch, err=%v", err)
+	}
+}
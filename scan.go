@@ -0,0 +1,112 @@
+package workq
+
+import "context"
+
+// DefaultScanPageSize is the page size used by ScanJobs when none is given.
+const DefaultScanPageSize = 100
+
+// JobScanner pages through inspected jobs of a name using "inspect jobs".
+//
+//	s := client.ScanJobs(ctx, "ping", 100, 0)
+//	for s.Scan() {
+//		job := s.Job()
+//		// ...
+//	}
+//	if err := s.Err(); err != nil {
+//		// ...
+//	}
+type JobScanner struct {
+	admin    Admin
+	ctx      context.Context
+	name     string
+	pageSize int
+	max      int
+
+	page   []*InspectedJob
+	offset int
+	seen   int
+	job    *InspectedJob
+	err    error
+	done   bool
+}
+
+// ScanJobs returns a JobScanner over all jobs of name, fetching pageSize jobs
+// per "inspect jobs" command and stopping on the first empty page.
+// A pageSize <= 0 uses DefaultScanPageSize, a max > 0 stops after max jobs.
+// The context is checked before each page is fetched.
+func (c *Client) ScanJobs(ctx context.Context, name string, pageSize int, max int) *JobScanner {
+	return NewJobScanner(ctx, c, name, pageSize, max)
+}
+
+// NewJobScanner returns a JobScanner over all jobs of name inspected through
+// admin, see Client.ScanJobs.
+func NewJobScanner(ctx context.Context, admin Admin, name string, pageSize int, max int) *JobScanner {
+	if pageSize <= 0 {
+		pageSize = DefaultScanPageSize
+	}
+
+	return &JobScanner{
+		admin:    admin,
+		ctx:      ctx,
+		name:     name,
+		pageSize: pageSize,
+		max:      max,
+	}
+}
+
+// Scan advances to the next job, fetching the next page when required.
+// Returns false when all jobs have been scanned or an error occurred.
+func (s *JobScanner) Scan() bool {
+	s.job = nil
+	if s.err != nil || s.done {
+		return false
+	}
+
+	if s.max > 0 && s.seen >= s.max {
+		s.done = true
+		return false
+	}
+
+	if len(s.page) == 0 {
+		err := s.ctx.Err()
+		if err != nil {
+			s.err = err
+			return false
+		}
+
+		limit := s.pageSize
+		if s.max > 0 && s.max-s.seen < limit {
+			limit = s.max - s.seen
+		}
+
+		page, err := s.admin.InspectJobs(s.name, s.offset, limit)
+		if err != nil {
+			s.err = err
+			return false
+		}
+
+		if len(page) == 0 {
+			s.done = true
+			return false
+		}
+
+		s.offset += len(page)
+		s.page = page
+	}
+
+	s.job = s.page[0]
+	s.page = s.page[1:]
+	s.seen++
+	return true
+}
+
+// Job returns the current job of the last successful Scan.
+func (s *JobScanner) Job() *InspectedJob {
+	return s.job
+}
+
+// Err returns the first error encountered while scanning, including context
+// cancellation.
+func (s *JobScanner) Err() error {
+	return s.err
+}
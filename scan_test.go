@@ -0,0 +1,145 @@
+package workq
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestScanJobs(t *testing.T) {
+	conn := &ScriptConn{resps: [][]byte{
+		inspectJobsResp(0, 2),
+		inspectJobsResp(2, 2),
+		inspectJobsResp(4, 1),
+		[]byte("+OK 0\r\n"),
+	}}
+	client := NewClient(conn)
+	s := client.ScanJobs(context.Background(), "ping", 2, 0)
+	var ids []string
+	for s.Scan() {
+		ids = append(ids, s.Job().ID)
+	}
+	if s.Err() != nil {
+		t.Fatalf("Scan mismatch, err=%s", s.Err())
+	}
+
+	if len(ids) != 5 || ids[0] != inspectJobID(0) || ids[4] != inspectJobID(4) {
+		t.Fatalf("Scan mismatch, ids=%v", ids)
+	}
+
+	expWrite := []byte(
+		"inspect jobs ping 0 2\r\n" +
+			"inspect jobs ping 2 2\r\n" +
+			"inspect jobs ping 4 2\r\n" +
+			"inspect jobs ping 5 2\r\n",
+	)
+	if !bytes.Equal(expWrite, conn.wrt.Bytes()) {
+		t.Fatalf("Write mismatch, act=%q", conn.wrt.Bytes())
+	}
+}
+
+func TestScanJobsMax(t *testing.T) {
+	conn := &ScriptConn{resps: [][]byte{
+		inspectJobsResp(0, 2),
+		inspectJobsResp(2, 1),
+	}}
+	client := NewClient(conn)
+	s := client.ScanJobs(context.Background(), "ping", 2, 3)
+	n := 0
+	for s.Scan() {
+		n++
+	}
+	if s.Err() != nil || n != 3 {
+		t.Fatalf("Scan mismatch, n=%d, err=%v", n, s.Err())
+	}
+
+	expWrite := []byte(
+		"inspect jobs ping 0 2\r\n" +
+			"inspect jobs ping 2 1\r\n",
+	)
+	if !bytes.Equal(expWrite, conn.wrt.Bytes()) {
+		t.Fatalf("Write mismatch, act=%q", conn.wrt.Bytes())
+	}
+}
+
+func TestScanJobsContextCancel(t *testing.T) {
+	conn := &ScriptConn{resps: [][]byte{
+		inspectJobsResp(0, 1),
+		inspectJobsResp(1, 1),
+	}}
+	client := NewClient(conn)
+	ctx, cancel := context.WithCancel(context.Background())
+	s := client.ScanJobs(ctx, "ping", 1, 0)
+	if !s.Scan() {
+		t.Fatalf("Scan mismatch, err=%v", s.Err())
+	}
+
+	cancel()
+	if s.Scan() || s.Err() != context.Canceled || s.Job() != nil {
+		t.Fatalf("Scan mismatch, err=%v", s.Err())
+	}
+}
+
+func TestScanJobsError(t *testing.T) {
+	conn := &ScriptConn{resps: [][]byte{
+		[]byte("-CLIENT-ERROR Invalid name\r\n"),
+	}}
+	client := NewClient(conn)
+	s := client.ScanJobs(context.Background(), "ping", 0, 0)
+	if s.Scan() {
+		t.Fatalf("Scan mismatch")
+	}
+
+	if s.Err() == nil || s.Err().Error() != "CLIENT-ERROR Invalid name" {
+		t.Fatalf("Error mismatch, err=%v", s.Err())
+	}
+
+	if !bytes.Equal([]byte("inspect jobs ping 0 100\r\n"), conn.wrt.Bytes()) {
+		t.Fatalf("Write mismatch, act=%q", conn.wrt.Bytes())
+	}
+}
+
+func inspectJobID(i int) string {
+	return fmt.Sprintf("6ba7b810-9dad-11d1-80b4-%012d", i)
+}
+
+// Build an "inspect jobs" response of n jobs starting at offset.
+func inspectJobsResp(offset int, n int) []byte {
+	resp := fmt.Sprintf("+OK %d\r\n", n)
+	for i := offset; i < offset+n; i++ {
+		resp += inspectJobID(i) + " 1\r\nname ping\r\n"
+	}
+
+	return []byte(resp)
+}
+
+// ScriptConn replies with the next scripted response on every write.
+type ScriptConn struct {
+	TestConn
+	resps [][]byte
+}
+
+func (c *ScriptConn) Read(b []byte) (int, error) {
+	if c.rdr == nil {
+		c.rdr = bytes.NewBuffer(nil)
+	}
+
+	return c.rdr.Read(b)
+}
+
+func (c *ScriptConn) Write(b []byte) (int, error) {
+	if c.wrt == nil {
+		c.wrt = bytes.NewBuffer(nil)
+	}
+	if c.rdr == nil {
+		c.rdr = bytes.NewBuffer(nil)
+	}
+
+	if len(c.resps) > 0 {
+		c.rdr.Write(c.resps[0])
+		c.resps = c.resps[1:]
+	}
+
+	return c.wrt.Write(b)
+}
@@ -0,0 +1,94 @@
+package workq
+
+import (
+	"io"
+	"strconv"
+	"sync"
+)
+
+// Serializes trace writes of all clients, which may share a writer.
+var traceMu sync.Mutex
+
+// wireTrace writes a transcript of protocol traffic, one event per line.
+// Commands are prefixed with "> " and replies with "< ". Lines are quoted so
+// that terminators and control characters remain visible in the transcript.
+//
+//	> "add 6ba7b810-9dad-11d1-80b4-00c04fd430c4 ping 5000 60000 5\r\n"
+//	> [block 5 bytes] "Ping!"
+//	< "+OK\r\n"
+type wireTrace struct {
+	w io.Writer
+	// Bytes of each data block included, 0 redacts, negative includes all.
+	maxBlock int
+	buf      []byte
+}
+
+// Trace a sent command line, without its terminator.
+func (t *wireTrace) sent(line string) {
+	if t == nil {
+		return
+	}
+
+	t.buf = append(t.buf[:0], "> "...)
+	t.buf = strconv.AppendQuote(t.buf, line+crnl)
+	t.flush()
+}
+
+// Trace a sent data block of size bytes, block is nil when streamed.
+func (t *wireTrace) sentBlock(block []byte, size int) {
+	if t == nil {
+		return
+	}
+
+	t.buf = append(t.buf[:0], "> "...)
+	t.appendBlock(block, size)
+	t.flush()
+}
+
+// Trace a received raw line, including its terminator if any.
+func (t *wireTrace) received(line []byte) {
+	if t == nil {
+		return
+	}
+
+	t.buf = append(t.buf[:0], "< "...)
+	t.buf = strconv.AppendQuote(t.buf, string(line))
+	t.flush()
+}
+
+// Trace a received data block of size bytes, block is nil when streamed.
+func (t *wireTrace) receivedBlock(block []byte, size int) {
+	if t == nil {
+		return
+	}
+
+	t.buf = append(t.buf[:0], "< "...)
+	t.appendBlock(block, size)
+	t.flush()
+}
+
+func (t *wireTrace) appendBlock(block []byte, size int) {
+	t.buf = append(t.buf, "[block "...)
+	t.buf = strconv.AppendInt(t.buf, int64(size), 10)
+	t.buf = append(t.buf, " bytes"...)
+	switch {
+	case block == nil:
+		t.buf = append(t.buf, " streamed]"...)
+	case t.maxBlock == 0:
+		t.buf = append(t.buf, " redacted]"...)
+	case t.maxBlock > 0 && len(block) > t.maxBlock:
+		t.buf = append(t.buf, "] "...)
+		t.buf = strconv.AppendQuote(t.buf, string(block[:t.maxBlock]))
+		t.buf = append(t.buf, "..."...)
+	default:
+		t.buf = append(t.buf, "] "...)
+		t.buf = strconv.AppendQuote(t.buf, string(block))
+	}
+}
+
+func (t *wireTrace) flush() {
+	t.buf = append(t.buf, '\n')
+	traceMu.Lock()
+	t.w.Write(t.buf)
+	traceMu.Unlock()
+}
@@ -0,0 +1,135 @@
+package workq
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWireTrace(t *testing.T) {
+	tests := []struct {
+		maxBlock int
+		exp      string
+	}{
+		{
+			maxBlock: 0,
+			exp: `> "add 6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 60 60000 5\r\n"` + "\n" +
+				`> [block 5 bytes redacted]` + "\n" +
+				`< "+OK\r\n"` + "\n" +
+				`> "lease j1 1000\r\n"` + "\n" +
+				`< "+OK 1\r\n"` + "\n" +
+				`< "6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1000 3\r\n"` + "\n" +
+				`< [block 3 bytes redacted]` + "\n",
+		},
+		{
+			maxBlock: 2,
+			exp: `> "add 6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 60 60000 5\r\n"` + "\n" +
+				`> [block 5 bytes] "Pi"...` + "\n" +
+				`< "+OK\r\n"` + "\n" +
+				`> "lease j1 1000\r\n"` + "\n" +
+				`< "+OK 1\r\n"` + "\n" +
+				`< "6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1000 3\r\n"` + "\n" +
+				`< [block 3 bytes] "ab"...` + "\n",
+		},
+		{
+			maxBlock: -1,
+			exp: `> "add 6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 60 60000 5\r\n"` + "\n" +
+				`> [block 5 bytes] "Ping!"` + "\n" +
+				`< "+OK\r\n"` + "\n" +
+				`> "lease j1 1000\r\n"` + "\n" +
+				`< "+OK 1\r\n"` + "\n" +
+				`< "6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1000 3\r\n"` + "\n" +
+				`< [block 3 bytes] "abc"` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		conn := &TestConn{
+			rdr: bytes.NewBuffer([]byte(
+				"+OK\r\n" +
+					"+OK 1\r\n" +
+					"6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1000 3\r\n" +
+					"abc\r\n",
+			)),
+			wrt: bytes.NewBuffer([]byte("")),
+		}
+		trace := &bytes.Buffer{}
+		client := NewClient(conn, WithWireTrace(trace, tt.maxBlock))
+		j := &BgJob{
+			ID:      "6ba7b810-9dad-11d1-80b4-00c04fd430c4",
+			Name:    "j1",
+			TTR:     60,
+			TTL:     60000,
+			Payload: []byte("Ping!"),
+		}
+		err := client.Add(j)
+		if err != nil {
+			t.Fatalf("Response mismatch, err=%s", err)
+		}
+
+		_, err = client.Lease([]string{"j1"}, 1000)
+		if err != nil {
+			t.Fatalf("Response mismatch, err=%s", err)
+		}
+
+		if trace.String() != tt.exp {
+			t.Fatalf("Trace mismatch, maxBlock=%d, act=\n%s", tt.maxBlock, trace)
+		}
+	}
+}
+
+func TestWireTraceStream(t *testing.T) {
+	conn := &TestConn{
+		rdr: bytes.NewBuffer([]byte("+OK\r\n")),
+		wrt: bytes.NewBuffer([]byte("")),
+	}
+	trace := &bytes.Buffer{}
+	client := NewClient(conn, WithWireTrace(trace, -1))
+	err := client.CompleteFrom("6ba7b810-9dad-11d1-80b4-00c04fd430c4", bytes.NewReader([]byte("abc")), 3)
+	if err != nil {
+		t.Fatalf("Response mismatch, err=%s", err)
+	}
+
+	exp := `> "complete 6ba7b810-9dad-11d1-80b4-00c04fd430c4 3\r\n"` + "\n" +
+		`> [block 3 bytes streamed]` + "\n" +
+		`< "+OK\r\n"` + "\n"
+	if trace.String() != exp {
+		t.Fatalf("Trace mismatch, act=\n%s", trace)
+	}
+}
+
+func TestWireTraceSharedWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	n := 100
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		conn := &TestConn{
+			rdr: bytes.NewBuffer(bytes.Repeat([]byte("+OK\r\n"), n)),
+			wrt: bytes.NewBuffer([]byte("")),
+		}
+		client := NewClient(conn, WithWireTrace(buf, 0))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < n; j++ {
+				client.Delete("6ba7b810-9dad-11d1-80b4-00c04fd430c4")
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Lines of clients sharing the writer don't interleave.
+	exp := map[string]int{
+		`> "delete 6ba7b810-9dad-11d1-80b4-00c04fd430c4\r\n"`: 4 * n,
+		`< "+OK\r\n"`: 4 * n,
+	}
+	act := map[string]int{}
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		act[line]++
+	}
+	if !reflect.DeepEqual(exp, act) {
+		t.Fatalf("Trace mismatch, act=%v", act)
+	}
+}
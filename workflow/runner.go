@@ -0,0 +1,292 @@
+package workflow
+
+import (
+	"context"
+
+	"github.com/iamduo/go-workq"
+	"github.com/satori/go.uuid"
+)
+
+// Namespace of the job IDs of workflow steps.
+var namespace = uuid.NewV5(uuid.NamespaceURL, "github.com/iamduo/go-workq/workflow")
+
+// Conn is the subset of workq.Client commands driving a workflow.
+type Conn interface {
+	Add(j *workq.BgJob) error
+	Complete(id string, result []byte) error
+	Fail(id string, result []byte) error
+	InspectJob(id string) (*workq.InspectedJob, error)
+}
+
+// Runner drives runs of a workflow over a workq connection.
+//
+// Steps are added as jobs with IDs derived from the run and step, and the
+// steps advanced past are recorded in the Store, so that advancing a run
+// again, such as after a job was leased again because its Complete failed,
+// does not add duplicate jobs.
+type Runner struct {
+	conn  Conn
+	wf    *Workflow
+	store Store
+}
+
+// Option configures a Runner.
+type Option func(*Runner)
+
+// WithStore sets the Store keeping the state of joins, defaults to a
+// MemoryStore.
+func WithStore(s Store) Option {
+	return func(r *Runner) {
+		r.store = s
+	}
+}
+
+// NewRunner returns a Runner of wf over conn.
+// wf must not be modified afterwards.
+// Returns ErrInvalidWorkflow if wf is not valid.
+func NewRunner(conn Conn, wf *Workflow, opts ...Option) (*Runner, error) {
+	err := wf.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Runner{conn: conn, wf: wf, store: NewMemoryStore()}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r, nil
+}
+
+// Start run of the workflow, adding its first steps with payload.
+// run identifies the run and must be unique for the workflow, starting the
+// same run again does not add its steps twice.
+func (r *Runner) Start(ctx context.Context, run string, payload []byte) error {
+	for _, s := range r.wf.start() {
+		err := r.add(run, s, payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Complete a leased job of the workflow, adding the steps following it on
+// success with result as payload before completing it.
+// Returns ErrNotWorkflowJob if j is not a job of the workflow.
+// Returns errors of the Conn and Store.
+func (r *Runner) Complete(ctx context.Context, j *workq.LeasedJob, result []byte) error {
+	run, s, err := r.jobStep(j)
+	if err != nil {
+		return err
+	}
+
+	err = r.advanceStep(ctx, run, s, s.OnSuccess, s.OnFailure, result)
+	if err != nil {
+		return err
+	}
+
+	return r.conn.Complete(j.ID, result)
+}
+
+// Fail a leased job of the workflow. When the failure is final, the steps
+// following it on failure are added with result as payload before failing it.
+// A failure is not final while the job is retried, as permitted by the
+// MaxFails and MaxAttempts of its step. Unless either limit rules out
+// retries, the job is inspected to tell.
+//
+// The job is inspected before it is failed, a lease expiring on TTR between
+// both fails the Fail command after the run was advanced. The job may then
+// be retried and complete, without advancing the run again.
+// Returns ErrNotWorkflowJob if j is not a job of the workflow.
+// Returns errors of the Conn and Store.
+func (r *Runner) Fail(ctx context.Context, j *workq.LeasedJob, result []byte) error {
+	run, s, err := r.jobStep(j)
+	if err != nil {
+		return err
+	}
+
+	final, err := r.finalFailure(j.ID, s)
+	if err != nil {
+		return err
+	}
+
+	if final {
+		err = r.advanceStep(ctx, run, s, s.OnFailure, s.OnSuccess, result)
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.conn.Fail(j.ID, result)
+}
+
+// Expire advances run past step as a final failure without result, unless it
+// was already advanced past it.
+//
+// Step jobs ending without Complete or Fail never advance their run, leaving
+// the steps following them, including joins, waiting forever. The server
+// fails jobs whose leases expired on TTR once their MaxAttempts are
+// exhausted, and evicts jobs on TTL expiry. Detect such steps, for example
+// with a failed state or a "NOT-FOUND" error when inspecting the job of
+// JobID, and expire them. A later Complete or Fail of the step's job no
+// longer advances the run.
+// Returns ErrNotWorkflowJob if step is not a step of the workflow.
+// Returns errors of the Conn and Store.
+func (r *Runner) Expire(ctx context.Context, run string, step string) error {
+	s, ok := r.wf.steps[step]
+	if !ok {
+		return ErrNotWorkflowJob
+	}
+
+	return r.advanceStep(ctx, run, s, s.OnFailure, s.OnSuccess, nil)
+}
+
+// Forget drops the state of run kept in the Store, once none of its steps
+// are advanced anymore. Advancing a step of a forgotten run adds the steps
+// following it again if their jobs were already deleted.
+func (r *Runner) Forget(ctx context.Context, run string) error {
+	return r.store.Forget(ctx, run)
+}
+
+// Report whether failing the leased job id of step s is final. The job is
+// retried while it failed fewer than MaxFails times, counting this failure,
+// and has attempts left within MaxAttempts.
+func (r *Runner) finalFailure(id string, s *Step) (bool, error) {
+	if s.MaxFails == 1 || s.MaxAttempts == 1 {
+		return true, nil
+	}
+
+	j, err := r.conn.InspectJob(id)
+	if err != nil {
+		return false, err
+	}
+
+	retried := j.MaxFails > 0 && j.Fails+1 < j.MaxFails && (j.MaxAttempts == 0 || j.Attempts < j.MaxAttempts)
+	return !retried, nil
+}
+
+// Return the run and step of a leased job.
+func (r *Runner) jobStep(j *workq.LeasedJob) (string, *Step, error) {
+	h := j.Headers()
+	if h.Get(HeaderWorkflow) != r.wf.Name || h.Get(HeaderRun) == "" {
+		return "", nil, ErrNotWorkflowJob
+	}
+
+	s, ok := r.wf.steps[h.Get(HeaderStep)]
+	if !ok {
+		return "", nil, ErrNotWorkflowJob
+	}
+
+	return h.Get(HeaderRun), s, nil
+}
+
+// Advance run past step s unless already advanced past, see advance.
+func (r *Runner) advanceStep(ctx context.Context, run string, s *Step, fired []string, skipped []string, result []byte) error {
+	advanced, err := r.store.Advanced(ctx, run, s.Name)
+	if err != nil || advanced {
+		return err
+	}
+
+	err = r.advance(ctx, run, s, fired, skipped, result)
+	if err != nil {
+		return err
+	}
+
+	return r.store.SetAdvanced(ctx, run, s.Name)
+}
+
+// Take the edges from s to the fired steps with result and skip the others.
+func (r *Runner) advance(ctx context.Context, run string, s *Step, fired []string, skipped []string, result []byte) error {
+	for _, next := range fired {
+		err := r.resolve(ctx, run, s.Name, next, true, result)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, next := range skipped {
+		err := r.resolve(ctx, run, s.Name, next, false, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Resolve the edge from step from to step to, adding to once all of its
+// preceding steps are resolved and at least one of them fired, or skipping it
+// when none did.
+func (r *Runner) resolve(ctx context.Context, run string, from string, to string, fired bool, result []byte) error {
+	s := r.wf.steps[to]
+	n := len(r.wf.preds[to])
+	if n == 1 {
+		if fired {
+			return r.add(run, s, result)
+		}
+		return r.skip(ctx, run, s)
+	}
+
+	results, done, err := r.store.Resolve(ctx, run, to, from, fired, result, n)
+	if err != nil || !done {
+		return err
+	}
+
+	if len(results) == 0 {
+		return r.skip(ctx, run, s)
+	}
+
+	join := s.Join
+	if join == nil {
+		join = JoinJSON
+	}
+	payload, err := join(results)
+	if err != nil {
+		return err
+	}
+
+	return r.add(run, s, payload)
+}
+
+// Skip step s, skipping all edges from it.
+func (r *Runner) skip(ctx context.Context, run string, s *Step) error {
+	return r.advance(ctx, run, s, nil, append(append([]string(nil), s.OnSuccess...), s.OnFailure...), nil)
+}
+
+// Add the job of step s of run.
+// A job already added for the step is not an error. The text of the
+// "CLIENT-ERROR" returned for duplicate IDs is not part of the protocol, the
+// job is inspected to confirm it exists instead.
+func (r *Runner) add(run string, s *Step, payload []byte) error {
+	id := JobID(r.wf.Name, run, s.Name)
+	err := r.conn.Add(&workq.BgJob{
+		ID:          id,
+		Name:        s.Name,
+		TTR:         s.TTR,
+		TTL:         s.TTL,
+		Payload:     payload,
+		Priority:    s.Priority,
+		MaxAttempts: s.MaxAttempts,
+		MaxFails:    s.MaxFails,
+		Headers: workq.Headers{
+			HeaderWorkflow: r.wf.Name,
+			HeaderRun:      run,
+			HeaderStep:     s.Name,
+		},
+	})
+	if rerr, ok := err.(*workq.ResponseError); ok && rerr.Code() == "CLIENT-ERROR" {
+		if _, ierr := r.conn.InspectJob(id); ierr == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// JobID returns the job ID of step of a run of workflow, for inspecting or
+// deleting the jobs of a run.
+func JobID(workflow string, run string, step string) string {
+	return uuid.NewV5(namespace, workflow+"/"+run+"/"+step).String()
+}
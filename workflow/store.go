@@ -0,0 +1,110 @@
+package workflow
+
+import (
+	"context"
+	"sync"
+)
+
+// Store keeps the state of workflow runs: the steps advanced past and the
+// joins waiting on preceding steps.
+type Store interface {
+	// Resolve records that the edge from step from to join step to of run was
+	// taken with result when fired, or skipped otherwise. Once all n edges to
+	// the join are resolved it returns the results of the fired edges keyed by
+	// step name with done true. This happens once per run, the join is kept
+	// as resolved and later calls return done false until the run is
+	// forgotten.
+	Resolve(ctx context.Context, run string, to string, from string, fired bool, result []byte, n int) (results map[string][]byte, done bool, err error)
+	// Advanced reports whether step of run was advanced past.
+	Advanced(ctx context.Context, run string, step string) (bool, error)
+	// SetAdvanced records that step of run was advanced past.
+	SetAdvanced(ctx context.Context, run string, step string) error
+	// Forget drops all state of run.
+	Forget(ctx context.Context, run string) error
+}
+
+// MemoryStore is a Store keeping runs in memory, for workflows driven by a
+// single process. Runs are lost when the process exits.
+type MemoryStore struct {
+	mu   sync.Mutex
+	runs map[string]*runState
+}
+
+type runState struct {
+	advanced map[string]bool
+	joins    map[string]*join
+}
+
+type join struct {
+	resolved map[string]bool
+	results  map[string][]byte
+	done     bool
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{runs: make(map[string]*runState)}
+}
+
+// Return the state of run, creating it if needed. Requires s.mu.
+func (s *MemoryStore) run(run string) *runState {
+	r, ok := s.runs[run]
+	if !ok {
+		r = &runState{advanced: make(map[string]bool), joins: make(map[string]*join)}
+		s.runs[run] = r
+	}
+
+	return r
+}
+
+// Resolve implements Store.
+func (s *MemoryStore) Resolve(ctx context.Context, run string, to string, from string, fired bool, result []byte, n int) (map[string][]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.run(run)
+	j, ok := r.joins[to]
+	if !ok {
+		j = &join{resolved: make(map[string]bool), results: make(map[string][]byte)}
+		r.joins[to] = j
+	}
+	if j.done {
+		return nil, false, nil
+	}
+
+	j.resolved[from] = true
+	if fired {
+		j.results[from] = result
+	}
+	if len(j.resolved) < n {
+		return nil, false, nil
+	}
+
+	results := j.results
+	j.resolved, j.results, j.done = nil, nil, true
+	return results, true, nil
+}
+
+// Advanced implements Store.
+func (s *MemoryStore) Advanced(ctx context.Context, run string, step string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.runs[run]
+	return ok && r.advanced[step], nil
+}
+
+// SetAdvanced implements Store.
+func (s *MemoryStore) SetAdvanced(ctx context.Context, run string, step string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.run(run).advanced[step] = true
+	return nil
+}
+
+// Forget implements Store.
+func (s *MemoryStore) Forget(ctx context.Context, run string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.runs, run)
+	return nil
+}
@@ -0,0 +1,181 @@
+// Package workflow chains workq jobs into DAG workflows such as "resize, then
+// thumbnail, then notify".
+//
+// A Workflow is a set of steps, each run as a job named after the step. When
+// a step completes, its result becomes the payload of the steps following it
+// on success, and when it fails, the payload of the steps following it on
+// failure. A step following several steps is a join and runs once all of them
+// finished, with their results combined by the step's Join function.
+//
+//	w := workflow.New("media")
+//	w.Add(workflow.Step{Name: "resize", TTR: 5000, TTL: 60000, OnSuccess: []string{"thumbnail"}, OnFailure: []string{"report"}})
+//	w.Add(workflow.Step{Name: "thumbnail", TTR: 5000, TTL: 60000, OnSuccess: []string{"notify"}})
+//	w.Add(workflow.Step{Name: "notify", TTR: 5000, TTL: 60000})
+//	w.Add(workflow.Step{Name: "report", TTR: 5000, TTL: 60000})
+//
+// Workflows are driven by the Add, Complete and Fail commands through a
+// Runner, workers lease the jobs of the steps with Lease as usual. The
+// workflow, run and step of a job travel in its envelope headers, joins keep
+// their state in a pluggable Store.
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/iamduo/go-workq"
+)
+
+// Job headers carrying the workflow state of a job.
+const (
+	HeaderWorkflow = "workflow"
+	HeaderRun      = "workflow-run"
+	HeaderStep     = "workflow-step"
+)
+
+var (
+	// ErrInvalidWorkflow is returned by Validate for an invalid workflow
+	// definition.
+	ErrInvalidWorkflow = errors.New("Invalid workflow")
+	// ErrNotWorkflowJob is returned by Runner for jobs not belonging to its
+	// workflow.
+	ErrNotWorkflowJob = errors.New("Job is not part of the workflow")
+)
+
+// Step of a workflow, run as a job named after the step.
+type Step struct {
+	Name        string
+	TTR         int
+	TTL         int
+	Priority    int
+	MaxAttempts int
+	MaxFails    int
+
+	// Steps run with the result of this step when it completes.
+	OnSuccess []string
+	// Steps run with the result of this step when it fails.
+	OnFailure []string
+
+	// Join combines the results of the steps preceding a join into its
+	// payload, keyed by step name. Only results of preceding steps that ran
+	// and took the edge to this step are included. Defaults to JoinJSON.
+	Join func(results map[string][]byte) ([]byte, error)
+}
+
+// JoinJSON encodes the results of a join as a JSON object of step name to
+// result, results are base64 encoded strings as per encoding/json.
+func JoinJSON(results map[string][]byte) ([]byte, error) {
+	return json.Marshal(results)
+}
+
+// Workflow is a DAG of steps.
+type Workflow struct {
+	Name  string
+	steps map[string]*Step
+	order []string
+	// Preceding steps of every step.
+	preds map[string][]string
+}
+
+// New returns an empty workflow, name is carried by all of its jobs.
+func New(name string) *Workflow {
+	return &Workflow{
+		Name:  name,
+		steps: make(map[string]*Step),
+		preds: make(map[string][]string),
+	}
+}
+
+// Add a step to the workflow, replacing any step of the same name.
+func (w *Workflow) Add(s Step) {
+	if _, ok := w.steps[s.Name]; !ok {
+		w.order = append(w.order, s.Name)
+	}
+	w.steps[s.Name] = &s
+}
+
+// Names returns the job names of all steps in the order they were added, for
+// leasing the jobs of the workflow.
+func (w *Workflow) Names() []string {
+	return append([]string(nil), w.order...)
+}
+
+// Validate the workflow.
+// Returns ErrInvalidWorkflow if the workflow has no steps, a step name is not
+// a valid job name, a step follows an unknown step or the same step twice, or
+// the steps form a cycle.
+func (w *Workflow) Validate() error {
+	if len(w.order) == 0 {
+		return fmt.Errorf("%w: no steps", ErrInvalidWorkflow)
+	}
+
+	preds := make(map[string][]string)
+	for _, name := range w.order {
+		s := w.steps[name]
+		if !workq.ValidName(name) {
+			return fmt.Errorf("%w: step %q is not a valid job name", ErrInvalidWorkflow, name)
+		}
+
+		seen := make(map[string]bool)
+		for _, next := range append(append([]string(nil), s.OnSuccess...), s.OnFailure...) {
+			if _, ok := w.steps[next]; !ok {
+				return fmt.Errorf("%w: step %q follows unknown step %q", ErrInvalidWorkflow, next, name)
+			}
+			if seen[next] {
+				return fmt.Errorf("%w: step %q follows step %q twice", ErrInvalidWorkflow, next, name)
+			}
+			seen[next] = true
+			preds[next] = append(preds[next], name)
+		}
+	}
+
+	// Depth first search for back edges.
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("%w: cycle through step %q", ErrInvalidWorkflow, name)
+		case visited:
+			return nil
+		}
+
+		state[name] = visiting
+		s := w.steps[name]
+		for _, next := range append(append([]string(nil), s.OnSuccess...), s.OnFailure...) {
+			err := visit(next)
+			if err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+	for _, name := range w.order {
+		err := visit(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	w.preds = preds
+	return nil
+}
+
+// Return the steps without preceding steps.
+func (w *Workflow) start() []*Step {
+	var steps []*Step
+	for _, name := range w.order {
+		if len(w.preds[name]) == 0 {
+			steps = append(steps, w.steps[name])
+		}
+	}
+
+	return steps
+}
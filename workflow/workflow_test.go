@@ -0,0 +1,513 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iamduo/go-workq"
+	"github.com/iamduo/go-workq/workqtest"
+)
+
+// Lease and handle the jobs of w until none are left, returning the payload
+// received by every step. Steps succeed unless listed in fail, with their
+// payload and name as result.
+func drive(t *testing.T, client *workq.Client, r *Runner, w *Workflow, fail map[string]bool) map[string]string {
+	ctx := context.Background()
+	payloads := make(map[string]string)
+	for {
+		j, err := client.Lease(w.Names(), 10)
+		if rerr, ok := err.(*workq.ResponseError); ok && rerr.Code() == "TIMED-OUT" {
+			return payloads
+		}
+		if err != nil {
+			t.Fatalf("Lease failed, err=%s", err)
+		}
+
+		payloads[j.Name] = string(j.Payload)
+		result := []byte(string(j.Payload) + "+" + j.Name)
+		if fail[j.Name] {
+			err = r.Fail(ctx, j, result)
+		} else {
+			err = r.Complete(ctx, j, result)
+		}
+		if err != nil {
+			t.Fatalf("Advance failed, step=%s, err=%s", j.Name, err)
+		}
+	}
+}
+
+func newMediaWorkflow() *Workflow {
+	w := New("media")
+	w.Add(Step{Name: "resize", TTR: 1000, TTL: 60000, OnSuccess: []string{"thumbnail"}, OnFailure: []string{"report"}})
+	w.Add(Step{Name: "thumbnail", TTR: 1000, TTL: 60000, OnSuccess: []string{"notify"}})
+	w.Add(Step{Name: "notify", TTR: 1000, TTL: 60000})
+	w.Add(Step{Name: "report", TTR: 1000, TTL: 60000})
+	return w
+}
+
+func TestChain(t *testing.T) {
+	srv := workqtest.NewUnstartedServer()
+	defer srv.Close()
+	client := srv.Client()
+	w := newMediaWorkflow()
+	r, err := NewRunner(srv.Client(), w)
+	if err != nil {
+		t.Fatalf("NewRunner failed, err=%s", err)
+	}
+
+	err = r.Start(context.Background(), "run1", []byte("img"))
+	if err != nil {
+		t.Fatalf("Start failed, err=%s", err)
+	}
+	// Starting a run again does not add its steps twice.
+	err = r.Start(context.Background(), "run1", []byte("img"))
+	if err != nil {
+		t.Fatalf("Start failed, err=%s", err)
+	}
+
+	payloads := drive(t, client, r, w, nil)
+	exp := map[string]string{
+		"resize":    "img",
+		"thumbnail": "img+resize",
+		"notify":    "img+resize+thumbnail",
+	}
+	if len(payloads) != len(exp) {
+		t.Fatalf("Steps mismatch, act=%v", payloads)
+	}
+	for step, payload := range exp {
+		if payloads[step] != payload {
+			t.Fatalf("Payload mismatch, step=%s, act=%q", step, payloads[step])
+		}
+	}
+
+	j, err := client.InspectJob(JobID("media", "run1", "notify"))
+	if err != nil || j.Headers.Get(HeaderRun) != "run1" || j.Headers.Get(HeaderStep) != "notify" {
+		t.Fatalf("Job mismatch, job=%+v, err=%v", j, err)
+	}
+}
+
+func TestBranchOnFailure(t *testing.T) {
+	srv := workqtest.NewUnstartedServer()
+	defer srv.Close()
+	client := srv.Client()
+	w := newMediaWorkflow()
+	r, err := NewRunner(srv.Client(), w)
+	if err != nil {
+		t.Fatalf("NewRunner failed, err=%s", err)
+	}
+
+	err = r.Start(context.Background(), "run1", []byte("img"))
+	if err != nil {
+		t.Fatalf("Start failed, err=%s", err)
+	}
+
+	payloads := drive(t, client, r, w, map[string]bool{"resize": true})
+	if len(payloads) != 2 || payloads["report"] != "img+resize" {
+		t.Fatalf("Steps mismatch, act=%v", payloads)
+	}
+
+	result, err := client.Result(JobID("media", "run1", "resize"), 0)
+	if err != nil || result.Success {
+		t.Fatalf("Result mismatch, result=%+v, err=%v", result, err)
+	}
+}
+
+func TestFailRetried(t *testing.T) {
+	tests := []struct {
+		maxFails    int
+		maxAttempts int
+		fails       int
+		final       bool
+	}{
+		{maxFails: 3, fails: 1},
+		{maxFails: 3, fails: 3, final: true},
+		// Attempts exhausted before fails.
+		{maxFails: 3, maxAttempts: 2, fails: 2, final: true},
+		{maxFails: 0, maxAttempts: 3, fails: 1, final: true},
+	}
+
+	for _, tt := range tests {
+		srv := workqtest.NewUnstartedServer()
+		client := srv.Client()
+		w := New("media")
+		w.Add(Step{Name: "resize", TTR: 1000, TTL: 60000, MaxFails: tt.maxFails, MaxAttempts: tt.maxAttempts, OnSuccess: []string{"thumbnail"}, OnFailure: []string{"report"}})
+		w.Add(Step{Name: "thumbnail", TTR: 1000, TTL: 60000})
+		w.Add(Step{Name: "report", TTR: 1000, TTL: 60000})
+		r, err := NewRunner(srv.Client(), w)
+		if err != nil {
+			t.Fatalf("NewRunner failed, err=%s", err)
+		}
+
+		err = r.Start(context.Background(), "run1", []byte("img"))
+		if err != nil {
+			t.Fatalf("Start failed, err=%s", err)
+		}
+
+		// Fail resize fails times, completing it when it is retried.
+		for i := 0; i < tt.fails; i++ {
+			j, err := client.Lease([]string{"resize"}, 10)
+			if err != nil {
+				t.Fatalf("Lease failed, i=%d, err=%s", i, err)
+			}
+			err = r.Fail(context.Background(), j, []byte("error"))
+			if err != nil {
+				t.Fatalf("Fail failed, err=%s", err)
+			}
+		}
+		payloads := drive(t, client, r, w, nil)
+
+		_, err = client.InspectJob(JobID("media", "run1", "report"))
+		_, err2 := client.InspectJob(JobID("media", "run1", "thumbnail"))
+		if !tt.final {
+			if payloads["thumbnail"] != "img+resize" || err == nil || err2 != nil {
+				t.Fatalf("Steps mismatch, test=%+v, act=%v, report=%v, thumbnail=%v", tt, payloads, err, err2)
+			}
+		} else {
+			if payloads["report"] != "error" || err != nil || err2 == nil {
+				t.Fatalf("Steps mismatch, test=%+v, act=%v, report=%v, thumbnail=%v", tt, payloads, err, err2)
+			}
+		}
+
+		srv.Close()
+	}
+}
+
+func TestJoin(t *testing.T) {
+	tests := []struct {
+		fail map[string]bool
+		exp  map[string]string
+	}{
+		// Both branches taken.
+		{
+			exp: map[string]string{"b": "a+a", "c": "a+a"},
+		},
+		// Branch c skipped.
+		{
+			fail: map[string]bool{"b": true},
+			exp:  map[string]string{"c": "a+a"},
+		},
+	}
+
+	for _, tt := range tests {
+		srv := workqtest.NewUnstartedServer()
+		client := srv.Client()
+
+		// a -> b, a -> c, c -> d, b -> d on success, b -> e on failure.
+		w := New("join")
+		w.Add(Step{Name: "a", TTR: 1000, TTL: 60000, OnSuccess: []string{"b", "c"}})
+		w.Add(Step{Name: "b", TTR: 1000, TTL: 60000, OnSuccess: []string{"d"}, OnFailure: []string{"e"}})
+		w.Add(Step{Name: "c", TTR: 1000, TTL: 60000, OnSuccess: []string{"d"}})
+		w.Add(Step{Name: "d", TTR: 1000, TTL: 60000})
+		w.Add(Step{Name: "e", TTR: 1000, TTL: 60000})
+		r, err := NewRunner(srv.Client(), w, WithStore(NewMemoryStore()))
+		if err != nil {
+			t.Fatalf("NewRunner failed, err=%s", err)
+		}
+
+		err = r.Start(context.Background(), "run1", []byte("a"))
+		if err != nil {
+			t.Fatalf("Start failed, err=%s", err)
+		}
+
+		payloads := drive(t, client, r, w, tt.fail)
+		var act map[string][]byte
+		err = json.Unmarshal([]byte(payloads["d"]), &act)
+		if err != nil {
+			t.Fatalf("Join payload mismatch, act=%q", payloads["d"])
+		}
+		if len(act) != len(tt.exp) {
+			t.Fatalf("Join results mismatch, act=%v", act)
+		}
+		for step, result := range tt.exp {
+			if string(act[step]) != result+"+"+step {
+				t.Fatalf("Join result mismatch, step=%s, act=%q", step, act[step])
+			}
+		}
+
+		srv.Close()
+	}
+}
+
+func TestJoinSkipped(t *testing.T) {
+	srv := workqtest.NewUnstartedServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	// Both steps preceding the join c are skipped when a fails.
+	w := New("skip")
+	w.Add(Step{Name: "a", TTR: 1000, TTL: 60000, OnSuccess: []string{"b1", "b2"}})
+	w.Add(Step{Name: "b1", TTR: 1000, TTL: 60000, OnSuccess: []string{"c"}})
+	w.Add(Step{Name: "b2", TTR: 1000, TTL: 60000, OnSuccess: []string{"c"}})
+	w.Add(Step{Name: "c", TTR: 1000, TTL: 60000})
+	store := NewMemoryStore()
+	r, err := NewRunner(srv.Client(), w, WithStore(store))
+	if err != nil {
+		t.Fatalf("NewRunner failed, err=%s", err)
+	}
+
+	r.Start(context.Background(), "run1", nil)
+	payloads := drive(t, client, r, w, map[string]bool{"a": true})
+	if len(payloads) != 1 {
+		t.Fatalf("Steps mismatch, act=%v", payloads)
+	}
+	if j := store.runs["run1"].joins["c"]; j == nil || !j.done {
+		t.Fatalf("Join not resolved, act=%+v", j)
+	}
+	r.Forget(context.Background(), "run1")
+	if len(store.runs) != 0 {
+		t.Fatalf("Runs not forgotten, act=%v", store.runs)
+	}
+}
+
+// Conn failing the first Complete.
+type failCompleteConn struct {
+	Conn
+	failed bool
+}
+
+func (c *failCompleteConn) Complete(id string, result []byte) error {
+	if !c.failed {
+		c.failed = true
+		return errors.New("complete failed")
+	}
+
+	return c.Conn.Complete(id, result)
+}
+
+func TestCompleteAgain(t *testing.T) {
+	srv := workqtest.NewUnstartedServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	w := New("again")
+	w.Add(Step{Name: "a", TTR: 1000, TTL: 60000, OnSuccess: []string{"c"}})
+	w.Add(Step{Name: "b", TTR: 1000, TTL: 60000, OnSuccess: []string{"c"}})
+	w.Add(Step{Name: "c", TTR: 1000, TTL: 60000})
+	store := NewMemoryStore()
+	r, err := NewRunner(srv.Client(), w, WithStore(store))
+	if err != nil {
+		t.Fatalf("NewRunner failed, err=%s", err)
+	}
+	r.Start(context.Background(), "run1", nil)
+
+	j, err := client.Lease([]string{"b"}, 10)
+	if err != nil {
+		t.Fatalf("Lease failed, err=%s", err)
+	}
+	err = r.Complete(context.Background(), j, []byte("b"))
+	if err != nil {
+		t.Fatalf("Complete failed, err=%s", err)
+	}
+
+	// Completing a resolves the join but fails, completing it again does not
+	// resolve the join again.
+	j, err = client.Lease([]string{"a"}, 10)
+	if err != nil {
+		t.Fatalf("Lease failed, err=%s", err)
+	}
+	fr, err := NewRunner(&failCompleteConn{Conn: srv.Client()}, w, WithStore(store))
+	if err != nil {
+		t.Fatalf("NewRunner failed, err=%s", err)
+	}
+	err = fr.Complete(context.Background(), j, []byte("a"))
+	if err == nil {
+		t.Fatal("Expected Complete error")
+	}
+	err = fr.Complete(context.Background(), j, []byte("a"))
+	if err != nil {
+		t.Fatalf("Complete failed, err=%s", err)
+	}
+
+	payloads := drive(t, client, r, w, nil)
+	if len(payloads) != 1 || payloads["c"] != `{"a":"YQ==","b":"Yg=="}` {
+		t.Fatalf("Steps mismatch, act=%v", payloads)
+	}
+	if j := store.runs["run1"].joins["c"]; j == nil || !j.done || j.resolved != nil {
+		t.Fatalf("Join state mismatch, act=%+v", j)
+	}
+	r.Forget(context.Background(), "run1")
+	if len(store.runs) != 0 {
+		t.Fatalf("Runs not forgotten, act=%v", store.runs)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	for _, ttl := range []bool{false, true} {
+		clock := workqtest.NewFakeClock(time.Now())
+		srv := workqtest.NewUnstartedServer(workqtest.WithClock(clock))
+		client := srv.Client()
+
+		// a expires either on TTR after its single attempt or on TTL, b joins
+		// c alone and a takes its failure edge to report.
+		w := New("expire")
+		w.Add(Step{Name: "a", TTR: 1000, TTL: 5000, MaxAttempts: 1, OnSuccess: []string{"c"}, OnFailure: []string{"report"}})
+		w.Add(Step{Name: "b", TTR: 1000, TTL: 60000, OnSuccess: []string{"c"}})
+		w.Add(Step{Name: "c", TTR: 1000, TTL: 60000})
+		w.Add(Step{Name: "report", TTR: 1000, TTL: 60000})
+		r, err := NewRunner(srv.Client(), w)
+		if err != nil {
+			t.Fatalf("NewRunner failed, err=%s", err)
+		}
+		r.Start(context.Background(), "run1", []byte("in"))
+
+		j, err := client.Lease([]string{"b"}, 0)
+		if err != nil {
+			t.Fatalf("Lease failed, err=%s", err)
+		}
+		err = r.Complete(context.Background(), j, []byte("b"))
+		if err != nil {
+			t.Fatalf("Complete failed, err=%s", err)
+		}
+
+		id := JobID("expire", "run1", "a")
+		if ttl {
+			clock.Advance(5 * time.Second)
+			_, err = client.InspectJob(id)
+			if rerr, ok := err.(*workq.ResponseError); !ok || rerr.Code() != "NOT-FOUND" {
+				t.Fatalf("Inspect mismatch, err=%v", err)
+			}
+		} else {
+			_, err = client.Lease([]string{"a"}, 0)
+			if err != nil {
+				t.Fatalf("Lease failed, err=%s", err)
+			}
+			clock.Advance(time.Second)
+			inspected, err := client.InspectJob(id)
+			if err != nil || inspected.State != workq.JobStateFailed {
+				t.Fatalf("Inspect mismatch, act=%+v, err=%v", inspected, err)
+			}
+		}
+
+		for i := 0; i < 2; i++ {
+			err = r.Expire(context.Background(), "run1", "a")
+			if err != nil {
+				t.Fatalf("Expire failed, err=%s", err)
+			}
+		}
+
+		payloads := make(map[string]string)
+		for i := 0; i < 2; i++ {
+			j, err := client.Lease([]string{"c", "report"}, 0)
+			if err != nil {
+				t.Fatalf("Lease failed, ttl=%v, err=%s", ttl, err)
+			}
+			payloads[j.Name] = string(j.Payload)
+		}
+		if payloads["c"] != `{"b":"Yg=="}` || payloads["report"] != "" {
+			t.Fatalf("Steps mismatch, ttl=%v, act=%v", ttl, payloads)
+		}
+
+		err = r.Expire(context.Background(), "run1", "x")
+		if err != ErrNotWorkflowJob {
+			t.Fatalf("Error mismatch, err=%v", err)
+		}
+
+		srv.Close()
+	}
+}
+
+// Conn returning "CLIENT-ERROR" with text for every failed add, and for adds
+// of jobs named invalid without adding them.
+type clientErrorConn struct {
+	Conn
+	text    string
+	invalid string
+}
+
+func (c *clientErrorConn) Add(j *workq.BgJob) error {
+	if j.Name == c.invalid {
+		return workq.NewResponseError("CLIENT-ERROR", c.text)
+	}
+
+	err := c.Conn.Add(j)
+	if err != nil {
+		return workq.NewResponseError("CLIENT-ERROR", c.text)
+	}
+
+	return nil
+}
+
+func TestAddClientError(t *testing.T) {
+	srv := workqtest.NewUnstartedServer()
+	defer srv.Close()
+
+	// Duplicates are told apart by inspecting the job, not by the error text.
+	conn := &clientErrorConn{Conn: srv.Client(), text: "ID in use", invalid: "thumbnail"}
+	r, err := NewRunner(conn, newMediaWorkflow())
+	if err != nil {
+		t.Fatalf("NewRunner failed, err=%s", err)
+	}
+	for i := 0; i < 2; i++ {
+		err = r.Start(context.Background(), "run1", []byte("img"))
+		if err != nil {
+			t.Fatalf("Start failed, i=%d, err=%s", i, err)
+		}
+	}
+
+	j, err := srv.Client().Lease([]string{"resize"}, 10)
+	if err != nil {
+		t.Fatalf("Lease failed, err=%s", err)
+	}
+	err = r.Complete(context.Background(), j, nil)
+	rerr, ok := err.(*workq.ResponseError)
+	if !ok || rerr.Code() != "CLIENT-ERROR" {
+		t.Fatalf("Error mismatch, err=%v", err)
+	}
+}
+
+func TestNotWorkflowJob(t *testing.T) {
+	r, err := NewRunner(&workq.Client{}, newMediaWorkflow())
+	if err != nil {
+		t.Fatalf("NewRunner failed, err=%s", err)
+	}
+
+	other := &workq.LeasedJob{Name: "resize"}
+	other.Headers().Set(HeaderWorkflow, "other")
+	other.Headers().Set(HeaderRun, "run1")
+	unknown := &workq.LeasedJob{Name: "x"}
+	unknown.Headers().Set(HeaderWorkflow, "media")
+	unknown.Headers().Set(HeaderRun, "run1")
+	unknown.Headers().Set(HeaderStep, "x")
+
+	for _, j := range []*workq.LeasedJob{{Name: "resize"}, other, unknown} {
+		err := r.Complete(context.Background(), j, nil)
+		if err != ErrNotWorkflowJob {
+			t.Fatalf("Error mismatch, err=%v", err)
+		}
+		err = r.Fail(context.Background(), j, nil)
+		if err != ErrNotWorkflowJob {
+			t.Fatalf("Error mismatch, err=%v", err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := [][]Step{
+		{},
+		{{Name: "a b"}},
+		{{Name: "a", OnSuccess: []string{"b"}}},
+		{{Name: "a", OnSuccess: []string{"b"}, OnFailure: []string{"b"}}, {Name: "b"}},
+		{{Name: "a", OnSuccess: []string{"b"}}, {Name: "b", OnFailure: []string{"a"}}},
+		{{Name: "a", OnSuccess: []string{"a"}}},
+	}
+
+	for _, steps := range tests {
+		w := New("w")
+		for _, s := range steps {
+			w.Add(s)
+		}
+
+		err := w.Validate()
+		if !errors.Is(err, ErrInvalidWorkflow) {
+			t.Fatalf("Error mismatch, steps=%+v, err=%v", steps, err)
+		}
+
+		_, err = NewRunner(&workq.Client{}, w)
+		if !errors.Is(err, ErrInvalidWorkflow) {
+			t.Fatalf("Error mismatch, steps=%+v, err=%v", steps, err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package workqtest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iamduo/go-workq"
+)
+
+// Clock is the time source of a Server.
+// TTR, TTL, scheduled times and command timeouts are all measured with it.
+// It is the Clock of client-side timers, so that a FakeClock may drive both a
+// Server and a workq.Pool.
+type Clock = workq.Clock
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTimer(d)
+	return t.C, func() { t.Stop() }
+}
+
+// FakeClock is a Clock that only moves when advanced.
+// Expiries, scheduled job releases, lease timeouts and command timeouts of a
+// Server using it trigger deterministically on Advance and Set.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+	// Closed and replaced whenever a waiter is added.
+	added chan struct{}
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now, added: make(chan struct{})}
+}
+
+// Now returns the current fake time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel receiving the fake time once the clock is advanced
+// by at least d. The returned function stops the channel from being pending,
+// it no longer counts towards BlockUntil afterwards.
+func (c *FakeClock) After(d time.Duration) (<-chan time.Time, func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch, func() {}
+	}
+
+	w := &fakeWaiter{at: c.now.Add(d), ch: ch}
+	c.waiters = append(c.waiters, w)
+	close(c.added)
+	c.added = make(chan struct{})
+	return ch, func() { c.stop(w) }
+}
+
+// Remove waiter w if still pending.
+func (c *FakeClock) stop(w *fakeWaiter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, pending := range c.waiters {
+		if pending == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// Advance moves the clock forward by d, firing any After channels due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.set(c.now.Add(d))
+	c.mu.Unlock()
+}
+
+// Set moves the clock to t, firing any After channels due.
+// Setting an earlier time does not fire anything.
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	c.set(t)
+	c.mu.Unlock()
+}
+
+// Requires c.mu.
+func (c *FakeClock) set(t time.Time) {
+	c.now = t
+	sort.Slice(c.waiters, func(a, b int) bool {
+		return c.waiters[a].at.Before(c.waiters[b].at)
+	})
+
+	n := 0
+	for _, w := range c.waiters {
+		if w.at.After(t) {
+			break
+		}
+		w.ch <- t
+		n++
+	}
+	c.waiters = c.waiters[n:]
+}
+
+// BlockUntil blocks until at least n After channels are pending, channels
+// already fired or stopped are not counted.
+// Use it to wait for commands, such as a lease with a timeout, to start
+// waiting on the clock before advancing it.
+func (c *FakeClock) BlockUntil(n int) {
+	for {
+		c.mu.Lock()
+		pending, added := len(c.waiters), c.added
+		c.mu.Unlock()
+		if pending >= n {
+			return
+		}
+
+		<-added
+	}
+}
@@ -0,0 +1,211 @@
+package workqtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iamduo/go-workq"
+)
+
+var fakeEpoch = time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func TestFakeClock(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	if !clock.Now().Equal(fakeEpoch) {
+		t.Fatalf("Now mismatch, act=%s", clock.Now())
+	}
+
+	after, _ := clock.After(0)
+	select {
+	case <-after:
+	default:
+		t.Fatal("Expected After(0) to fire immediately")
+	}
+
+	c1, _ := clock.After(time.Second)
+	c2, _ := clock.After(2 * time.Second)
+	clock.BlockUntil(2)
+
+	clock.Advance(999 * time.Millisecond)
+	select {
+	case <-c1:
+		t.Fatal("Unexpected fire before due")
+	default:
+	}
+
+	clock.Advance(time.Millisecond)
+	select {
+	case now := <-c1:
+		if !now.Equal(fakeEpoch.Add(time.Second)) {
+			t.Fatalf("Fire time mismatch, act=%s", now)
+		}
+	default:
+		t.Fatal("Expected fire when due")
+	}
+
+	clock.Set(fakeEpoch.Add(time.Hour))
+	select {
+	case <-c2:
+	default:
+		t.Fatal("Expected fire when due")
+	}
+}
+
+func TestFakeClockTTLExpiry(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	srv := NewUnstartedServer(WithClock(clock))
+	defer srv.Close()
+	client := srv.Client()
+
+	err := client.Add(&workq.BgJob{ID: id1, Name: "j1", TTR: 1000, TTL: 60000})
+	if err != nil {
+		t.Fatalf("Add failed, err=%s", err)
+	}
+
+	clock.Advance(59999 * time.Millisecond)
+	_, err = client.InspectJob(id1)
+	if err != nil {
+		t.Fatalf("Inspect failed before TTL, err=%s", err)
+	}
+
+	clock.Advance(time.Millisecond)
+	_, err = client.InspectJob(id1)
+	expCode(t, err, "NOT-FOUND")
+
+	s, err := client.InspectServer()
+	if err != nil {
+		t.Fatalf("Inspect failed, err=%s", err)
+	}
+	if s.EvictedJobs != 1 || !s.Started.Equal(fakeEpoch) {
+		t.Fatalf("Inspected server mismatch, act=%+v", s)
+	}
+}
+
+func TestFakeClockTTRRelease(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	srv := NewUnstartedServer(WithClock(clock))
+	defer srv.Close()
+	client := srv.Client()
+
+	err := client.Add(&workq.BgJob{ID: id1, Name: "j1", TTR: 5000, TTL: 60000, MaxAttempts: 2})
+	if err != nil {
+		t.Fatalf("Add failed, err=%s", err)
+	}
+
+	_, err = client.Lease([]string{"j1"}, 0)
+	if err != nil {
+		t.Fatalf("Lease failed, err=%s", err)
+	}
+
+	clock.Advance(4999 * time.Millisecond)
+	_, err = client.Lease([]string{"j1"}, 0)
+	expCode(t, err, "TIMED-OUT")
+
+	clock.Advance(time.Millisecond)
+	j, err := client.InspectJob(id1)
+	if err != nil {
+		t.Fatalf("Inspect failed, err=%s", err)
+	}
+	if j.State != workq.JobStatePending || j.Attempts != 1 {
+		t.Fatalf("Inspected job mismatch, act=%+v", j)
+	}
+
+	_, err = client.Lease([]string{"j1"}, 0)
+	if err != nil {
+		t.Fatalf("Lease after TTR failed, err=%s", err)
+	}
+
+	clock.Advance(5 * time.Second)
+	j, err = client.InspectJob(id1)
+	if err != nil {
+		t.Fatalf("Inspect failed, err=%s", err)
+	}
+	if j.State != workq.JobStateFailed || j.Attempts != 2 {
+		t.Fatalf("Inspected job mismatch, act=%+v", j)
+	}
+}
+
+func TestFakeClockScheduledRelease(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	srv := NewUnstartedServer(WithClock(clock))
+	defer srv.Close()
+	client := srv.Client()
+
+	err := client.Schedule(&workq.ScheduledJob{
+		ID:   id1,
+		Name: "j1",
+		TTR:  1000,
+		TTL:  60000,
+		Time: fakeEpoch.Add(time.Hour).Format(workq.TimeFormat),
+	})
+	if err != nil {
+		t.Fatalf("Schedule failed, err=%s", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := srv.Client().Lease([]string{"j1"}, 2*60*60*1000)
+		done <- err
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Hour)
+	err = <-done
+	if err != nil {
+		t.Fatalf("Lease after scheduled time failed, err=%s", err)
+	}
+}
+
+func TestFakeClockLeaseTimeout(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	srv := NewUnstartedServer(WithClock(clock))
+	defer srv.Close()
+
+	done := make(chan error)
+	go func() {
+		_, err := srv.Client().Lease([]string{"j1"}, 60000)
+		done <- err
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	expCode(t, <-done, "TIMED-OUT")
+}
+
+func TestFakeClockStop(t *testing.T) {
+	clock := NewFakeClock(fakeEpoch)
+	c1, stop := clock.After(time.Second)
+	stop()
+	clock.Advance(time.Second)
+	select {
+	case <-c1:
+		t.Fatal("Unexpected fire after stop")
+	default:
+	}
+
+	// Leases ending before their timeout leave no pending channel behind.
+	srv := NewUnstartedServer(WithClock(clock))
+	defer srv.Close()
+	done := make(chan error)
+	go func() {
+		_, err := srv.Client().Lease([]string{"j1"}, 60000)
+		done <- err
+	}()
+
+	clock.BlockUntil(1)
+	err := srv.Client().Add(&workq.BgJob{ID: id1, Name: "j1", TTR: 1000, TTL: 60000})
+	if err != nil {
+		t.Fatalf("Add failed, err=%s", err)
+	}
+	err = <-done
+	if err != nil {
+		t.Fatalf("Lease failed, err=%s", err)
+	}
+
+	clock.mu.Lock()
+	pending := len(clock.waiters)
+	clock.mu.Unlock()
+	if pending != 0 {
+		t.Fatalf("Pending mismatch, act=%d", pending)
+	}
+}
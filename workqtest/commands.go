@@ -0,0 +1,558 @@
+package workqtest
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iamduo/go-workq"
+	"github.com/satori/go.uuid"
+)
+
+const maxLineLen = 4096
+
+// serverConn serves the commands of a single connection.
+type serverConn struct {
+	srv *Server
+	rdr *bufio.Reader
+	wrt *bufio.Writer
+}
+
+// clientError is replied as "-CLIENT-ERROR <text>".
+type clientError string
+
+// Read and serve a single command.
+// Returns false when the connection must be closed.
+func (c *serverConn) handle() bool {
+	line, err := c.readLine()
+	if err != nil {
+		if err != io.EOF {
+			c.clientError("Invalid command")
+		}
+		return false
+	}
+
+	args := strings.Split(line, " ")
+	switch args[0] {
+	case "add":
+		return c.add(args[1:])
+	case "run":
+		return c.run(args[1:])
+	case "schedule":
+		return c.schedule(args[1:])
+	case "result":
+		c.result(args[1:])
+	case "lease":
+		c.lease(args[1:])
+	case "complete":
+		return c.finish(args[1:], true)
+	case "fail":
+		return c.finish(args[1:], false)
+	case "delete":
+		c.delete(args[1:])
+	case "inspect":
+		c.inspect(args[1:])
+	default:
+		c.clientError("Unknown command")
+	}
+
+	return true
+}
+
+// "add <id> <name> <ttr> <ttl> <payload-size> [-priority=<value>] [-max-attempts=<value>] [-max-fails=<value>]"
+func (c *serverConn) add(args []string) bool {
+	if len(args) < 5 {
+		c.clientError("Invalid command args")
+		return true
+	}
+
+	payload, ok := c.readBlock(args[4])
+	if !ok {
+		return false
+	}
+
+	j, err := parseJob(args[0], args[1], args[2], args[3], args[5:])
+	if err != "" {
+		c.clientError(string(err))
+		return true
+	}
+
+	j.payload = payload
+	s := c.srv
+	s.mu.Lock()
+	j.created = s.clock.Now()
+	j.expiresAt = j.created.Add(time.Duration(j.ttl) * time.Millisecond)
+	ok = s.addJob(j)
+	s.mu.Unlock()
+
+	if !ok {
+		c.clientError("Duplicate job ID")
+		return true
+	}
+
+	c.ok()
+	return true
+}
+
+// "schedule <id> <name> <ttr> <ttl> <time> <payload-size> [-priority=<value>] [-max-attempts=<value>] [-max-fails=<value>]"
+func (c *serverConn) schedule(args []string) bool {
+	if len(args) < 6 {
+		c.clientError("Invalid command args")
+		return true
+	}
+
+	payload, ok := c.readBlock(args[5])
+	if !ok {
+		return false
+	}
+
+	j, err := parseJob(args[0], args[1], args[2], args[3], args[6:])
+	if err != "" {
+		c.clientError(string(err))
+		return true
+	}
+
+	readyAt, terr := time.Parse(workq.TimeFormat, args[4])
+	if terr != nil {
+		c.clientError("Invalid time")
+		return true
+	}
+
+	j.payload = payload
+	j.state = stateScheduled
+	j.readyAt = readyAt
+	j.expiresAt = readyAt.Add(time.Duration(j.ttl) * time.Millisecond)
+	s := c.srv
+	s.mu.Lock()
+	j.created = s.clock.Now()
+	ok = s.addJob(j)
+	s.mu.Unlock()
+
+	if !ok {
+		c.clientError("Duplicate job ID")
+		return true
+	}
+
+	c.ok()
+	return true
+}
+
+// "run <id> <name> <ttr> <timeout> <payload-size> [-priority=<value>]"
+//
+// The job is removed once a result is replied or the timeout passes.
+func (c *serverConn) run(args []string) bool {
+	if len(args) < 5 {
+		c.clientError("Invalid command args")
+		return true
+	}
+
+	payload, ok := c.readBlock(args[4])
+	if !ok {
+		return false
+	}
+
+	timeout, terr := parseUint(args[3])
+	if terr != "" || timeout == 0 {
+		c.clientError("Invalid timeout")
+		return true
+	}
+
+	for _, flag := range args[5:] {
+		if !strings.HasPrefix(flag, "-priority=") {
+			c.clientError("Invalid flag")
+			return true
+		}
+	}
+
+	j, err := parseJob(args[0], args[1], args[2], "0", args[5:])
+	if err != "" {
+		c.clientError(string(err))
+		return true
+	}
+
+	j.payload = payload
+	s := c.srv
+	s.mu.Lock()
+	j.created = s.clock.Now()
+	ok = s.addJob(j)
+	s.mu.Unlock()
+
+	if !ok {
+		c.clientError("Duplicate job ID")
+		return true
+	}
+
+	var result *workq.JobResult
+	s.wait(time.Duration(timeout)*time.Millisecond, func(now time.Time) bool {
+		if cur, ok := s.jobs[j.id]; !ok || cur != j {
+			return true
+		}
+
+		result = j.result
+		return result != nil
+	})
+
+	s.mu.Lock()
+	if s.jobs[j.id] == j {
+		delete(s.jobs, j.id)
+		s.notify()
+	}
+	s.mu.Unlock()
+
+	if result == nil {
+		c.error("TIMED-OUT", "")
+		return true
+	}
+
+	c.okReply(1)
+	c.writeResult(j.id, result)
+	return true
+}
+
+// "result <id> <timeout>"
+func (c *serverConn) result(args []string) {
+	if len(args) != 2 {
+		c.clientError("Invalid command args")
+		return
+	}
+
+	id, err := parseID(args[0])
+	if err != "" {
+		c.clientError(string(err))
+		return
+	}
+
+	timeout, err := parseUint(args[1])
+	if err != "" {
+		c.clientError("Invalid timeout")
+		return
+	}
+
+	s := c.srv
+	found := false
+	var result *workq.JobResult
+	s.wait(time.Duration(timeout)*time.Millisecond, func(now time.Time) bool {
+		j, ok := s.jobs[id]
+		if !ok {
+			return true
+		}
+
+		found = true
+		result = j.result
+		return result != nil
+	})
+
+	if !found {
+		c.error("NOT-FOUND", "")
+		return
+	}
+
+	if result == nil {
+		c.error("TIMED-OUT", "")
+		return
+	}
+
+	c.okReply(1)
+	c.writeResult(id, result)
+}
+
+// "lease <name>... <timeout>"
+func (c *serverConn) lease(args []string) {
+	if len(args) < 2 {
+		c.clientError("Invalid command args")
+		return
+	}
+
+	names := args[:len(args)-1]
+	for _, name := range names {
+		if !workq.ValidName(name) {
+			c.clientError("Invalid name")
+			return
+		}
+	}
+
+	timeout, err := parseUint(args[len(args)-1])
+	if err != "" {
+		c.clientError("Invalid timeout")
+		return
+	}
+
+	s := c.srv
+	var id, name string
+	var ttr int
+	var payload []byte
+	leased := s.wait(time.Duration(timeout)*time.Millisecond, func(now time.Time) bool {
+		j := s.leaseJob(names, now)
+		if j == nil {
+			return false
+		}
+
+		id, name, ttr, payload = j.id, j.name, j.ttr, j.payload
+		return true
+	})
+
+	if !leased {
+		c.error("TIMED-OUT", "")
+		return
+	}
+
+	c.okReply(1)
+	c.writeLine(id + " " + name + " " + strconv.Itoa(ttr) + " " + strconv.Itoa(len(payload)))
+	c.writeBlock(payload)
+}
+
+// "complete <id> <result-size>" and "fail <id> <result-size>"
+func (c *serverConn) finish(args []string, success bool) bool {
+	if len(args) != 2 {
+		c.clientError("Invalid command args")
+		return true
+	}
+
+	result, ok := c.readBlock(args[1])
+	if !ok {
+		return false
+	}
+
+	id, err := parseID(args[0])
+	if err != "" {
+		c.clientError(string(err))
+		return true
+	}
+
+	s := c.srv
+	s.mu.Lock()
+	s.tick(s.clock.Now())
+	j, ok := s.jobs[id]
+	ok = ok && j.state == workq.JobStateLeased
+	if ok {
+		if success {
+			s.completeJob(j, result)
+		} else {
+			s.failJob(j, result)
+		}
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		c.error("NOT-FOUND", "")
+		return true
+	}
+
+	c.ok()
+	return true
+}
+
+// "delete <id>"
+func (c *serverConn) delete(args []string) {
+	if len(args) != 1 {
+		c.clientError("Invalid command args")
+		return
+	}
+
+	id, err := parseID(args[0])
+	if err != "" {
+		c.clientError(string(err))
+		return
+	}
+
+	s := c.srv
+	s.mu.Lock()
+	s.tick(s.clock.Now())
+	_, ok := s.jobs[id]
+	if ok {
+		delete(s.jobs, id)
+		s.notify()
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		c.error("NOT-FOUND", "")
+		return
+	}
+
+	c.ok()
+}
+
+// Parse job arguments common to add, schedule and run.
+func parseJob(id string, name string, ttr string, ttl string, flags []string) (*job, clientError) {
+	j := &job{state: workq.JobStateNew}
+
+	var err clientError
+	j.id, err = parseID(id)
+	if err != "" {
+		return nil, err
+	}
+
+	if !workq.ValidName(name) {
+		return nil, "Invalid name"
+	}
+	j.name = name
+
+	v, err := parseUint(ttr)
+	if err != "" || v == 0 {
+		return nil, "Invalid TTR"
+	}
+	j.ttr = v
+
+	v, err = parseUint(ttl)
+	if err != "" {
+		return nil, "Invalid TTL"
+	}
+	j.ttl = v
+
+	for _, flag := range flags {
+		i := strings.IndexByte(flag, '=')
+		if i < 0 {
+			return nil, "Invalid flag"
+		}
+
+		key, value := flag[:i], flag[i+1:]
+		switch key {
+		case "-priority":
+			p, perr := strconv.ParseInt(value, 10, 32)
+			if perr != nil {
+				return nil, "Invalid priority"
+			}
+			j.priority = int(p)
+		case "-max-attempts":
+			v, err = parseUint8(value)
+			if err != "" {
+				return nil, "Invalid max-attempts"
+			}
+			j.maxAttempts = v
+		case "-max-fails":
+			v, err = parseUint8(value)
+			if err != "" {
+				return nil, "Invalid max-fails"
+			}
+			j.maxFails = v
+		default:
+			return nil, "Invalid flag"
+		}
+	}
+
+	return j, ""
+}
+
+func parseID(s string) (string, clientError) {
+	_, err := uuid.FromString(s)
+	if err != nil {
+		return "", "Invalid ID"
+	}
+
+	return s, ""
+}
+
+func parseUint(s string) (int, clientError) {
+	v, err := strconv.ParseUint(s, 10, 31)
+	if err != nil {
+		return 0, "Invalid number"
+	}
+
+	return int(v), ""
+}
+
+func parseUint8(s string) (int, clientError) {
+	v, err := strconv.ParseUint(s, 10, 8)
+	if err != nil {
+		return 0, "Invalid number"
+	}
+
+	return int(v), ""
+}
+
+// Read a "\r\n" terminated command line.
+func (c *serverConn) readLine() (string, error) {
+	var line []byte
+	for {
+		b, err := c.rdr.ReadSlice('\n')
+		line = append(line, b...)
+		if err == bufio.ErrBufferFull && len(line) < maxLineLen {
+			continue
+		}
+		if err != nil {
+			if err == bufio.ErrBufferFull {
+				return "", workq.ErrMalformed
+			}
+			return "", err
+		}
+		break
+	}
+
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", workq.ErrMalformed
+	}
+
+	return string(line[:len(line)-2]), nil
+}
+
+// Read a "\r\n" terminated data block of size.
+// Replies with an error and returns false when the block can not be read,
+// the connection can not be resynchronized after that.
+func (c *serverConn) readBlock(size string) ([]byte, bool) {
+	n, err := parseUint(size)
+	if err != "" {
+		c.clientError("Invalid data block size")
+		return nil, false
+	}
+
+	if n > workq.DefaultMaxDataBlock {
+		c.clientError("Data block too large")
+		return nil, false
+	}
+
+	b := make([]byte, n+2)
+	_, rerr := io.ReadFull(c.rdr, b)
+	if rerr != nil {
+		return nil, false
+	}
+
+	if b[n] != '\r' || b[n+1] != '\n' {
+		c.clientError("Invalid data block")
+		return nil, false
+	}
+
+	return b[:n], true
+}
+
+func (c *serverConn) writeLine(line string) {
+	c.wrt.WriteString(line)
+	c.wrt.WriteString("\r\n")
+}
+
+func (c *serverConn) writeBlock(b []byte) {
+	c.wrt.Write(b)
+	c.wrt.WriteString("\r\n")
+}
+
+func (c *serverConn) writeResult(id string, result *workq.JobResult) {
+	success := "0"
+	if result.Success {
+		success = "1"
+	}
+
+	c.writeLine(id + " " + success + " " + strconv.Itoa(len(result.Result)))
+	c.writeBlock(result.Result)
+}
+
+func (c *serverConn) ok() {
+	c.writeLine("+OK")
+}
+
+func (c *serverConn) okReply(count int) {
+	c.writeLine("+OK " + strconv.Itoa(count))
+}
+
+func (c *serverConn) error(code string, text string) {
+	if text != "" {
+		code += " " + text
+	}
+
+	c.writeLine("-" + code)
+}
+
+func (c *serverConn) clientError(text string) {
+	c.error("CLIENT-ERROR", text)
+}
@@ -0,0 +1,523 @@
+package workqtest
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iamduo/go-workq"
+)
+
+// RunConformance runs the protocol conformance suite against the workq server
+// at addr, one subtest per documented behavior.
+//
+// Every subtest connects on its own and uses unique job IDs and names, so the
+// suite can run against a server with existing jobs. Some subtests wait for
+// short TTR and TTL expiries in real time.
+//
+//	func TestConformance(t *testing.T) {
+//		srv := workqtest.NewServer()
+//		defer srv.Close()
+//		workqtest.RunConformance(t, srv.Addr)
+//	}
+func RunConformance(t *testing.T, addr string) {
+	for _, tc := range conformanceTests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := workq.Connect(addr)
+			if err != nil {
+				t.Fatalf("Unable to connect, err=%s", err)
+			}
+			defer client.Close()
+
+			tc.run(&conformance{T: t, addr: addr, client: client})
+		})
+	}
+}
+
+// conformance is the state of a single conformance subtest.
+type conformance struct {
+	*testing.T
+	addr   string
+	client *workq.Client
+}
+
+var conformanceTests = []struct {
+	name string
+	run  func(c *conformance)
+}{
+	{"AddLeaseComplete", (*conformance).addLeaseComplete},
+	{"ResultAfterComplete", (*conformance).resultAfterComplete},
+	{"ResultTimeout", (*conformance).resultTimeout},
+	{"ResultZeroTimeout", (*conformance).resultZeroTimeout},
+	{"LeaseTimeout", (*conformance).leaseTimeout},
+	{"LeaseMultipleNames", (*conformance).leaseMultipleNames},
+	{"LeasePriority", (*conformance).leasePriority},
+	{"LeaseWaitsForAdd", (*conformance).leaseWaitsForAdd},
+	{"TTRRelease", (*conformance).ttrRelease},
+	{"MaxAttemptsExhausted", (*conformance).maxAttemptsExhausted},
+	{"FailRelease", (*conformance).failRelease},
+	{"MaxFailsExhausted", (*conformance).maxFailsExhausted},
+	{"TTLExpiry", (*conformance).ttlExpiry},
+	{"Run", (*conformance).run},
+	{"RunTimeout", (*conformance).runTimeout},
+	{"Schedule", (*conformance).schedule},
+	{"CompleteNotLeased", (*conformance).completeNotLeased},
+	{"Delete", (*conformance).delete},
+	{"InspectJob", (*conformance).inspectJob},
+	{"InspectJobs", (*conformance).inspectJobs},
+	{"InspectQueues", (*conformance).inspectQueues},
+	{"InspectServer", (*conformance).inspectServer},
+}
+
+// Return a random version 4 UUID.
+func newID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// Return a unique job name.
+func (c *conformance) newName() string {
+	return "conformance-" + newID()[:8]
+}
+
+func (c *conformance) add(j *workq.BgJob) {
+	c.Helper()
+	err := c.client.Add(j)
+	if err != nil {
+		c.Fatalf("Add failed, err=%s", err)
+	}
+}
+
+func (c *conformance) lease(name string, timeout int) *workq.LeasedJob {
+	c.Helper()
+	j, err := c.client.Lease([]string{name}, timeout)
+	if err != nil {
+		c.Fatalf("Lease failed, err=%s", err)
+	}
+
+	return j
+}
+
+func (c *conformance) expCode(err error, code string) {
+	c.Helper()
+	rerr, ok := err.(*workq.ResponseError)
+	if !ok || rerr.Code() != code {
+		c.Fatalf("Error mismatch, exp=%s, act=%v", code, err)
+	}
+}
+
+func (c *conformance) addLeaseComplete() {
+	name := c.newName()
+	j := &workq.BgJob{ID: newID(), Name: name, TTR: 5000, TTL: 60000, Payload: []byte("a\r\nb")}
+	c.add(j)
+
+	leased := c.lease(name, 1000)
+	if leased.ID != j.ID || leased.Name != name || leased.TTR != j.TTR || string(leased.Payload) != "a\r\nb" {
+		c.Fatalf("Leased job mismatch, act=%+v", leased)
+	}
+
+	err := c.client.Complete(j.ID, []byte("done"))
+	if err != nil {
+		c.Fatalf("Complete failed, err=%s", err)
+	}
+}
+
+func (c *conformance) resultAfterComplete() {
+	name := c.newName()
+	j := &workq.BgJob{ID: newID(), Name: name, TTR: 5000, TTL: 60000}
+	c.add(j)
+	c.lease(name, 1000)
+
+	err := c.client.Complete(j.ID, []byte("done"))
+	if err != nil {
+		c.Fatalf("Complete failed, err=%s", err)
+	}
+
+	result, err := c.client.Result(j.ID, 1000)
+	if err != nil {
+		c.Fatalf("Result failed, err=%s", err)
+	}
+	if !result.Success || string(result.Result) != "done" {
+		c.Fatalf("Result mismatch, act=%+v", result)
+	}
+}
+
+func (c *conformance) resultTimeout() {
+	j := &workq.BgJob{ID: newID(), Name: c.newName(), TTR: 5000, TTL: 60000}
+	c.add(j)
+
+	_, err := c.client.Result(j.ID, 100)
+	c.expCode(err, "TIMED-OUT")
+}
+
+// A zero timeout returns the result at once, as polled by workq.Pool.
+func (c *conformance) resultZeroTimeout() {
+	name := c.newName()
+	j := &workq.BgJob{ID: newID(), Name: name, TTR: 5000, TTL: 60000}
+	c.add(j)
+
+	start := time.Now()
+	_, err := c.client.Result(j.ID, 0)
+	c.expCode(err, "TIMED-OUT")
+	if time.Since(start) > 500*time.Millisecond {
+		c.Fatalf("Result with zero timeout blocked, dur=%s", time.Since(start))
+	}
+
+	c.lease(name, 1000)
+	err = c.client.Complete(j.ID, []byte("done"))
+	if err != nil {
+		c.Fatalf("Complete failed, err=%s", err)
+	}
+
+	result, err := c.client.Result(j.ID, 0)
+	if err != nil {
+		c.Fatalf("Result failed, err=%s", err)
+	}
+	if !result.Success || string(result.Result) != "done" {
+		c.Fatalf("Result mismatch, act=%+v", result)
+	}
+}
+
+func (c *conformance) leaseTimeout() {
+	start := time.Now()
+	_, err := c.client.Lease([]string{c.newName()}, 100)
+	c.expCode(err, "TIMED-OUT")
+	if time.Since(start) < 100*time.Millisecond {
+		c.Fatalf("Lease returned before timeout, elapsed=%s", time.Since(start))
+	}
+}
+
+func (c *conformance) leaseMultipleNames() {
+	name1, name2 := c.newName(), c.newName()
+	j := &workq.BgJob{ID: newID(), Name: name2, TTR: 5000, TTL: 60000}
+	c.add(j)
+
+	leased, err := c.client.Lease([]string{name1, name2}, 1000)
+	if err != nil || leased.ID != j.ID {
+		c.Fatalf("Lease mismatch, job=%+v, err=%v", leased, err)
+	}
+}
+
+func (c *conformance) leasePriority() {
+	name := c.newName()
+	low := &workq.BgJob{ID: newID(), Name: name, TTR: 5000, TTL: 60000, Priority: -1}
+	normal := &workq.BgJob{ID: newID(), Name: name, TTR: 5000, TTL: 60000}
+	high := &workq.BgJob{ID: newID(), Name: name, TTR: 5000, TTL: 60000, Priority: 10}
+	c.add(low)
+	c.add(normal)
+	c.add(high)
+
+	for _, exp := range []*workq.BgJob{high, normal, low} {
+		leased := c.lease(name, 1000)
+		if leased.ID != exp.ID {
+			c.Fatalf("Lease order mismatch, exp priority=%d, act=%+v", exp.Priority, leased)
+		}
+	}
+}
+
+func (c *conformance) leaseWaitsForAdd() {
+	name := c.newName()
+	j := &workq.BgJob{ID: newID(), Name: name, TTR: 5000, TTL: 60000}
+	added := make(chan struct{})
+	go func() {
+		defer close(added)
+		time.Sleep(50 * time.Millisecond)
+		producer, err := workq.Connect(c.addr)
+		if err != nil {
+			c.Errorf("Unable to connect producer, err=%s", err)
+			return
+		}
+		defer producer.Close()
+
+		err = producer.Add(j)
+		if err != nil {
+			c.Errorf("Producer add failed, err=%s", err)
+		}
+	}()
+	defer func() { <-added }()
+
+	leased := c.lease(name, 5000)
+	if leased.ID != j.ID {
+		c.Fatalf("Leased job mismatch, act=%+v", leased)
+	}
+}
+
+func (c *conformance) ttrRelease() {
+	name := c.newName()
+	j := &workq.BgJob{ID: newID(), Name: name, TTR: 100, TTL: 60000}
+	c.add(j)
+	c.lease(name, 1000)
+
+	leased := c.lease(name, 2000)
+	if leased.ID != j.ID {
+		c.Fatalf("Leased job mismatch after TTR, act=%+v", leased)
+	}
+}
+
+func (c *conformance) maxAttemptsExhausted() {
+	name := c.newName()
+	j := &workq.BgJob{ID: newID(), Name: name, TTR: 100, TTL: 60000, MaxAttempts: 1}
+	c.add(j)
+	c.lease(name, 1000)
+
+	_, err := c.client.Lease([]string{name}, 500)
+	c.expCode(err, "TIMED-OUT")
+}
+
+func (c *conformance) failRelease() {
+	name := c.newName()
+	j := &workq.BgJob{ID: newID(), Name: name, TTR: 5000, TTL: 60000, MaxFails: 2}
+	c.add(j)
+	c.lease(name, 1000)
+
+	err := c.client.Fail(j.ID, []byte("error"))
+	if err != nil {
+		c.Fatalf("Fail failed, err=%s", err)
+	}
+
+	leased := c.lease(name, 1000)
+	if leased.ID != j.ID {
+		c.Fatalf("Leased job mismatch after fail, act=%+v", leased)
+	}
+}
+
+func (c *conformance) maxFailsExhausted() {
+	name := c.newName()
+	j := &workq.BgJob{ID: newID(), Name: name, TTR: 5000, TTL: 60000, MaxFails: 1}
+	c.add(j)
+	c.lease(name, 1000)
+
+	err := c.client.Fail(j.ID, []byte("error"))
+	if err != nil {
+		c.Fatalf("Fail failed, err=%s", err)
+	}
+
+	_, err = c.client.Lease([]string{name}, 100)
+	c.expCode(err, "TIMED-OUT")
+
+	result, err := c.client.Result(j.ID, 1000)
+	if err != nil {
+		c.Fatalf("Result failed, err=%s", err)
+	}
+	if result.Success || string(result.Result) != "error" {
+		c.Fatalf("Result mismatch, act=%+v", result)
+	}
+}
+
+func (c *conformance) ttlExpiry() {
+	name := c.newName()
+	j := &workq.BgJob{ID: newID(), Name: name, TTR: 5000, TTL: 100}
+	c.add(j)
+	time.Sleep(300 * time.Millisecond)
+
+	_, err := c.client.Lease([]string{name}, 100)
+	c.expCode(err, "TIMED-OUT")
+}
+
+func (c *conformance) run() {
+	name := c.newName()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		worker, err := workq.Connect(c.addr)
+		if err != nil {
+			c.Errorf("Unable to connect, err=%s", err)
+			return
+		}
+		defer worker.Close()
+
+		leased, err := worker.Lease([]string{name}, 5000)
+		if err != nil {
+			c.Errorf("Lease failed, err=%s", err)
+			return
+		}
+
+		err = worker.Complete(leased.ID, append([]byte("re: "), leased.Payload...))
+		if err != nil {
+			c.Errorf("Complete failed, err=%s", err)
+		}
+	}()
+
+	result, err := c.client.Run(&workq.FgJob{ID: newID(), Name: name, TTR: 5000, Timeout: 5000, Payload: []byte("ping")})
+	wg.Wait()
+	if err != nil {
+		c.Fatalf("Run failed, err=%s", err)
+	}
+	if !result.Success || string(result.Result) != "re: ping" {
+		c.Fatalf("Result mismatch, act=%+v", result)
+	}
+}
+
+func (c *conformance) runTimeout() {
+	_, err := c.client.Run(&workq.FgJob{ID: newID(), Name: c.newName(), TTR: 5000, Timeout: 100})
+	c.expCode(err, "TIMED-OUT")
+}
+
+func (c *conformance) schedule() {
+	name := c.newName()
+	future := &workq.ScheduledJob{
+		ID:   newID(),
+		Name: name,
+		TTR:  5000,
+		TTL:  60000,
+		Time: time.Now().UTC().Add(time.Hour).Format(workq.TimeFormat),
+	}
+	past := &workq.ScheduledJob{
+		ID:   newID(),
+		Name: name,
+		TTR:  5000,
+		TTL:  60000,
+		Time: time.Now().UTC().Add(-time.Second).Format(workq.TimeFormat),
+	}
+	for _, j := range []*workq.ScheduledJob{future, past} {
+		err := c.client.Schedule(j)
+		if err != nil {
+			c.Fatalf("Schedule failed, err=%s", err)
+		}
+	}
+
+	leased := c.lease(name, 1000)
+	if leased.ID != past.ID {
+		c.Fatalf("Leased job mismatch, act=%+v", leased)
+	}
+
+	_, err := c.client.Lease([]string{name}, 100)
+	c.expCode(err, "TIMED-OUT")
+}
+
+func (c *conformance) completeNotLeased() {
+	j := &workq.BgJob{ID: newID(), Name: c.newName(), TTR: 5000, TTL: 60000}
+	c.add(j)
+
+	c.expCode(c.client.Complete(j.ID, nil), "NOT-FOUND")
+	c.expCode(c.client.Fail(j.ID, nil), "NOT-FOUND")
+}
+
+func (c *conformance) delete() {
+	name := c.newName()
+	j := &workq.BgJob{ID: newID(), Name: name, TTR: 5000, TTL: 60000}
+	c.add(j)
+
+	err := c.client.Delete(j.ID)
+	if err != nil {
+		c.Fatalf("Delete failed, err=%s", err)
+	}
+
+	c.expCode(c.client.Delete(j.ID), "NOT-FOUND")
+	_, err = c.client.Lease([]string{name}, 100)
+	c.expCode(err, "TIMED-OUT")
+}
+
+func (c *conformance) inspectJob() {
+	j := &workq.BgJob{
+		ID:          newID(),
+		Name:        c.newName(),
+		TTR:         5000,
+		TTL:         60000,
+		Payload:     []byte("a\r\nb"),
+		Priority:    5,
+		MaxAttempts: 3,
+		MaxFails:    2,
+	}
+	c.add(j)
+
+	inspected, err := c.client.InspectJob(j.ID)
+	if err != nil {
+		c.Fatalf("InspectJob failed, err=%s", err)
+	}
+	if inspected.ID != j.ID || inspected.Name != j.Name || inspected.TTR != j.TTR ||
+		inspected.TTL != j.TTL || string(inspected.Payload) != string(j.Payload) ||
+		inspected.Priority != j.Priority || inspected.MaxAttempts != j.MaxAttempts ||
+		inspected.MaxFails != j.MaxFails || inspected.Attempts != 0 || inspected.Fails != 0 ||
+		inspected.State != workq.JobStateNew || inspected.Created.IsZero() {
+		c.Fatalf("Inspected job mismatch, act=%+v", inspected)
+	}
+
+	_, err = c.client.InspectJob(newID())
+	c.expCode(err, "NOT-FOUND")
+}
+
+func (c *conformance) inspectJobs() {
+	name := c.newName()
+	var ids []string
+	for i := 0; i < 3; i++ {
+		j := &workq.BgJob{ID: newID(), Name: name, TTR: 5000, TTL: 60000}
+		c.add(j)
+		ids = append(ids, j.ID)
+	}
+
+	seen := make(map[string]bool)
+	for offset := 0; offset < 3; offset += 2 {
+		jobs, err := c.client.InspectJobs(name, offset, 2)
+		if err != nil {
+			c.Fatalf("InspectJobs failed, err=%s", err)
+		}
+		for _, j := range jobs {
+			seen[j.ID] = true
+		}
+	}
+
+	for _, id := range ids {
+		if !seen[id] {
+			c.Fatalf("InspectJobs missing job %s, seen=%v", id, seen)
+		}
+	}
+
+	jobs, err := c.client.InspectJobs(name, 3, 2)
+	if err != nil || len(jobs) != 0 {
+		c.Fatalf("InspectJobs past end mismatch, jobs=%+v, err=%v", jobs, err)
+	}
+}
+
+func (c *conformance) inspectQueues() {
+	name := c.newName()
+	c.add(&workq.BgJob{ID: newID(), Name: name, TTR: 5000, TTL: 60000})
+	c.add(&workq.BgJob{ID: newID(), Name: name, TTR: 5000, TTL: 60000})
+	c.lease(name, 1000)
+
+	q, err := c.client.InspectQueue(name)
+	if err != nil {
+		c.Fatalf("InspectQueue failed, err=%s", err)
+	}
+	if q.Name != name || q.ReadyLen != 1 || q.LeasedLen != 1 || q.ScheduledLen != 0 {
+		c.Fatalf("Inspected queue mismatch, act=%+v", q)
+	}
+
+	scanned := 0
+	found := false
+	for offset := 0; ; offset += 100 {
+		queues, err := c.client.InspectQueues(offset, 100)
+		if err != nil {
+			c.Fatalf("InspectQueues failed, err=%s", err)
+		}
+		if len(queues) == 0 {
+			break
+		}
+
+		scanned += len(queues)
+		for _, q := range queues {
+			found = found || q.Name == name
+		}
+	}
+
+	if !found {
+		c.Fatalf("InspectQueues missing %s in %d queues", name, scanned)
+	}
+}
+
+func (c *conformance) inspectServer() {
+	srv, err := c.client.InspectServer()
+	if err != nil {
+		c.Fatalf("InspectServer failed, err=%s", err)
+	}
+	if srv.ActiveClients < 1 || srv.Started.IsZero() || srv.Started.After(time.Now()) {
+		c.Fatalf("Inspected server mismatch, act=%+v", srv)
+	}
+}
@@ -0,0 +1,22 @@
+package workqtest
+
+import (
+	"os"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	RunConformance(t, srv.Addr)
+}
+
+// Run the suite against a real workq server with WORKQ_ADDR="host:port".
+func TestConformanceWorkq(t *testing.T) {
+	addr := os.Getenv("WORKQ_ADDR")
+	if addr == "" {
+		t.Skip("WORKQ_ADDR not set")
+	}
+
+	RunConformance(t, addr)
+}
@@ -0,0 +1,183 @@
+package workqtest
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrInjectedFault is returned by FaultConn for injected write failures and
+// for writes after an injected drop.
+var ErrInjectedFault = errors.New("workqtest: injected fault")
+
+// FaultConn wraps a net.Conn injecting network faults: latency, dropped
+// connections mid-reply, reads split into small chunks, corrupted bytes and
+// failed writes.
+//
+//	conn := workqtest.NewFaultConn(srv.Pipe(), workqtest.WithReadChunk(1))
+//	client := workq.NewClient(conn)
+type FaultConn struct {
+	net.Conn
+
+	latency        time.Duration
+	readChunk      int
+	dropAfter      int64
+	writeFailAfter int64
+	corrupt        func(offset int64, b byte) byte
+
+	mu      sync.Mutex
+	read    int64
+	written int64
+	dropped bool
+}
+
+// FaultOption configures the faults of a FaultConn.
+type FaultOption func(*FaultConn)
+
+// WithLatency delays every Read and Write by d.
+func WithLatency(d time.Duration) FaultOption {
+	return func(c *FaultConn) {
+		c.latency = d
+	}
+}
+
+// WithReadChunk returns at most n bytes per Read.
+func WithReadChunk(n int) FaultOption {
+	return func(c *FaultConn) {
+		c.readChunk = n
+	}
+}
+
+// WithDropAfter closes the connection once n bytes have been read, as if the
+// peer dropped it. Further reads return io.EOF and writes ErrInjectedFault.
+func WithDropAfter(n int64) FaultOption {
+	return func(c *FaultConn) {
+		c.dropAfter = n
+	}
+}
+
+// WithWriteFailAfter fails writes with ErrInjectedFault once n bytes have been
+// written. The write crossing n is written partially.
+func WithWriteFailAfter(n int64) FaultOption {
+	return func(c *FaultConn) {
+		c.writeFailAfter = n
+	}
+}
+
+// WithCorrupt replaces every byte read with the result of fn, given the
+// byte's offset in the stream read so far.
+func WithCorrupt(fn func(offset int64, b byte) byte) FaultOption {
+	return func(c *FaultConn) {
+		c.corrupt = fn
+	}
+}
+
+// CorruptAt returns a WithCorrupt function inverting the bits of the bytes at
+// offsets.
+func CorruptAt(offsets ...int64) func(offset int64, b byte) byte {
+	return func(offset int64, b byte) byte {
+		for _, o := range offsets {
+			if o == offset {
+				return ^b
+			}
+		}
+
+		return b
+	}
+}
+
+// NewFaultConn wraps conn with the faults of opts.
+// Without options the FaultConn behaves as conn.
+func NewFaultConn(conn net.Conn, opts ...FaultOption) *FaultConn {
+	c := &FaultConn{Conn: conn, dropAfter: -1, writeFailAfter: -1}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+func (c *FaultConn) Read(b []byte) (int, error) {
+	if c.latency > 0 {
+		time.Sleep(c.latency)
+	}
+
+	c.mu.Lock()
+	dropped, read := c.dropped, c.read
+	c.mu.Unlock()
+	if dropped {
+		return 0, io.EOF
+	}
+
+	if c.readChunk > 0 && len(b) > c.readChunk {
+		b = b[:c.readChunk]
+	}
+
+	if c.dropAfter >= 0 && int64(len(b)) > c.dropAfter-read {
+		b = b[:c.dropAfter-read]
+		if len(b) == 0 {
+			c.drop()
+			return 0, io.EOF
+		}
+	}
+
+	n, err := c.Conn.Read(b)
+	if c.corrupt != nil {
+		for i := 0; i < n; i++ {
+			b[i] = c.corrupt(read+int64(i), b[i])
+		}
+	}
+
+	c.mu.Lock()
+	c.read += int64(n)
+	drop := c.dropAfter >= 0 && c.read >= c.dropAfter
+	c.mu.Unlock()
+	if drop {
+		c.drop()
+	}
+
+	return n, err
+}
+
+func (c *FaultConn) Write(b []byte) (int, error) {
+	if c.latency > 0 {
+		time.Sleep(c.latency)
+	}
+
+	c.mu.Lock()
+	dropped, written := c.dropped, c.written
+	c.mu.Unlock()
+	if dropped {
+		return 0, ErrInjectedFault
+	}
+
+	fail := false
+	if c.writeFailAfter >= 0 && int64(len(b)) > c.writeFailAfter-written {
+		b = b[:c.writeFailAfter-written]
+		fail = true
+	}
+
+	n, err := c.Conn.Write(b)
+	c.mu.Lock()
+	c.written += int64(n)
+	c.mu.Unlock()
+	if err == nil && fail {
+		err = ErrInjectedFault
+	}
+
+	return n, err
+}
+
+// Drop closes the connection as if the peer dropped it.
+func (c *FaultConn) Drop() {
+	c.drop()
+}
+
+func (c *FaultConn) drop() {
+	c.mu.Lock()
+	c.dropped = true
+	c.mu.Unlock()
+	c.Conn.Close()
+}
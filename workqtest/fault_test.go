@@ -0,0 +1,104 @@
+package workqtest
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iamduo/go-workq"
+)
+
+func TestFaultConnReadChunk(t *testing.T) {
+	srv := NewUnstartedServer()
+	defer srv.Close()
+	client := workq.NewClient(NewFaultConn(srv.Pipe(), WithReadChunk(1)))
+
+	payload := []byte("a\r\nb")
+	err := client.Add(&workq.BgJob{ID: id1, Name: "j1", TTR: 1000, TTL: 60000, Payload: payload})
+	if err != nil {
+		t.Fatalf("Add failed, err=%s", err)
+	}
+
+	jobs, err := client.InspectJobs("j1", 0, 10)
+	if err != nil || len(jobs) != 1 || !bytes.Equal(jobs[0].Payload, payload) {
+		t.Fatalf("Inspect mismatch, jobs=%+v, err=%v", jobs, err)
+	}
+
+	j, err := client.Lease([]string{"j1"}, 0)
+	if err != nil || !bytes.Equal(j.Payload, payload) {
+		t.Fatalf("Lease mismatch, job=%+v, err=%v", j, err)
+	}
+}
+
+func TestFaultConnLatency(t *testing.T) {
+	srv := NewUnstartedServer()
+	defer srv.Close()
+	client := workq.NewClient(NewFaultConn(srv.Pipe(), WithLatency(5*time.Millisecond)))
+
+	start := time.Now()
+	err := client.Add(&workq.BgJob{ID: id1, Name: "j1", TTR: 1000, TTL: 60000})
+	if err != nil {
+		t.Fatalf("Add failed, err=%s", err)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Fatalf("Latency not applied, elapsed=%s", elapsed)
+	}
+}
+
+func TestFaultConnDropMidReply(t *testing.T) {
+	srv := NewUnstartedServer()
+	defer srv.Close()
+
+	err := srv.Client().Add(&workq.BgJob{ID: id1, Name: "j1", TTR: 1000, TTL: 60000, Payload: []byte("abc")})
+	if err != nil {
+		t.Fatalf("Add failed, err=%s", err)
+	}
+
+	// Drop within the leased job header after "+OK 1\r\n".
+	conn := NewFaultConn(srv.Pipe(), WithDropAfter(10))
+	client := workq.NewClient(conn)
+	_, err = client.Lease([]string{"j1"}, 0)
+	if _, ok := err.(*workq.NetError); !ok {
+		t.Fatalf("Error mismatch, err=%+v", err)
+	}
+
+	err = client.Add(&workq.BgJob{ID: id2, Name: "j1", TTR: 1000, TTL: 60000})
+	if _, ok := err.(*workq.NetError); !ok {
+		t.Fatalf("Error mismatch after drop, err=%+v", err)
+	}
+}
+
+func TestFaultConnCorrupt(t *testing.T) {
+	srv := NewUnstartedServer()
+	defer srv.Close()
+
+	// Corrupt the "+" of "+OK".
+	client := workq.NewClient(NewFaultConn(srv.Pipe(), WithCorrupt(CorruptAt(0))))
+	err := client.Add(&workq.BgJob{ID: id1, Name: "j1", TTR: 1000, TTL: 60000})
+	if !errors.Is(err, workq.ErrMalformed) {
+		t.Fatalf("Error mismatch, err=%+v", err)
+	}
+}
+
+func TestFaultConnWriteFailAfter(t *testing.T) {
+	srv := NewUnstartedServer()
+	defer srv.Close()
+
+	client := workq.NewClient(NewFaultConn(srv.Pipe(), WithWriteFailAfter(10)))
+	err := client.Add(&workq.BgJob{ID: id1, Name: "j1", TTR: 1000, TTL: 60000})
+	if _, ok := err.(*workq.NetError); !ok {
+		t.Fatalf("Error mismatch, err=%+v", err)
+	}
+}
+
+func TestFaultConnPassthrough(t *testing.T) {
+	srv := NewUnstartedServer()
+	defer srv.Close()
+
+	client := workq.NewClient(NewFaultConn(srv.Pipe()))
+	err := client.Add(&workq.BgJob{ID: id1, Name: "j1", TTR: 1000, TTL: 60000})
+	if err != nil {
+		t.Fatalf("Add failed, err=%s", err)
+	}
+}
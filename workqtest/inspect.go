@@ -0,0 +1,207 @@
+package workqtest
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/iamduo/go-workq"
+)
+
+// "inspect jobs <name> <cursor-offset> <limit>", "inspect job <id>",
+// "inspect server", "inspect queues <cursor-offset> <limit>" and
+// "inspect queue <name>"
+func (c *serverConn) inspect(args []string) {
+	if len(args) == 0 {
+		c.clientError("Invalid command args")
+		return
+	}
+
+	s := c.srv
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tick(s.clock.Now())
+
+	switch {
+	case args[0] == "jobs" && len(args) == 4:
+		c.inspectJobs(args[1], args[2], args[3])
+	case args[0] == "job" && len(args) == 2:
+		c.inspectJob(args[1])
+	case args[0] == "server" && len(args) == 1:
+		c.inspectServer()
+	case args[0] == "queues" && len(args) == 3:
+		c.inspectQueues(args[1], args[2])
+	case args[0] == "queue" && len(args) == 2:
+		c.inspectQueue(args[1])
+	default:
+		c.clientError("Invalid command args")
+	}
+}
+
+// Requires s.mu.
+func (c *serverConn) inspectJobs(name string, offset string, limit string) {
+	if !workq.ValidName(name) {
+		c.clientError("Invalid name")
+		return
+	}
+
+	jobs := c.srv.sortedJobs(func(j *job) bool {
+		return j.name == name
+	})
+	ok := c.page(len(jobs), offset, limit, func(from, to int) {
+		jobs = jobs[from:to]
+	})
+	if !ok {
+		return
+	}
+
+	c.okReply(len(jobs))
+	for _, j := range jobs {
+		c.writeJob(j)
+	}
+}
+
+// Requires s.mu.
+func (c *serverConn) inspectJob(id string) {
+	id, err := parseID(id)
+	if err != "" {
+		c.clientError(string(err))
+		return
+	}
+
+	j, ok := c.srv.jobs[id]
+	if !ok {
+		c.error("NOT-FOUND", "")
+		return
+	}
+
+	c.okReply(1)
+	c.writeJob(j)
+}
+
+// Requires s.mu.
+func (c *serverConn) inspectServer() {
+	s := c.srv
+	c.okReply(1)
+	c.writeLine("server 3")
+	c.writeLine("active-clients " + strconv.Itoa(len(s.conns)))
+	c.writeLine("evicted-jobs " + strconv.Itoa(s.evicted))
+	c.writeLine("started " + s.started.UTC().Format(time.RFC3339))
+}
+
+// Requires s.mu.
+func (c *serverConn) inspectQueues(offset string, limit string) {
+	queues := c.srv.queues()
+	names := make([]string, 0, len(queues))
+	for name := range queues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ok := c.page(len(names), offset, limit, func(from, to int) {
+		names = names[from:to]
+	})
+	if !ok {
+		return
+	}
+
+	c.okReply(len(names))
+	for _, name := range names {
+		c.writeQueue(queues[name])
+	}
+}
+
+// Requires s.mu.
+func (c *serverConn) inspectQueue(name string) {
+	if !workq.ValidName(name) {
+		c.clientError("Invalid name")
+		return
+	}
+
+	q, ok := c.srv.queues()[name]
+	if !ok {
+		c.error("NOT-FOUND", "")
+		return
+	}
+
+	c.okReply(1)
+	c.writeQueue(q)
+}
+
+// Parse cursor offset and limit, calling slice with the page bounds within n.
+// Replies with an error and returns false if either is invalid.
+func (c *serverConn) page(n int, offset string, limit string, slice func(from, to int)) bool {
+	from, err := parseUint(offset)
+	if err != "" {
+		c.clientError("Invalid cursor offset")
+		return false
+	}
+
+	max, err := parseUint(limit)
+	if err != "" {
+		c.clientError("Invalid limit")
+		return false
+	}
+
+	if from > n {
+		from = n
+	}
+	to := n
+	if max < to-from {
+		to = from + max
+	}
+
+	slice(from, to)
+	return true
+}
+
+func (c *serverConn) writeJob(j *job) {
+	c.writeLine(j.id + " 12")
+	c.writeLine("name " + j.name)
+	c.writeLine("ttr " + strconv.Itoa(j.ttr))
+	c.writeLine("ttl " + strconv.Itoa(j.ttl))
+	c.writeLine("payload-size " + strconv.Itoa(len(j.payload)))
+	c.wrt.WriteString("payload ")
+	c.writeBlock(j.payload)
+	c.writeLine("max-attempts " + strconv.Itoa(j.maxAttempts))
+	c.writeLine("attempts " + strconv.Itoa(j.attempts))
+	c.writeLine("max-fails " + strconv.Itoa(j.maxFails))
+	c.writeLine("fails " + strconv.Itoa(j.fails))
+	c.writeLine("priority " + strconv.Itoa(j.priority))
+	state := j.state
+	if state == stateScheduled {
+		state = workq.JobStateNew
+	}
+	c.writeLine("state " + strconv.Itoa(int(state)))
+	c.writeLine("created " + j.created.UTC().Format(time.RFC3339))
+}
+
+func (c *serverConn) writeQueue(q *workq.InspectedQueue) {
+	c.writeLine(q.Name + " 3")
+	c.writeLine("ready-len " + strconv.Itoa(q.ReadyLen))
+	c.writeLine("scheduled-len " + strconv.Itoa(q.ScheduledLen))
+	c.writeLine("leased-len " + strconv.Itoa(q.LeasedLen))
+}
+
+// Return queue stats by name. Requires s.mu.
+func (s *Server) queues() map[string]*workq.InspectedQueue {
+	queues := make(map[string]*workq.InspectedQueue)
+	for _, j := range s.jobs {
+		q, ok := queues[j.name]
+		if !ok {
+			q = &workq.InspectedQueue{Name: j.name}
+			queues[j.name] = q
+		}
+
+		switch j.state {
+		case workq.JobStateNew, workq.JobStatePending:
+			q.ReadyLen++
+		case stateScheduled:
+			q.ScheduledLen++
+		case workq.JobStateLeased:
+			q.LeasedLen++
+		}
+	}
+
+	return queues
+}
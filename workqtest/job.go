@@ -0,0 +1,182 @@
+package workqtest
+
+import (
+	"sort"
+	"time"
+
+	"github.com/iamduo/go-workq"
+)
+
+// State of scheduled jobs waiting for their time. workq has no such state and
+// reports them as workq.JobStateNew.
+const stateScheduled workq.JobState = -1
+
+// job is a job held by the server.
+type job struct {
+	id          string
+	name        string
+	ttr         int // Milliseconds
+	ttl         int // Milliseconds
+	payload     []byte
+	priority    int
+	maxAttempts int
+	maxFails    int
+	attempts    int
+	fails       int
+	state       workq.JobState
+	created     time.Time
+	seq         uint64
+
+	readyAt      time.Time // Scheduled time, zero when ready on add.
+	expiresAt    time.Time // TTL or run timeout expiry.
+	leaseExpires time.Time // TTR expiry of the current lease.
+	result       *workq.JobResult
+}
+
+// Add a job, returns false if the ID already exists. Requires s.mu.
+func (s *Server) addJob(j *job) bool {
+	if _, ok := s.jobs[j.id]; ok {
+		return false
+	}
+
+	s.seq++
+	j.seq = s.seq
+	s.jobs[j.id] = j
+	s.notify()
+	return true
+}
+
+// Apply time based transitions at now. Requires s.mu.
+//
+// Scheduled jobs become ready at their scheduled time, leases past their TTR
+// are released for another attempt unless max attempts are exhausted and jobs
+// past their TTL are evicted.
+func (s *Server) tick(now time.Time) {
+	changed := false
+	for id, j := range s.jobs {
+		if !j.expiresAt.IsZero() && !now.Before(j.expiresAt) {
+			delete(s.jobs, id)
+			s.evicted++
+			changed = true
+			continue
+		}
+
+		switch j.state {
+		case stateScheduled:
+			if !now.Before(j.readyAt) {
+				j.state = workq.JobStateNew
+				changed = true
+			}
+		case workq.JobStateLeased:
+			if !now.Before(j.leaseExpires) {
+				if j.maxAttempts > 0 && j.attempts >= j.maxAttempts {
+					j.state = workq.JobStateFailed
+				} else {
+					j.state = workq.JobStatePending
+				}
+				changed = true
+			}
+		}
+	}
+
+	if changed {
+		s.notify()
+	}
+}
+
+// Return the time of the next time based transition. Requires s.mu.
+func (s *Server) nextEvent() (time.Time, bool) {
+	var next time.Time
+	earlier := func(t time.Time) {
+		if !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+
+	for _, j := range s.jobs {
+		earlier(j.expiresAt)
+		switch j.state {
+		case stateScheduled:
+			earlier(j.readyAt)
+		case workq.JobStateLeased:
+			earlier(j.leaseExpires)
+		}
+	}
+
+	return next, !next.IsZero()
+}
+
+// Lease the next ready job within names, by highest priority then by order of
+// addition. Requires s.mu.
+func (s *Server) leaseJob(names []string, now time.Time) *job {
+	var next *job
+	for _, j := range s.jobs {
+		if j.state != workq.JobStateNew && j.state != workq.JobStatePending {
+			continue
+		}
+
+		if !contains(names, j.name) {
+			continue
+		}
+
+		if next == nil || j.priority > next.priority || (j.priority == next.priority && j.seq < next.seq) {
+			next = j
+		}
+	}
+
+	if next == nil {
+		return nil
+	}
+
+	next.state = workq.JobStateLeased
+	next.attempts++
+	next.leaseExpires = now.Add(time.Duration(next.ttr) * time.Millisecond)
+	s.notify()
+	return next
+}
+
+// Mark a leased job complete. Requires s.mu.
+func (s *Server) completeJob(j *job, result []byte) {
+	j.state = workq.JobStateCompleted
+	j.result = &workq.JobResult{Success: true, Result: result}
+	s.notify()
+}
+
+// Mark a leased job failed. The job is released for another attempt while
+// both max fails and max attempts permit, otherwise the failure is final.
+// Requires s.mu.
+func (s *Server) failJob(j *job, result []byte) {
+	j.fails++
+	if j.maxFails > 0 && j.fails < j.maxFails && (j.maxAttempts == 0 || j.attempts < j.maxAttempts) {
+		j.state = workq.JobStatePending
+	} else {
+		j.state = workq.JobStateFailed
+		j.result = &workq.JobResult{Success: false, Result: result}
+	}
+	s.notify()
+}
+
+// Return jobs matching filter ordered by addition. Requires s.mu.
+func (s *Server) sortedJobs(filter func(j *job) bool) []*job {
+	var jobs []*job
+	for _, j := range s.jobs {
+		if filter(j) {
+			jobs = append(jobs, j)
+		}
+	}
+
+	sort.Slice(jobs, func(a, b int) bool {
+		return jobs[a].seq < jobs[b].seq
+	})
+	return jobs
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,248 @@
+package workqtest
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/iamduo/go-workq"
+)
+
+// Call is a method call recorded by Mock.
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// Mock implements workq.Producer, workq.Consumer and workq.Admin without a
+// network, recording every call.
+//
+// Responses are programmed through the func field named after each method.
+// Methods with a nil func return ErrNotProgrammed when returning a job, result
+// or inspected object, and zero values otherwise.
+//
+//	m := &workqtest.Mock{
+//		LeaseFunc: func(names []string, timeout int) (*workq.LeasedJob, error) {
+//			return nil, workq.NewResponseError("TIMED-OUT", "")
+//		},
+//	}
+type Mock struct {
+	AddFunc           func(j *workq.BgJob) error
+	AddFromFunc       func(j *workq.BgJob, r io.Reader, size int) error
+	RunFunc           func(j *workq.FgJob) (*workq.JobResult, error)
+	ScheduleFunc      func(j *workq.ScheduledJob) error
+	ResultFunc        func(id string, timeout int) (*workq.JobResult, error)
+	LeaseFunc         func(names []string, timeout int) (*workq.LeasedJob, error)
+	LeaseStreamFunc   func(names []string, timeout int) (*workq.LeasedJob, error)
+	CompleteFunc      func(id string, result []byte) error
+	CompleteFromFunc  func(id string, r io.Reader, size int) error
+	FailFunc          func(id string, result []byte) error
+	FailFromFunc      func(id string, r io.Reader, size int) error
+	DeleteFunc        func(id string) error
+	InspectJobsFunc   func(name string, cursorOffset int, limit int) ([]*workq.InspectedJob, error)
+	InspectJobFunc    func(id string) (*workq.InspectedJob, error)
+	InspectServerFunc func() (*workq.InspectedServer, error)
+	InspectQueuesFunc func(cursorOffset int, limit int) ([]*workq.InspectedQueue, error)
+	InspectQueueFunc  func(name string) (*workq.InspectedQueue, error)
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// ErrNotProgrammed is returned by Mock methods returning a job, result or
+// inspected object without a programmed func, rather than a nil value
+// dereferenced far from the missing func.
+var ErrNotProgrammed = errors.New("workqtest: mock method not programmed")
+
+var (
+	_ workq.Producer = (*Mock)(nil)
+	_ workq.Consumer = (*Mock)(nil)
+	_ workq.Admin    = (*Mock)(nil)
+)
+
+// Calls returns all recorded calls in order.
+func (m *Mock) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// CallsTo returns the recorded calls of method in order.
+func (m *Mock) CallsTo(method string) []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var calls []Call
+	for _, call := range m.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+// Reset clears the recorded calls.
+func (m *Mock) Reset() {
+	m.mu.Lock()
+	m.calls = nil
+	m.mu.Unlock()
+}
+
+func (m *Mock) record(method string, args ...interface{}) {
+	m.mu.Lock()
+	m.calls = append(m.calls, Call{Method: method, Args: args})
+	m.mu.Unlock()
+}
+
+func (m *Mock) Add(j *workq.BgJob) error {
+	m.record("Add", j)
+	if m.AddFunc == nil {
+		return nil
+	}
+
+	return m.AddFunc(j)
+}
+
+func (m *Mock) AddFrom(j *workq.BgJob, r io.Reader, size int) error {
+	m.record("AddFrom", j, r, size)
+	if m.AddFromFunc == nil {
+		return nil
+	}
+
+	return m.AddFromFunc(j, r, size)
+}
+
+func (m *Mock) Run(j *workq.FgJob) (*workq.JobResult, error) {
+	m.record("Run", j)
+	if m.RunFunc == nil {
+		return nil, ErrNotProgrammed
+	}
+
+	return m.RunFunc(j)
+}
+
+func (m *Mock) Schedule(j *workq.ScheduledJob) error {
+	m.record("Schedule", j)
+	if m.ScheduleFunc == nil {
+		return nil
+	}
+
+	return m.ScheduleFunc(j)
+}
+
+func (m *Mock) Result(id string, timeout int) (*workq.JobResult, error) {
+	m.record("Result", id, timeout)
+	if m.ResultFunc == nil {
+		return nil, ErrNotProgrammed
+	}
+
+	return m.ResultFunc(id, timeout)
+}
+
+func (m *Mock) Lease(names []string, timeout int) (*workq.LeasedJob, error) {
+	m.record("Lease", names, timeout)
+	if m.LeaseFunc == nil {
+		return nil, ErrNotProgrammed
+	}
+
+	return m.LeaseFunc(names, timeout)
+}
+
+func (m *Mock) LeaseStream(names []string, timeout int) (*workq.LeasedJob, error) {
+	m.record("LeaseStream", names, timeout)
+	if m.LeaseStreamFunc == nil {
+		return nil, ErrNotProgrammed
+	}
+
+	return m.LeaseStreamFunc(names, timeout)
+}
+
+func (m *Mock) Complete(id string, result []byte) error {
+	m.record("Complete", id, result)
+	if m.CompleteFunc == nil {
+		return nil
+	}
+
+	return m.CompleteFunc(id, result)
+}
+
+func (m *Mock) CompleteFrom(id string, r io.Reader, size int) error {
+	m.record("CompleteFrom", id, r, size)
+	if m.CompleteFromFunc == nil {
+		return nil
+	}
+
+	return m.CompleteFromFunc(id, r, size)
+}
+
+func (m *Mock) Fail(id string, result []byte) error {
+	m.record("Fail", id, result)
+	if m.FailFunc == nil {
+		return nil
+	}
+
+	return m.FailFunc(id, result)
+}
+
+func (m *Mock) FailFrom(id string, r io.Reader, size int) error {
+	m.record("FailFrom", id, r, size)
+	if m.FailFromFunc == nil {
+		return nil
+	}
+
+	return m.FailFromFunc(id, r, size)
+}
+
+func (m *Mock) Delete(id string) error {
+	m.record("Delete", id)
+	if m.DeleteFunc == nil {
+		return nil
+	}
+
+	return m.DeleteFunc(id)
+}
+
+func (m *Mock) InspectJobs(name string, cursorOffset int, limit int) ([]*workq.InspectedJob, error) {
+	m.record("InspectJobs", name, cursorOffset, limit)
+	if m.InspectJobsFunc == nil {
+		return nil, nil
+	}
+
+	return m.InspectJobsFunc(name, cursorOffset, limit)
+}
+
+func (m *Mock) InspectJob(id string) (*workq.InspectedJob, error) {
+	m.record("InspectJob", id)
+	if m.InspectJobFunc == nil {
+		return nil, ErrNotProgrammed
+	}
+
+	return m.InspectJobFunc(id)
+}
+
+func (m *Mock) InspectServer() (*workq.InspectedServer, error) {
+	m.record("InspectServer")
+	if m.InspectServerFunc == nil {
+		return nil, ErrNotProgrammed
+	}
+
+	return m.InspectServerFunc()
+}
+
+func (m *Mock) InspectQueues(cursorOffset int, limit int) ([]*workq.InspectedQueue, error) {
+	m.record("InspectQueues", cursorOffset, limit)
+	if m.InspectQueuesFunc == nil {
+		return nil, nil
+	}
+
+	return m.InspectQueuesFunc(cursorOffset, limit)
+}
+
+func (m *Mock) InspectQueue(name string) (*workq.InspectedQueue, error) {
+	m.record("InspectQueue", name)
+	if m.InspectQueueFunc == nil {
+		return nil, ErrNotProgrammed
+	}
+
+	return m.InspectQueueFunc(name)
+}
@@ -0,0 +1,101 @@
+package workqtest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/iamduo/go-workq"
+)
+
+func TestMockRecordsCalls(t *testing.T) {
+	m := &Mock{}
+	j := &workq.BgJob{ID: id1, Name: "j1"}
+	err := m.Add(j)
+	if err != nil {
+		t.Fatalf("Add failed, err=%s", err)
+	}
+
+	// Unprogrammed methods returning a job fail rather than return nil.
+	j2, err := m.Lease([]string{"j1"}, 1000)
+	if j2 != nil || err != ErrNotProgrammed {
+		t.Fatalf("Lease mismatch, j=%+v, err=%v", j2, err)
+	}
+
+	err = m.Complete(id1, []byte("a"))
+	if err != nil {
+		t.Fatalf("Complete failed, err=%s", err)
+	}
+
+	expCalls := []Call{
+		{Method: "Add", Args: []interface{}{j}},
+		{Method: "Lease", Args: []interface{}{[]string{"j1"}, 1000}},
+		{Method: "Complete", Args: []interface{}{id1, []byte("a")}},
+	}
+	if !reflect.DeepEqual(m.Calls(), expCalls) {
+		t.Fatalf("Calls mismatch, act=%+v", m.Calls())
+	}
+
+	if calls := m.CallsTo("Lease"); len(calls) != 1 || calls[0].Args[1] != 1000 {
+		t.Fatalf("Lease calls mismatch, act=%+v", calls)
+	}
+
+	m.Reset()
+	if len(m.Calls()) != 0 {
+		t.Fatalf("Calls not reset, act=%+v", m.Calls())
+	}
+}
+
+func TestMockProgrammedResponses(t *testing.T) {
+	expErr := workq.NewResponseError("TIMED-OUT", "")
+	m := &Mock{
+		LeaseFunc: func(names []string, timeout int) (*workq.LeasedJob, error) {
+			return nil, expErr
+		},
+		ResultFunc: func(id string, timeout int) (*workq.JobResult, error) {
+			return &workq.JobResult{Success: true, Result: []byte(id)}, nil
+		},
+	}
+
+	var consumer workq.Consumer = m
+	_, err := consumer.Lease([]string{"j1"}, 1000)
+	if err != expErr {
+		t.Fatalf("Error mismatch, act=%v", err)
+	}
+
+	var producer workq.Producer = m
+	result, err := producer.Result(id1, 1000)
+	if err != nil || !result.Success || string(result.Result) != id1 {
+		t.Fatalf("Result mismatch, result=%+v, err=%v", result, err)
+	}
+}
+
+func TestMockJobScanner(t *testing.T) {
+	m := &Mock{
+		InspectJobsFunc: func(name string, cursorOffset int, limit int) ([]*workq.InspectedJob, error) {
+			if cursorOffset > 0 {
+				return nil, errors.New("page error")
+			}
+
+			return []*workq.InspectedJob{{}}, nil
+		},
+	}
+
+	s := workq.NewJobScanner(context.Background(), m, "j1", 1, 0)
+	n := 0
+	for s.Scan() {
+		n++
+	}
+	if n != 1 || s.Err() == nil || s.Err().Error() != "page error" {
+		t.Fatalf("Scan mismatch, n=%d, err=%v", n, s.Err())
+	}
+
+	expCalls := []Call{
+		{Method: "InspectJobs", Args: []interface{}{"j1", 0, 1}},
+		{Method: "InspectJobs", Args: []interface{}{"j1", 1, 1}},
+	}
+	if !reflect.DeepEqual(m.Calls(), expCalls) {
+		t.Fatalf("Calls mismatch, act=%+v", m.Calls())
+	}
+}
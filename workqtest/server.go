@@ -0,0 +1,239 @@
+// Package workqtest provides an in-process Workq server for testing clients
+// and workers without a workq binary.
+//
+// The server speaks the Workq protocol over local TCP connections or net.Pipe
+// and keeps jobs in memory with the queue semantics of workq: priorities,
+// time-to-run re-leases, time-to-live expiry, max attempts and max fails.
+//
+//	srv := workqtest.NewServer()
+//	defer srv.Close()
+//
+//	client, err := workq.Connect(srv.Addr)
+//	// or without a network listener:
+//	client := srv.Client()
+//
+// Time is read from a Clock, a FakeClock makes TTR, TTL and scheduled time
+// behavior deterministic.
+package workqtest
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/iamduo/go-workq"
+)
+
+// Server is an in-memory Workq server.
+type Server struct {
+	// Addr is the "host:port" the server listens on once started.
+	Addr string
+
+	listener net.Listener
+	clock    Clock
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	seq     uint64
+	evicted int
+	started time.Time
+	conns   map[net.Conn]struct{}
+	// Closed and replaced on every state change to wake waiting commands.
+	changed chan struct{}
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// ServerOption configures a Server.
+type ServerOption func(*Server)
+
+// WithClock sets the time source of the server, see FakeClock.
+func WithClock(clock Clock) ServerOption {
+	return func(s *Server) {
+		s.clock = clock
+	}
+}
+
+// NewServer returns a Server listening on a random local port.
+func NewServer(opts ...ServerOption) *Server {
+	s := NewUnstartedServer(opts...)
+	s.Start()
+	return s
+}
+
+// NewUnstartedServer returns a Server without a network listener.
+// Connections can be made with Pipe or Client, or after calling Start.
+func NewUnstartedServer(opts ...ServerOption) *Server {
+	s := &Server{
+		clock:   realClock{},
+		jobs:    make(map[string]*job),
+		conns:   make(map[net.Conn]struct{}),
+		changed: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.started = s.clock.Now()
+	return s
+}
+
+// Start listens on a random local port and sets Addr.
+// Panics if the listener can not be created.
+func (s *Server) Start() {
+	if s.listener != nil {
+		panic("workqtest: server already started")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("workqtest: failed to listen: " + err.Error())
+	}
+
+	s.listener = l
+	s.Addr = l.Addr().String()
+	s.wg.Add(1)
+	go s.accept()
+}
+
+// Pipe returns the client side of an in-memory connection to the server.
+func (s *Server) Pipe() net.Conn {
+	client, server := net.Pipe()
+	if !s.track(server) {
+		client.Close()
+		server.Close()
+		return client
+	}
+
+	go s.serve(server)
+	return client
+}
+
+// Client returns a workq.Client connected to the server through Pipe.
+func (s *Server) Client(opts ...workq.Option) *workq.Client {
+	return workq.NewClient(s.Pipe(), opts...)
+}
+
+// Close stops the listener, closes all connections and aborts waiting
+// commands. Close waits for all connections to finish.
+func (s *Server) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		if s.listener != nil {
+			err = s.listener.Close()
+		}
+
+		s.mu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+	})
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		if !s.track(conn) {
+			conn.Close()
+			return
+		}
+
+		go s.serve(conn)
+	}
+}
+
+// Register a connection, returns false once the server is closed.
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.done:
+		return false
+	default:
+	}
+
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+// Serve commands on conn until it is closed or a protocol error occurs.
+func (s *Server) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	c := &serverConn{
+		srv: s,
+		rdr: bufio.NewReader(conn),
+		wrt: bufio.NewWriter(conn),
+	}
+	for {
+		ok := c.handle()
+		if c.wrt.Flush() != nil || !ok {
+			return
+		}
+	}
+}
+
+// Notify waiting commands of a state change. Requires s.mu.
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// Wait until try reports success, the timeout passes or the server closes.
+// try is called with s.mu held after time based transitions are applied and
+// reports whether waiting is over.
+func (s *Server) wait(timeout time.Duration, try func(now time.Time) bool) bool {
+	deadline := s.clock.Now().Add(timeout)
+	for {
+		s.mu.Lock()
+		now := s.clock.Now()
+		s.tick(now)
+		if try(now) {
+			s.mu.Unlock()
+			return true
+		}
+
+		if !now.Before(deadline) {
+			s.mu.Unlock()
+			return false
+		}
+
+		wake := deadline
+		if next, ok := s.nextEvent(); ok && next.Before(wake) {
+			wake = next
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		after, stop := s.clock.After(wake.Sub(now))
+		select {
+		case <-changed:
+		case <-after:
+		case <-s.done:
+			stop()
+			return false
+		}
+		stop()
+	}
+}
@@ -0,0 +1,428 @@
+package workqtest
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iamduo/go-workq"
+)
+
+const (
+	id1 = "6ba7b810-9dad-11d1-80b4-00c04fd430c4"
+	id2 = "6ba7b811-9dad-11d1-80b4-00c04fd430c4"
+	id3 = "6ba7b812-9dad-11d1-80b4-00c04fd430c4"
+)
+
+func expCode(t *testing.T, err error, code string) {
+	t.Helper()
+	rerr, ok := err.(*workq.ResponseError)
+	if !ok || rerr.Code() != code {
+		t.Fatalf("Error mismatch, exp=%s, act=%v", code, err)
+	}
+}
+
+func TestAddLeaseCompleteResult(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	producer, err := workq.Connect(srv.Addr)
+	if err != nil {
+		t.Fatalf("Unable to connect, err=%s", err)
+	}
+	defer producer.Close()
+	worker := srv.Client()
+	defer worker.Close()
+
+	err = producer.Add(&workq.BgJob{ID: id1, Name: "j1", TTR: 1000, TTL: 60000, Payload: []byte("a")})
+	if err != nil {
+		t.Fatalf("Add failed, err=%s", err)
+	}
+
+	expCode(t, producer.Add(&workq.BgJob{ID: id1, Name: "j1", TTR: 1000, TTL: 60000}), "CLIENT-ERROR")
+
+	_, err = producer.Result(id1, 0)
+	expCode(t, err, "TIMED-OUT")
+
+	j, err := worker.Lease([]string{"j2", "j1"}, 1000)
+	if err != nil {
+		t.Fatalf("Lease failed, err=%s", err)
+	}
+	if j.ID != id1 || j.Name != "j1" || j.TTR != 1000 || string(j.Payload) != "a" {
+		t.Fatalf("Leased job mismatch, act=%+v", j)
+	}
+
+	err = worker.Complete(id1, []byte("done"))
+	if err != nil {
+		t.Fatalf("Complete failed, err=%s", err)
+	}
+
+	expCode(t, worker.Complete(id1, nil), "NOT-FOUND")
+
+	result, err := producer.Result(id1, 1000)
+	if err != nil {
+		t.Fatalf("Result failed, err=%s", err)
+	}
+	if !result.Success || string(result.Result) != "done" {
+		t.Fatalf("Result mismatch, act=%+v", result)
+	}
+}
+
+func TestLeaseWaitsForAdd(t *testing.T) {
+	srv := NewUnstartedServer()
+	defer srv.Close()
+
+	done := make(chan *workq.LeasedJob)
+	go func() {
+		j, err := srv.Client().Lease([]string{"j1"}, 5000)
+		if err != nil {
+			t.Errorf("Lease failed, err=%s", err)
+		}
+		done <- j
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	err := srv.Client().Add(&workq.BgJob{ID: id1, Name: "j1", TTR: 1000, TTL: 60000})
+	if err != nil {
+		t.Fatalf("Add failed, err=%s", err)
+	}
+
+	j := <-done
+	if j == nil || j.ID != id1 {
+		t.Fatalf("Leased job mismatch, act=%+v", j)
+	}
+}
+
+func TestLeaseTimeout(t *testing.T) {
+	srv := NewUnstartedServer()
+	defer srv.Close()
+
+	_, err := srv.Client().Lease([]string{"j1"}, 10)
+	expCode(t, err, "TIMED-OUT")
+}
+
+func TestLeasePriority(t *testing.T) {
+	srv := NewUnstartedServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	jobs := []*workq.BgJob{
+		{ID: id1, Name: "j1", TTR: 1000, TTL: 60000},
+		{ID: id2, Name: "j1", TTR: 1000, TTL: 60000, Priority: 10},
+		{ID: id3, Name: "j1", TTR: 1000, TTL: 60000},
+	}
+	for _, j := range jobs {
+		err := client.Add(j)
+		if err != nil {
+			t.Fatalf("Add failed, err=%s", err)
+		}
+	}
+
+	for _, expID := range []string{id2, id1, id3} {
+		j, err := client.Lease([]string{"j1"}, 0)
+		if err != nil {
+			t.Fatalf("Lease failed, err=%s", err)
+		}
+		if j.ID != expID {
+			t.Fatalf("Lease order mismatch, exp=%s, act=%s", expID, j.ID)
+		}
+	}
+}
+
+func TestTTRReleaseAndMaxAttempts(t *testing.T) {
+	srv := NewUnstartedServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	err := client.Add(&workq.BgJob{ID: id1, Name: "j1", TTR: 10, TTL: 60000, MaxAttempts: 2})
+	if err != nil {
+		t.Fatalf("Add failed, err=%s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		j, err := client.Lease([]string{"j1"}, 1000)
+		if err != nil {
+			t.Fatalf("Lease %d failed, err=%s", i, err)
+		}
+		if j.ID != id1 {
+			t.Fatalf("Leased job mismatch, act=%+v", j)
+		}
+	}
+
+	_, err = client.Lease([]string{"j1"}, 50)
+	expCode(t, err, "TIMED-OUT")
+
+	j, err := client.InspectJob(id1)
+	if err != nil {
+		t.Fatalf("Inspect failed, err=%s", err)
+	}
+	if j.State != workq.JobStateFailed || j.Attempts != 2 {
+		t.Fatalf("Inspected job mismatch, act=%+v", j)
+	}
+}
+
+func TestFailMaxFails(t *testing.T) {
+	srv := NewUnstartedServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	err := client.Add(&workq.BgJob{ID: id1, Name: "j1", TTR: 1000, TTL: 60000, MaxFails: 2})
+	if err != nil {
+		t.Fatalf("Add failed, err=%s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := client.Lease([]string{"j1"}, 0)
+		if err != nil {
+			t.Fatalf("Lease %d failed, err=%s", i, err)
+		}
+
+		err = client.Fail(id1, []byte("err"))
+		if err != nil {
+			t.Fatalf("Fail %d failed, err=%s", i, err)
+		}
+	}
+
+	result, err := client.Result(id1, 0)
+	if err != nil {
+		t.Fatalf("Result failed, err=%s", err)
+	}
+	if result.Success || string(result.Result) != "err" {
+		t.Fatalf("Result mismatch, act=%+v", result)
+	}
+
+	j, err := client.InspectJob(id1)
+	if err != nil {
+		t.Fatalf("Inspect failed, err=%s", err)
+	}
+	if j.State != workq.JobStateFailed || j.Fails != 2 || j.Attempts != 2 {
+		t.Fatalf("Inspected job mismatch, act=%+v", j)
+	}
+}
+
+func TestTTLExpiry(t *testing.T) {
+	srv := NewUnstartedServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	err := client.Add(&workq.BgJob{ID: id1, Name: "j1", TTR: 1000, TTL: 10})
+	if err != nil {
+		t.Fatalf("Add failed, err=%s", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	_, err = client.InspectJob(id1)
+	expCode(t, err, "NOT-FOUND")
+
+	s, err := client.InspectServer()
+	if err != nil {
+		t.Fatalf("Inspect failed, err=%s", err)
+	}
+	if s.EvictedJobs != 1 || s.ActiveClients != 1 {
+		t.Fatalf("Inspected server mismatch, act=%+v", s)
+	}
+}
+
+func TestRun(t *testing.T) {
+	srv := NewUnstartedServer()
+	defer srv.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		worker := srv.Client()
+		j, err := worker.Lease([]string{"j1"}, 5000)
+		if err != nil {
+			t.Errorf("Lease failed, err=%s", err)
+			return
+		}
+
+		err = worker.Complete(j.ID, bytes.ToUpper(j.Payload))
+		if err != nil {
+			t.Errorf("Complete failed, err=%s", err)
+		}
+	}()
+
+	result, err := srv.Client().Run(&workq.FgJob{ID: id1, Name: "j1", TTR: 1000, Timeout: 5000, Payload: []byte("a")})
+	if err != nil {
+		t.Fatalf("Run failed, err=%s", err)
+	}
+	if !result.Success || string(result.Result) != "A" {
+		t.Fatalf("Result mismatch, act=%+v", result)
+	}
+	wg.Wait()
+
+	_, err = srv.Client().Run(&workq.FgJob{ID: id2, Name: "j1", TTR: 1000, Timeout: 10})
+	expCode(t, err, "TIMED-OUT")
+
+	_, err = srv.Client().InspectJob(id2)
+	expCode(t, err, "NOT-FOUND")
+}
+
+func TestSchedule(t *testing.T) {
+	srv := NewUnstartedServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	future := time.Now().UTC().Add(time.Hour).Format(workq.TimeFormat)
+	past := time.Now().UTC().Add(-time.Second).Format(workq.TimeFormat)
+	err := client.Schedule(&workq.ScheduledJob{ID: id1, Name: "j1", TTR: 1000, TTL: 60000, Time: future})
+	if err != nil {
+		t.Fatalf("Schedule failed, err=%s", err)
+	}
+	err = client.Schedule(&workq.ScheduledJob{ID: id2, Name: "j1", TTR: 1000, TTL: 60000, Time: past})
+	if err != nil {
+		t.Fatalf("Schedule failed, err=%s", err)
+	}
+
+	q, err := client.InspectQueue("j1")
+	if err != nil {
+		t.Fatalf("Inspect failed, err=%s", err)
+	}
+	if q.ReadyLen != 1 || q.ScheduledLen != 1 || q.LeasedLen != 0 {
+		t.Fatalf("Inspected queue mismatch, act=%+v", q)
+	}
+
+	j, err := client.Lease([]string{"j1"}, 10)
+	if err != nil || j.ID != id2 {
+		t.Fatalf("Lease mismatch, job=%+v, err=%v", j, err)
+	}
+
+	_, err = client.Lease([]string{"j1"}, 10)
+	expCode(t, err, "TIMED-OUT")
+}
+
+func TestDelete(t *testing.T) {
+	srv := NewUnstartedServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	expCode(t, client.Delete(id1), "NOT-FOUND")
+
+	err := client.Add(&workq.BgJob{ID: id1, Name: "j1", TTR: 1000, TTL: 60000})
+	if err != nil {
+		t.Fatalf("Add failed, err=%s", err)
+	}
+
+	err = client.Delete(id1)
+	if err != nil {
+		t.Fatalf("Delete failed, err=%s", err)
+	}
+
+	_, err = client.Result(id1, 0)
+	expCode(t, err, "NOT-FOUND")
+}
+
+func TestInspect(t *testing.T) {
+	srv := NewUnstartedServer()
+	defer srv.Close()
+	client := srv.Client(workq.WithStrictInspect())
+
+	jobs := []*workq.BgJob{
+		{ID: id1, Name: "j2", TTR: 1000, TTL: 60000, Payload: []byte("a\r\nb"), Priority: -1, MaxAttempts: 3, MaxFails: 1},
+		{ID: id2, Name: "j2", TTR: 1000, TTL: 60000},
+		{ID: id3, Name: "j1", TTR: 1000, TTL: 60000},
+	}
+	for _, j := range jobs {
+		err := client.Add(j)
+		if err != nil {
+			t.Fatalf("Add failed, err=%s", err)
+		}
+	}
+
+	inspected, err := client.InspectJobs("j2", 0, 1)
+	if err != nil {
+		t.Fatalf("Inspect failed, err=%s", err)
+	}
+	if len(inspected) != 1 {
+		t.Fatalf("Inspected jobs len mismatch, act=%d", len(inspected))
+	}
+	j := inspected[0]
+	if j.ID != id1 || j.Name != "j2" || j.TTR != 1000 || j.TTL != 60000 ||
+		string(j.Payload) != "a\r\nb" || j.Priority != -1 || j.MaxAttempts != 3 ||
+		j.MaxFails != 1 || j.State != workq.JobStateNew || j.Created.IsZero() {
+		t.Fatalf("Inspected job mismatch, act=%+v", j)
+	}
+
+	inspected, err = client.InspectJobs("j2", 1, 10)
+	if err != nil || len(inspected) != 1 || inspected[0].ID != id2 {
+		t.Fatalf("Inspected page mismatch, jobs=%v, err=%v", inspected, err)
+	}
+
+	inspected, err = client.InspectJobs("j2", 2, 10)
+	if err != nil || len(inspected) != 0 {
+		t.Fatalf("Inspected page mismatch, jobs=%v, err=%v", inspected, err)
+	}
+
+	queues, err := client.InspectQueues(0, 10)
+	if err != nil {
+		t.Fatalf("Inspect failed, err=%s", err)
+	}
+	if len(queues) != 2 || queues[0].Name != "j1" || queues[1].Name != "j2" || queues[1].ReadyLen != 2 {
+		t.Fatalf("Inspected queues mismatch, act=%+v", queues)
+	}
+
+	_, err = client.InspectQueue("j3")
+	expCode(t, err, "NOT-FOUND")
+}
+
+func TestLeaseStreamPayload(t *testing.T) {
+	srv := NewUnstartedServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	payload := bytes.Repeat([]byte("a"), 100000)
+	err := client.AddFrom(&workq.BgJob{ID: id1, Name: "j1", TTR: 1000, TTL: 60000}, bytes.NewReader(payload), len(payload))
+	if err != nil {
+		t.Fatalf("Add failed, err=%s", err)
+	}
+
+	j, err := client.LeaseStream([]string{"j1"}, 0)
+	if err != nil {
+		t.Fatalf("Lease failed, err=%s", err)
+	}
+
+	b, err := ioutil.ReadAll(j.PayloadReader())
+	if err != nil || !bytes.Equal(b, payload) {
+		t.Fatalf("Payload mismatch, len=%d, err=%v", len(b), err)
+	}
+}
+
+func TestInvalidCommand(t *testing.T) {
+	srv := NewUnstartedServer()
+	defer srv.Close()
+	client := srv.Client()
+
+	_, err := client.Do(context.Background(), "unknown", nil, nil)
+	expCode(t, err, "CLIENT-ERROR")
+
+	expCode(t, client.Add(&workq.BgJob{ID: "1", Name: "j1", TTR: 1000, TTL: 60000}), "CLIENT-ERROR")
+	expCode(t, client.Add(&workq.BgJob{ID: id1, Name: "j/1", TTR: 1000, TTL: 60000}), "CLIENT-ERROR")
+	expCode(t, client.Add(&workq.BgJob{ID: id1, Name: "j1", TTR: 0, TTL: 60000}), "CLIENT-ERROR")
+
+	err = client.Add(&workq.BgJob{ID: id1, Name: "j1", TTR: 1000, TTL: 60000})
+	if err != nil {
+		t.Fatalf("Add after errors failed, err=%s", err)
+	}
+}
+
+func TestCloseAbortsWaits(t *testing.T) {
+	srv := NewServer()
+	client := srv.Client()
+
+	done := make(chan error)
+	go func() {
+		_, err := client.Lease([]string{"j1"}, 60000)
+		done <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	srv.Close()
+	if err := <-done; err == nil {
+		t.Fatal("Expected error after server close")
+	}
+}
@@ -0,0 +1,340 @@
+package workqtest
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Transcript is a recorded exchange of bytes between a client and a server.
+//
+// Transcripts are stored as text, one quoted chunk per line, sent bytes
+// prefixed with "> " and received bytes with "< ". Chunks are split after
+// each "\n" and consecutive chunks of the same direction are joined when read.
+// Blank lines and lines starting with "#" are ignored.
+//
+//	> "add 6ba7b810-9dad-11d1-80b4-00c04fd430c4 ping 5000 60000 5\r\n"
+//	> "Ping!\r\n"
+//	< "+OK\r\n"
+type Transcript struct {
+	Entries []TranscriptEntry
+}
+
+// TranscriptEntry is a chunk of bytes sent or received.
+type TranscriptEntry struct {
+	Sent bool
+	Data []byte
+}
+
+// Append b to the transcript, joining it with the last entry of the same
+// direction.
+func (t *Transcript) append(sent bool, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+
+	if n := len(t.Entries); n > 0 && t.Entries[n-1].Sent == sent {
+		t.Entries[n-1].Data = append(t.Entries[n-1].Data, b...)
+		return
+	}
+
+	t.Entries = append(t.Entries, TranscriptEntry{Sent: sent, Data: append([]byte(nil), b...)})
+}
+
+// WriteTo writes the transcript in text form.
+func (t *Transcript) WriteTo(w io.Writer) (int64, error) {
+	var buf []byte
+	for _, e := range t.Entries {
+		prefix := "< "
+		if e.Sent {
+			prefix = "> "
+		}
+
+		data := e.Data
+		for len(data) > 0 {
+			n := bytes.IndexByte(data, '\n') + 1
+			if n == 0 {
+				n = len(data)
+			}
+
+			buf = append(buf, prefix...)
+			buf = strconv.AppendQuote(buf, string(data[:n]))
+			buf = append(buf, '\n')
+			data = data[n:]
+		}
+	}
+
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// Save writes the transcript in text form to path.
+func (t *Transcript) Save(path string) error {
+	var buf bytes.Buffer
+	_, err := t.WriteTo(&buf)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// ReadTranscript reads a transcript in text form.
+func ReadTranscript(r io.Reader) (*Transcript, error) {
+	t := &Transcript{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		if len(line) < 2 || (line[:2] != "> " && line[:2] != "< ") {
+			return nil, fmt.Errorf("workqtest: transcript line %d: expected \"> \" or \"< \" prefix", n)
+		}
+
+		data, err := strconv.Unquote(line[2:])
+		if err != nil {
+			return nil, fmt.Errorf("workqtest: transcript line %d: %s", n, err)
+		}
+
+		t.append(line[0] == '>', []byte(data))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// LoadTranscript reads a transcript in text form from path.
+func LoadTranscript(path string) (*Transcript, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ReadTranscript(bytes.NewReader(b))
+}
+
+// RecordConn wraps a net.Conn recording all bytes sent and received.
+//
+//	conn, _ := net.Dial("tcp", addr)
+//	rec := workqtest.NewRecordConn(conn)
+//	client := workq.NewClient(rec)
+//	// ...
+//	rec.Transcript().Save("testdata/add.transcript")
+type RecordConn struct {
+	net.Conn
+
+	mu sync.Mutex
+	t  Transcript
+}
+
+// NewRecordConn returns a RecordConn recording conn.
+func NewRecordConn(conn net.Conn) *RecordConn {
+	return &RecordConn{Conn: conn}
+}
+
+func (c *RecordConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.mu.Lock()
+	c.t.append(false, b[:n])
+	c.mu.Unlock()
+	return n, err
+}
+
+func (c *RecordConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.mu.Lock()
+	c.t.append(true, b[:n])
+	c.mu.Unlock()
+	return n, err
+}
+
+// Transcript returns a copy of the exchange recorded so far.
+func (c *RecordConn) Transcript() *Transcript {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &Transcript{}
+	for _, e := range c.t.Entries {
+		t.append(e.Sent, e.Data)
+	}
+
+	return t
+}
+
+// ErrReplayMismatch is matched by replay errors through errors.Is.
+var ErrReplayMismatch = errors.New("workqtest: replay mismatch")
+
+// ReplayConn is a net.Conn serving a Transcript back to a client.
+// Writes must match the sent bytes of the transcript in order and reads are
+// served the received bytes once all preceding sent bytes were written.
+// Any deviation fails the Read or Write and is reported by Verify.
+type ReplayConn struct {
+	mu     sync.Mutex
+	t      *Transcript
+	entry  int
+	offset int
+	err    error
+	closed bool
+}
+
+// NewReplayConn returns a ReplayConn serving t.
+func NewReplayConn(t *Transcript) *ReplayConn {
+	return &ReplayConn{t: t}
+}
+
+// Return the unconsumed remainder of the current entry, nil at the end.
+// Requires c.mu.
+func (c *ReplayConn) current() *TranscriptEntry {
+	for c.entry < len(c.t.Entries) && c.offset >= len(c.t.Entries[c.entry].Data) {
+		c.entry++
+		c.offset = 0
+	}
+
+	if c.entry == len(c.t.Entries) {
+		return nil
+	}
+
+	return &c.t.Entries[c.entry]
+}
+
+// Record the first mismatch. Requires c.mu.
+func (c *ReplayConn) mismatch(format string, args ...interface{}) error {
+	if c.err == nil {
+		c.err = fmt.Errorf("%w: "+format, append([]interface{}{ErrReplayMismatch}, args...)...)
+	}
+
+	return c.err
+}
+
+func (c *ReplayConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	if c.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	e := c.current()
+	if e == nil {
+		return 0, io.EOF
+	}
+
+	if e.Sent {
+		return 0, c.mismatch("read while expecting write of %q", e.Data[c.offset:])
+	}
+
+	n := copy(b, e.Data[c.offset:])
+	c.offset += n
+	return n, nil
+}
+
+func (c *ReplayConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	if c.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	written := 0
+	for written < len(b) {
+		e := c.current()
+		if e == nil {
+			return written, c.mismatch("unexpected write of %q after end of transcript", b[written:])
+		}
+
+		if !e.Sent {
+			return written, c.mismatch("unexpected write of %q while expecting read of %q", b[written:], e.Data[c.offset:])
+		}
+
+		exp := e.Data[c.offset:]
+		act := b[written:]
+		if len(act) > len(exp) {
+			act = act[:len(exp)]
+		}
+
+		if !bytes.Equal(act, exp[:len(act)]) {
+			return written, c.mismatch("write of %q, expected %q", act, exp[:len(act)])
+		}
+
+		c.offset += len(act)
+		written += len(act)
+	}
+
+	return written, nil
+}
+
+// Verify returns the first mismatch, or an error if the transcript was not
+// fully replayed.
+func (c *ReplayConn) Verify() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+
+	if e := c.current(); e != nil {
+		return fmt.Errorf("%w: transcript not fully replayed, next %q", ErrReplayMismatch, e.Data[c.offset:])
+	}
+
+	return nil
+}
+
+func (c *ReplayConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return io.ErrClosedPipe
+	}
+
+	c.closed = true
+	return nil
+}
+
+func (c *ReplayConn) LocalAddr() net.Addr {
+	return replayAddr{}
+}
+
+func (c *ReplayConn) RemoteAddr() net.Addr {
+	return replayAddr{}
+}
+
+func (c *ReplayConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *ReplayConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *ReplayConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+type replayAddr struct{}
+
+func (replayAddr) Network() string {
+	return "replay"
+}
+
+func (replayAddr) String() string {
+	return "replay"
+}
@@ -0,0 +1,257 @@
+package workqtest
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iamduo/go-workq"
+)
+
+// Re-record golden transcripts with "go test -run TestTranscripts -update".
+// Transcripts are recorded against the server at WORKQ_ADDR when set, which
+// must be freshly started, otherwise against the in-memory Server. The first
+// lines of each transcript record which one it was.
+var update = flag.Bool("update", false, "update golden transcripts")
+
+// Transcript headers naming the server recorded against.
+const (
+	fakeTranscriptHeader = "# Fake-server fixture: recorded against the in-memory workqtest.Server, not a\n" +
+		"# real workq server. Re-record against a real server with \"make transcripts\".\n"
+	realTranscriptHeader = "# Recorded against a real workq server with \"make transcripts\".\n"
+)
+
+var transcriptScenarios = []struct {
+	name string
+	run  func(t *testing.T, client *workq.Client)
+}{
+	{
+		name: "add_lease_complete",
+		run: func(t *testing.T, client *workq.Client) {
+			err := client.Add(&workq.BgJob{ID: id1, Name: "transcript1", TTR: 5000, TTL: 60000, Payload: []byte("Ping!"), Priority: 10})
+			if err != nil {
+				t.Fatalf("Add failed, err=%s", err)
+			}
+
+			j, err := client.Lease([]string{"transcript1"}, 1000)
+			if err != nil || j.ID != id1 || string(j.Payload) != "Ping!" {
+				t.Fatalf("Lease mismatch, job=%+v, err=%v", j, err)
+			}
+
+			err = client.Complete(id1, []byte("Pong!"))
+			if err != nil {
+				t.Fatalf("Complete failed, err=%s", err)
+			}
+
+			result, err := client.Result(id1, 1000)
+			if err != nil || !result.Success || string(result.Result) != "Pong!" {
+				t.Fatalf("Result mismatch, result=%+v, err=%v", result, err)
+			}
+		},
+	},
+	{
+		name: "lease_fail_delete",
+		run: func(t *testing.T, client *workq.Client) {
+			err := client.Add(&workq.BgJob{ID: id2, Name: "transcript2", TTR: 5000, TTL: 60000, MaxAttempts: 3, MaxFails: 1})
+			if err != nil {
+				t.Fatalf("Add failed, err=%s", err)
+			}
+
+			_, err = client.Lease([]string{"transcript2"}, 1000)
+			if err != nil {
+				t.Fatalf("Lease failed, err=%s", err)
+			}
+
+			err = client.Fail(id2, []byte("error"))
+			if err != nil {
+				t.Fatalf("Fail failed, err=%s", err)
+			}
+
+			err = client.Delete(id2)
+			if err != nil {
+				t.Fatalf("Delete failed, err=%s", err)
+			}
+
+			err = client.Delete(id2)
+			if rerr, ok := err.(*workq.ResponseError); !ok || rerr.Code() != "NOT-FOUND" {
+				t.Fatalf("Delete error mismatch, err=%v", err)
+			}
+		},
+	},
+	{
+		name: "inspect",
+		run: func(t *testing.T, client *workq.Client) {
+			err := client.Add(&workq.BgJob{ID: id3, Name: "transcript3", TTR: 5000, TTL: 60000, Payload: []byte("a\r\nb")})
+			if err != nil {
+				t.Fatalf("Add failed, err=%s", err)
+			}
+
+			jobs, err := client.InspectJobs("transcript3", 0, 10)
+			if err != nil || len(jobs) != 1 || string(jobs[0].Payload) != "a\r\nb" {
+				t.Fatalf("InspectJobs mismatch, jobs=%+v, err=%v", jobs, err)
+			}
+
+			_, err = client.InspectJob(id3)
+			if err != nil {
+				t.Fatalf("InspectJob failed, err=%s", err)
+			}
+
+			q, err := client.InspectQueue("transcript3")
+			if err != nil || q.ReadyLen != 1 {
+				t.Fatalf("InspectQueue mismatch, queue=%+v, err=%v", q, err)
+			}
+
+			_, err = client.InspectQueues(0, 10)
+			if err != nil {
+				t.Fatalf("InspectQueues failed, err=%s", err)
+			}
+
+			_, err = client.InspectServer()
+			if err != nil {
+				t.Fatalf("InspectServer failed, err=%s", err)
+			}
+		},
+	},
+}
+
+func TestTranscripts(t *testing.T) {
+	for _, sc := range transcriptScenarios {
+		t.Run(sc.name, func(t *testing.T) {
+			path := filepath.Join("testdata", sc.name+".transcript")
+			if *update {
+				recordTranscript(t, path, sc.run)
+				return
+			}
+
+			tr, err := LoadTranscript(path)
+			if err != nil {
+				t.Fatalf("Unable to load transcript, err=%s", err)
+			}
+
+			conn := NewReplayConn(tr)
+			sc.run(t, workq.NewClient(conn))
+			err = conn.Verify()
+			if err != nil {
+				t.Fatalf("Replay failed, err=%s", err)
+			}
+		})
+	}
+}
+
+func recordTranscript(t *testing.T, path string, run func(t *testing.T, client *workq.Client)) {
+	var conn net.Conn
+	header := fakeTranscriptHeader
+	if addr := os.Getenv("WORKQ_ADDR"); addr != "" {
+		var err error
+		conn, err = net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("Unable to connect, err=%s", err)
+		}
+		header = realTranscriptHeader
+	} else {
+		srv := NewUnstartedServer()
+		defer srv.Close()
+		conn = srv.Pipe()
+	}
+
+	rec := NewRecordConn(conn)
+	client := workq.NewClient(rec)
+	defer client.Close()
+	run(t, client)
+
+	buf := bytes.NewBufferString(header)
+	_, err := rec.Transcript().WriteTo(buf)
+	if err != nil {
+		t.Fatalf("Unable to write transcript, err=%s", err)
+	}
+	err = ioutil.WriteFile(path, buf.Bytes(), 0644)
+	if err != nil {
+		t.Fatalf("Unable to save transcript, err=%s", err)
+	}
+}
+
+func TestTranscriptRoundTrip(t *testing.T) {
+	tr := &Transcript{}
+	tr.append(true, []byte("add 6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1 2 3\r\n"))
+	tr.append(true, []byte("a\nb\r\n"))
+	tr.append(false, []byte("+OK\r\n"))
+
+	var buf bytes.Buffer
+	tr.WriteTo(&buf)
+	exp := "> \"add 6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1 2 3\\r\\n\"\n" +
+		"> \"a\\n\"\n" +
+		"> \"b\\r\\n\"\n" +
+		"< \"+OK\\r\\n\"\n"
+	if buf.String() != exp {
+		t.Fatalf("Transcript mismatch, act=%s", buf.String())
+	}
+
+	read, err := ReadTranscript(strings.NewReader("# comment\n\n" + buf.String()))
+	if err != nil {
+		t.Fatalf("Unable to read transcript, err=%s", err)
+	}
+	if len(read.Entries) != 2 || !read.Entries[0].Sent || string(read.Entries[0].Data) != "add 6ba7b810-9dad-11d1-80b4-00c04fd430c4 j1 1 2 3\r\na\nb\r\n" {
+		t.Fatalf("Entries mismatch, act=%+v", read.Entries)
+	}
+
+	for _, bad := range []string{"add\n", "> unquoted\n"} {
+		_, err := ReadTranscript(strings.NewReader(bad))
+		if err == nil {
+			t.Fatalf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	tr, err := ReadTranscript(strings.NewReader(
+		"> \"delete 6ba7b810-9dad-11d1-80b4-00c04fd430c4\\r\\n\"\n" +
+			"< \"+OK\\r\\n\"\n",
+	))
+	if err != nil {
+		t.Fatalf("Unable to read transcript, err=%s", err)
+	}
+
+	conn := NewReplayConn(tr)
+	err = workq.NewClient(conn).Delete(id2)
+	if _, ok := err.(*workq.NetError); !ok {
+		t.Fatalf("Error mismatch, err=%+v", err)
+	}
+	if !errors.Is(conn.Verify(), ErrReplayMismatch) {
+		t.Fatalf("Verify mismatch, err=%v", conn.Verify())
+	}
+
+	conn = NewReplayConn(tr)
+	client := workq.NewClient(conn)
+	if !errors.Is(conn.Verify(), ErrReplayMismatch) {
+		t.Fatalf("Expected unreplayed transcript error, err=%v", conn.Verify())
+	}
+
+	err = client.Delete(id1)
+	if err != nil || conn.Verify() != nil {
+		t.Fatalf("Replay mismatch, err=%v, verify=%v", err, conn.Verify())
+	}
+}
+
+func TestRecordConn(t *testing.T) {
+	srv := NewUnstartedServer()
+	defer srv.Close()
+
+	rec := NewRecordConn(srv.Pipe())
+	err := workq.NewClient(rec).Delete(id1)
+	if err == nil {
+		t.Fatal("Expected NOT-FOUND")
+	}
+
+	tr := rec.Transcript()
+	if len(tr.Entries) != 2 ||
+		string(tr.Entries[0].Data) != "delete "+id1+"\r\n" ||
+		string(tr.Entries[1].Data) != "-NOT-FOUND\r\n" {
+		t.Fatalf("Transcript mismatch, act=%+v", tr.Entries)
+	}
+}